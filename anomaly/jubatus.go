@@ -0,0 +1,76 @@
+package anomaly
+
+import (
+	"fmt"
+	"github.com/zeromberto/jubatus/internal/jubatus"
+	"github.com/zeromberto/jubatus/internal/nearest"
+	"io"
+)
+
+// LoadJubatusLightLOF creates a LightLOF model from a model file saved by
+// jubaanomaly of the original Jubatus using light_lof method. Only the
+// configuration of the model is imported. Rows in the table of the nearest
+// neighbor engine cannot be reused because Jubatus hashes feature vectors
+// with its own random projection, whose hash values aren't comparable with
+// ones of this package, so the file must not have any row. Any unlearner of
+// Jubatus is imported as the random unlearner having the same max size.
+func LoadJubatusLightLOF(r io.Reader) (*LightLOF, error) {
+	f, err := jubatus.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	return loadJubatusLightLOF(f)
+}
+
+func loadJubatusLightLOF(f *jubatus.File) (*LightLOF, error) {
+	method, params, err := f.Method()
+	if err != nil {
+		return nil, err
+	}
+	if method != "light_lof" {
+		return nil, fmt.Errorf("the model isn't light_lof but %v", method)
+	}
+
+	nnNum, err := intParam(params, "nearest_neighbor_num")
+	if err != nil {
+		return nil, err
+	}
+	rnnNum, err := intParam(params, "reverse_nearest_neighbor_num")
+	if err != nil {
+		return nil, err
+	}
+	nnMethod, _ := params["method"].(string)
+	nnAlgo := nearest.ParseAlgorithm(nnMethod)
+	if nnAlgo == InvalidNNAlgorithm {
+		return nil, fmt.Errorf("unsupported nearest neighbor method: %v", params["method"])
+	}
+	nnParams, _ := params["parameter"].(map[string]interface{})
+	hashNum, err := intParam(nnParams, "hash_num")
+	if err != nil {
+		return nil, err
+	}
+	maxSize := 0
+	if u, ok := params["unlearner"]; ok && u != nil {
+		up, _ := params["unlearner_parameter"].(map[string]interface{})
+		if maxSize, err = intParam(up, "max_size"); err != nil {
+			return nil, err
+		}
+	}
+
+	t, err := f.NearestNeighborTable()
+	if err != nil {
+		return nil, err
+	}
+	if len(t.Keys) > 0 {
+		return nil, fmt.Errorf("the model has %v rows, which cannot be imported because Jubatus hashes them differently", len(t.Keys))
+	}
+	return NewLightLOF(nnAlgo, hashNum, nnNum, rnnNum, maxSize, 0)
+}
+
+func intParam(params map[string]interface{}, name string) (int, error) {
+	v, ok := params[name].(float64)
+	if !ok || v != float64(int(v)) {
+		return 0, fmt.Errorf("%v of the model is invalid: %v", name, params[name])
+	}
+	return int(v), nil
+}
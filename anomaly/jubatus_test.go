@@ -0,0 +1,97 @@
+package anomaly
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/zeromberto/jubatus/internal/jubatus"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func writeJubatusLightLOF(config string, bitNum int, hashes [][]uint64, norms []float32) []byte {
+	cols := []jubatus.Column{
+		{Type: jubatus.BitVectorColumn, BitNum: bitNum, Bits: hashes},
+	}
+	if norms != nil {
+		cols = append(cols, jubatus.Column{Type: jubatus.FloatColumn, Floats: norms})
+	}
+	keys := make([]string, len(hashes))
+	for i := range keys {
+		keys[i] = string(rune('a' + i))
+	}
+	tbl := jubatus.PackTable(&jubatus.Table{Keys: keys, Columns: cols})
+
+	buf := bytes.NewBuffer(nil)
+	So(jubatus.Write(buf, &jubatus.File{
+		Type:   "anomaly",
+		Config: config,
+		Data:   []interface{}{[]interface{}{[]interface{}{tbl}, []interface{}{}}, []interface{}{}},
+	}), ShouldBeNil)
+	return buf.Bytes()
+}
+
+func TestLoadJubatusLightLOF(t *testing.T) {
+	Convey("Given an empty light_lof model file of Jubatus using euclid_lsh", t, func() {
+		b := writeJubatusLightLOF(`{"method":"light_lof","parameter":{
+			"nearest_neighbor_num":2,"reverse_nearest_neighbor_num":3,
+			"method":"euclid_lsh","parameter":{"hash_num":8},
+			"unlearner":"lru","unlearner_parameter":{"max_size":10}}}`,
+			8, [][]uint64{}, []float32{})
+
+		Convey("when loading it", func() {
+			l, err := LoadJubatusLightLOF(bytes.NewReader(b))
+			So(err, ShouldBeNil)
+
+			Convey("it should have the parameters", func() {
+				So(l.nnNum, ShouldEqual, 2)
+				So(l.rnnNum, ShouldEqual, 3)
+				So(l.maxSize, ShouldEqual, 10)
+			})
+
+			Convey("it should be trainable", func() {
+				id, _, err := l.AddWithID(FeatureVector{"x": data.Float(1)})
+				So(err, ShouldBeNil)
+				So(id, ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given a light_lof model file of Jubatus using lsh", t, func() {
+		config := `{"method":"light_lof","parameter":{
+			"nearest_neighbor_num":2,"reverse_nearest_neighbor_num":2,
+			"method":"lsh","parameter":{"hash_num":8}}}`
+
+		Convey("when loading it without rows", func() {
+			b := writeJubatusLightLOF(config, 8, [][]uint64{}, nil)
+			l, err := LoadJubatusLightLOF(bytes.NewReader(b))
+			So(err, ShouldBeNil)
+
+			Convey("it shouldn't unlearn rows", func() {
+				So(l.maxSize, ShouldEqual, 0x7fffffff)
+			})
+		})
+
+		Convey("when loading it having rows", func() {
+			// Hash values of Jubatus cannot be compared with hash values of
+			// new feature vectors computed by this package.
+			b := writeJubatusLightLOF(config, 8, [][]uint64{{0x01}, {0x03}, {0x07}}, nil)
+			_, err := LoadJubatusLightLOF(bytes.NewReader(b))
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given a lof model file of Jubatus", t, func() {
+		b := writeJubatusLightLOF(`{"method":"lof","parameter":{}}`, 8, nil, nil)
+
+		Convey("when loading it", func() {
+			_, err := LoadJubatusLightLOF(bytes.NewReader(b))
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -1,11 +1,12 @@
 package anomaly
 
 import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/ugorji/go/codec"
 	"github.com/zeromberto/jubatus/internal/nearest"
-	"github.com/zeromberto/jubatus/internal/nested"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 	"io"
 	"math"
@@ -22,6 +23,12 @@ type LightLOF struct {
 	kdists []float32
 	lrds   []float32
 
+	// keys has the key of each row. origin and seq are used to issue keys of
+	// new rows.
+	keys   []rowKey
+	origin uint64
+	seq    uint64
+
 	// for random unlearner
 	maxSize int
 	rg      *rand.Rand
@@ -29,20 +36,48 @@ type LightLOF struct {
 	m sync.RWMutex
 }
 
+// rowKey identifies a row across models. Mix copies keys with rows so that a
+// row isn't duplicated when models having copies of it are mixed again.
+type rowKey struct {
+	_struct struct{} `codec:",toarray"`
+
+	// Origin is a random number of the model which added the row.
+	Origin uint64
+	Seq    uint64
+}
+
+// newOrigin returns a random origin of row keys. It doesn't use the seed of
+// the model because models created with the same seed must not issue the
+// same keys.
+func newOrigin() uint64 {
+	var b [8]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		return uint64(rand.Int63())
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// newKey issues the key of a new row.
+func (l *LightLOF) newKey() rowKey {
+	k := rowKey{Origin: l.origin, Seq: l.seq}
+	l.seq++
+	return k
+}
+
+// NNAlgorithm is an enum type which represents nearest neighbor algorithms.
+type NNAlgorithm = nearest.Algorithm
+
 const (
 	// InvalidNNAlgorithm represents an invalid nearest neighbor algorithm.
-	InvalidNNAlgorithm NNAlgorithm = iota
+	InvalidNNAlgorithm = nearest.InvalidAlgorithm
 	// LSH represents locality sensitive hashing.
-	LSH
+	LSH = nearest.LSHAlgorithm
 	// Minhash represents minhash.
-	Minhash
+	Minhash = nearest.MinhashAlgorithm
 	// EuclidLSH represents locality sensitive hashing with euclidean distance.
-	EuclidLSH
+	EuclidLSH = nearest.EuclidLSHAlgorithm
 )
 
-// NNAlgorithm is an enum type which represents nearest neighbor algorithms.
-type NNAlgorithm int
-
 // NewLightLOF creates a LightLOF model.
 func NewLightLOF(nnAlgo NNAlgorithm, hashNum, nnNum, rnnNum, maxSize int, seed int64) (*LightLOF, error) {
 	const maxSizeLimit = 0x7fffffff
@@ -63,16 +98,9 @@ func NewLightLOF(nnAlgo NNAlgorithm, hashNum, nnNum, rnnNum, maxSize int, seed i
 		return nil, fmt.Errorf("max size must be less than or equal to %v", maxSizeLimit)
 	}
 
-	var nn nearest.Neighbor
-	switch nnAlgo {
-	case LSH:
-		nn = nearest.NewLSH(hashNum)
-	case Minhash:
-		nn = nearest.NewMinhash(hashNum)
-	case EuclidLSH:
-		nn = nearest.NewEuclidLSH(hashNum)
-	default:
-		return nil, errors.New("invalid nearest neighbor algorithm")
+	nn, err := nearest.New(nnAlgo, hashNum)
+	if err != nil {
+		return nil, err
 	}
 
 	// maxSize == 0 means no unlearn.
@@ -85,13 +113,14 @@ func NewLightLOF(nnAlgo NNAlgorithm, hashNum, nnNum, rnnNum, maxSize int, seed i
 		nn:      nn,
 		nnNum:   nnNum,
 		rnnNum:  rnnNum,
+		origin:  newOrigin(),
 		maxSize: maxSize,
 		rg:      rand.New(rand.NewSource(seed)),
 	}, nil
 }
 
 const (
-	lightLOFFormatVersion = 1
+	lightLOFFormatVersion = 2
 )
 
 type lightLOFMsgpack struct {
@@ -104,6 +133,8 @@ type lightLOFMsgpack struct {
 	LRDs   []float32
 
 	MaxSize int
+
+	Keys []rowKey
 }
 
 // Save saves a LightLOF model.
@@ -124,6 +155,8 @@ func (l *LightLOF) Save(w io.Writer) error {
 		LRDs:   l.lrds,
 
 		MaxSize: l.maxSize,
+
+		Keys: l.keys,
 	}); err != nil {
 		return err
 	}
@@ -140,12 +173,23 @@ func LoadLightLOF(r io.Reader) (*LightLOF, error) {
 	switch formatVersion[0] {
 	case 1:
 		return loadLightLOFFormatV1(r)
+	case 2:
+		return loadLightLOFFormatV2(r)
 	default:
 		return nil, fmt.Errorf("unsupported format version of LightLOF container: %v", formatVersion[0])
 	}
 }
 
 func loadLightLOFFormatV1(r io.Reader) (*LightLOF, error) {
+	// The format version 1 doesn't have keys of rows.
+	return loadLightLOF(r)
+}
+
+func loadLightLOFFormatV2(r io.Reader) (*LightLOF, error) {
+	return loadLightLOF(r)
+}
+
+func loadLightLOF(r io.Reader) (*LightLOF, error) {
 	m := lightLOFMsgpack{}
 	dec := codec.NewDecoder(r, anomalyMsgpackHandle)
 	if err := dec.Decode(&m); err != nil {
@@ -156,7 +200,7 @@ func loadLightLOFFormatV1(r io.Reader) (*LightLOF, error) {
 		return nil, err
 	}
 
-	return &LightLOF{
+	l := &LightLOF{
 		nn:     nn,
 		nnNum:  m.NNNum,
 		rnnNum: m.RNNNum,
@@ -164,29 +208,50 @@ func loadLightLOFFormatV1(r io.Reader) (*LightLOF, error) {
 		kdists: m.KDists,
 		lrds:   m.LRDs,
 
+		keys: m.Keys,
+
+		// The loaded model gets a new origin because the same saved model
+		// can be loaded by multiple states.
+		origin: newOrigin(),
+
 		maxSize: m.MaxSize,
 		rg:      rand.New(rand.NewSource(0)),
-	}, nil
+	}
+	if len(l.keys) != len(l.kdists) {
+		l.keys = make([]rowKey, len(l.kdists))
+		for i := range l.keys {
+			l.keys[i] = l.newKey()
+		}
+	}
+	return l, nil
 }
 
 // Add adds a feature vector to a LightLOF model and calculates its score.
 func (l *LightLOF) Add(v FeatureVector) (score float32, err error) {
-	nnfv, err := v.toNNFV()
+	_, score, err = l.AddWithID(v)
+	return score, err
+}
+
+// AddWithID adds a feature vector to a LightLOF model and returns the ID of
+// the row having the vector and its score. The ID may be reused for another
+// vector when the model unlearns the row.
+func (l *LightLOF) AddWithID(v FeatureVector) (id ID, score float32, err error) {
+	nnfv, err := nearest.FeatureVectorFromMap(data.Map(v))
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	l.m.Lock()
 	defer l.m.Unlock()
 
-	id := l.add(nnfv)
+	id = l.add(nnfv)
 	score = l.calcScoreByID(id)
-	return score, nil
+	return id, score, nil
 }
 
 // AddWithoutCalcScore adds a feature vector to a LightLOF model.
 func (l *LightLOF) AddWithoutCalcScore(v FeatureVector) error {
-	nnfv, err := v.toNNFV()
+	nnfv, err := nearest.FeatureVectorFromMap(data.Map(v))
 	if err != nil {
 		return err
 	}
@@ -203,28 +268,39 @@ func (l *LightLOF) add(v nearest.FeatureVector) ID {
 	if len(l.kdists) <= l.maxSize {
 		l.kdists = append(l.kdists, 0)
 		l.lrds = append(l.lrds, 0)
+		l.keys = append(l.keys, l.newKey())
 		nnID = nearest.ID(len(l.kdists))
 	} else {
 		// unlearn
 		nnID = nearest.ID(l.rg.Intn(l.maxSize)) + 1
 		l.kdists[nnID-1] = 0
 		l.lrds[nnID-1] = 0
+		l.keys[nnID-1] = l.newKey()
 	}
 	l.nn.SetRow(nnID, v)
 
 	neighbors := l.nn.NeighborRowFromID(nnID, l.rnnNum)
+	ids := make([]nearest.ID, len(neighbors))
+	for i := range neighbors {
+		ids[i] = neighbors[i].ID
+	}
+	l.updateKDistsAndLRDs(ids)
+
+	return ID(nnID)
+}
 
+// updateKDistsAndLRDs updates k-distances and local reachability densities
+// of rows having ids.
+func (l *LightLOF) updateKDistsAndLRDs(ids []nearest.ID) {
 	nestedNeighbors := map[ID][]nearest.IDist{}
-	for i := range neighbors {
-		nnID := neighbors[i].ID
+	for _, nnID := range ids {
 		id := ID(nnID)
 		nnResult := l.nn.NeighborRowFromID(nnID, l.nnNum)
 		nestedNeighbors[id] = nnResult
 		l.kdists[id-1] = nnResult[len(nnResult)-1].Dist
 	}
 
-	for i := range neighbors {
-		nnID := neighbors[i].ID
+	for _, nnID := range ids {
 		id := ID(nnID)
 		nn := nestedNeighbors[id]
 		var lrd float32 = 1
@@ -242,13 +318,95 @@ func (l *LightLOF) add(v nearest.FeatureVector) ID {
 		}
 		l.lrds[id-1] = lrd
 	}
+}
 
-	return ID(nnID)
+// Mix replaces rows of the model with rows of sources. A row which more than
+// one source has, for example because it was copied by a previous mix, is
+// only taken once, so mixing the same models repeatedly doesn't increase the
+// number of rows. k-distances and local reachability densities of all rows
+// are recalculated afterwards. When the total number of rows exceeds the max
+// size, rows are randomly chosen.
+// Sources must have the same nearest neighbor algorithm and the same number
+// of hash bits as the model. The model itself can be in sources.
+func (l *LightLOF) Mix(sources []*LightLOF) error {
+	if len(sources) == 0 {
+		return errors.New("at least one source is required")
+	}
+
+	type row struct {
+		src *LightLOF
+		id  nearest.ID
+		key rowKey
+	}
+
+	var rows []row
+	seen := map[rowKey]struct{}{}
+	for _, src := range sources {
+		src.m.RLock()
+		for i, k := range src.keys {
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+			rows = append(rows, row{src, nearest.ID(i + 1), k})
+		}
+		src.m.RUnlock()
+	}
+
+	l.m.RLock()
+	nn := nearest.NewEmpty(l.nn)
+	maxSize := l.maxSize
+	l.m.RUnlock()
+
+	if len(rows) > maxSize {
+		l.m.Lock()
+		perm := l.rg.Perm(len(rows))
+		l.m.Unlock()
+		chosen := make([]row, maxSize)
+		for i := range chosen {
+			chosen[i] = rows[perm[i]]
+		}
+		rows = chosen
+	}
+
+	for i, r := range rows {
+		r.src.m.RLock()
+		err := nearest.CopyRow(nn, nearest.ID(i+1), r.src.nn, r.id)
+		r.src.m.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.nn = nn
+	l.kdists = make([]float32, len(rows))
+	l.lrds = make([]float32, len(rows))
+	l.keys = make([]rowKey, len(rows))
+	ids := make([]nearest.ID, len(rows))
+	for i, r := range rows {
+		ids[i] = nearest.ID(i + 1)
+		l.keys[i] = r.key
+	}
+	l.updateKDistsAndLRDs(ids)
+	return nil
+}
+
+// Clear removes all rows from a LightLOF model.
+func (l *LightLOF) Clear() {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.nn = nearest.NewEmpty(l.nn)
+	l.kdists = nil
+	l.lrds = nil
+	l.keys = nil
 }
 
 // CalcScore calculates a score for a feature vector.
 func (l *LightLOF) CalcScore(v FeatureVector) (float32, error) {
-	nnFV, err := v.toNNFV()
+	nnFV, err := nearest.FeatureVectorFromMap(data.Map(v))
 	if err != nil {
 		return 0, err
 	}
@@ -341,17 +499,6 @@ func calcLOF(lrd float32, neighborLRDs []float32) float32 {
 // FeatureVector represents a feature vector.
 type FeatureVector data.Map
 
-func (v FeatureVector) toNNFV() (nearest.FeatureVector, error) {
-	ret := make(nearest.FeatureVector, 0, len(v))
-	err := nested.Flatten(data.Map(v), func(key string, value float32) {
-		ret = append(ret, nearest.FeatureElement{Dim: key, Value: value})
-	})
-	if err != nil {
-		return nil, err
-	}
-	return ret, nil
-}
-
 // ID is an identifier for a point.
 type ID uint32
 
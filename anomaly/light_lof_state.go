@@ -3,13 +3,18 @@ package anomaly
 import (
 	"fmt"
 	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/fvconv"
+	"github.com/zeromberto/jubatus/internal/jubatus"
+	"github.com/zeromberto/jubatus/internal/nearest"
+	"github.com/zeromberto/jubatus/internal/pipeline"
 	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"github.com/zeromberto/jubatus/mix"
+	"github.com/zeromberto/jubatus/normalizer"
 	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
 	"gopkg.in/sensorbee/sensorbee.v0/core"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 	"io"
 	"reflect"
-	"strings"
 )
 
 type anomalyMsgpack struct {
@@ -21,9 +26,20 @@ type anomalyMsgpack struct {
 type lightLOFState struct {
 	lightLOF           *LightLOF
 	featureVectorField string
+
+	// converter converts raw values into feature vectors. Values are used
+	// as feature vectors when it's nil.
+	converter *fvconv.Converter
+
+	// normalizer normalizes feature vectors. They aren't normalized when
+	// it's nil.
+	normalizer *normalizer.Normalizer
 }
 
-var _ core.SavableSharedState = &lightLOFState{}
+var (
+	_ core.SavableSharedState = &lightLOFState{}
+	_ mix.RemoteMixer         = &lightLOFState{}
+)
 
 type lightLOFStateMsgpack struct {
 	_struct            struct{} `codec:",toarray"`
@@ -40,69 +56,58 @@ func (c *LightLOFStateCreator) CreateState(ctx *core.Context, params data.Map) (
 	if err != nil {
 		return nil, err
 	}
-
-	nnAlgoName, err := pluginutil.ExtractParamAsString(params, "nearest_neighbor_algorithm")
-	if err != nil {
-		return nil, err
-	}
-
-	var nnAlgo NNAlgorithm
-	switch strings.ToLower(nnAlgoName) {
-	case "lsh":
-		nnAlgo = LSH
-	case "minhash":
-		nnAlgo = Minhash
-	case "euclid_lsh":
-		nnAlgo = EuclidLSH
-	default:
-		return nil, fmt.Errorf("invalid nearest_neighbor_algorithm: %s", nnAlgoName)
-	}
-
-	hashNum, err := pluginutil.ExtractParamAsInt(params, "hash_num")
-	if err != nil {
-		return nil, err
-	}
-	nnNum, err := pluginutil.ExtractParamAsInt(params, "nearest_neighbor_num")
+	conv, err := fvconv.NewFromParams(params)
 	if err != nil {
 		return nil, err
 	}
-	rnnNum, err := pluginutil.ExtractParamAsInt(params, "reverse_nearest_neighbor_num")
+	norm, err := normalizer.NewFromParams(params)
 	if err != nil {
 		return nil, err
 	}
 
-	unlearn, err := pluginutil.ExtractParamAsStringWithDefault(params, "unlearner", "no")
-	if err != nil {
-		return nil, err
-	}
-	var maxSize int
-	var seed int64
-	switch unlearn {
-	case "no":
-		maxSize = 0
-	case "random":
-		m, err := pluginutil.ExtractParamAsInt(params, "max_size")
+	// The configuration of the model is imported from a model file of the
+	// original Jubatus when jubatus_model parameter is given. See
+	// LoadJubatusLightLOF for details.
+	if _, ok := params["jubatus_model"]; ok {
+		path, err := pluginutil.ExtractParamAsString(params, "jubatus_model")
 		if err != nil {
 			return nil, err
 		}
-		maxSize = int(m)
-
-		seed, err = pluginutil.ExtractParamAsIntWithDefault(params, "seed", 0)
+		f, err := jubatus.ReadFile(path)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("cannot read the Jubatus model: %v", err)
 		}
-	default:
-		return nil, fmt.Errorf("invalid unlearner: %v", unlearn)
+		llof, err := loadJubatusLightLOF(f)
+		if err != nil {
+			return nil, fmt.Errorf("cannot import the Jubatus model: %v", err)
+		}
+		return &lightLOFState{
+			lightLOF:           llof,
+			featureVectorField: fv,
+			converter:          conv,
+			normalizer:         norm,
+		}, nil
+	}
+
+	nnParams, err := nearest.ExtractParams(params)
+	if err != nil {
+		return nil, err
+	}
+	rnnNum, err := pluginutil.ExtractParamAsInt(params, "reverse_nearest_neighbor_num")
+	if err != nil {
+		return nil, err
 	}
 
 	// TODO: check hashNum, nnNum, rnnNum <= INT_MAX
-	llof, err := NewLightLOF(nnAlgo, int(hashNum), int(nnNum), int(rnnNum), maxSize, seed)
+	llof, err := NewLightLOF(nnParams.Algorithm, nnParams.HashNum, nnParams.NNNum, int(rnnNum), nnParams.MaxSize, nnParams.Seed)
 	if err != nil {
 		return nil, err
 	}
 	return &lightLOFState{
 		lightLOF:           llof,
 		featureVectorField: fv,
+		converter:          conv,
+		normalizer:         norm,
 	}, nil
 }
 
@@ -127,6 +132,10 @@ func (c *LightLOFStateCreator) LoadState(ctx *core.Context, r io.Reader, params
 	switch d.FormatVersion {
 	case 1:
 		return loadLightLOFStateFormatV1(ctx, r)
+	case 2:
+		return loadLightLOFStateFormatV2(ctx, r)
+	case 3:
+		return loadLightLOFStateFormatV3(ctx, r)
 	default:
 		return nil, fmt.Errorf("unsupported format version of LightLOFState container: %v", d.FormatVersion)
 	}
@@ -150,6 +159,60 @@ func loadLightLOFStateFormatV1(ctx *core.Context, r io.Reader) (core.SharedState
 	return s, nil
 }
 
+func loadLightLOFStateFormatV2(ctx *core.Context, r io.Reader) (core.SharedState, error) {
+	s := &lightLOFState{}
+
+	var d lightLOFStateMsgpack
+	dec := codec.NewDecoder(r, anomalyMsgpackHandle)
+	if err := dec.Decode(&d); err != nil {
+		return nil, err
+	}
+	s.featureVectorField = d.FeatureVectorField
+
+	conv, err := fvconv.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.converter = conv
+
+	llof, err := LoadLightLOF(r)
+	if err != nil {
+		return nil, err
+	}
+	s.lightLOF = llof
+	return s, nil
+}
+
+func loadLightLOFStateFormatV3(ctx *core.Context, r io.Reader) (core.SharedState, error) {
+	s := &lightLOFState{}
+
+	var d lightLOFStateMsgpack
+	dec := codec.NewDecoder(r, anomalyMsgpackHandle)
+	if err := dec.Decode(&d); err != nil {
+		return nil, err
+	}
+	s.featureVectorField = d.FeatureVectorField
+
+	conv, err := fvconv.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.converter = conv
+
+	norm, err := normalizer.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.normalizer = norm
+
+	llof, err := LoadLightLOF(r)
+	if err != nil {
+		return nil, err
+	}
+	s.lightLOF = llof
+	return s, nil
+}
+
 func (*lightLOFState) Terminate(ctx *core.Context) error {
 	return nil
 }
@@ -159,17 +222,31 @@ func (l *lightLOFState) Write(ctx *core.Context, t *core.Tuple) error {
 	if !ok {
 		return fmt.Errorf("%s field is missing", l.featureVectorField)
 	}
-	fv, err := data.AsMap(vfv)
+	m, err := data.AsMap(vfv)
 	if err != nil {
 		return fmt.Errorf("%s value is not a map: %v", l.featureVectorField, err)
 	}
+	fv, err := l.featureVector(m, true)
+	if err != nil {
+		return err
+	}
 
-	err = l.lightLOF.AddWithoutCalcScore(FeatureVector(fv))
+	err = l.lightLOF.AddWithoutCalcScore(fv)
 	return err
 }
 
+// featureVector converts a value into a feature vector with the converter
+// and the normalizer of the state.
+func (l *lightLOFState) featureVector(v data.Map, training bool) (FeatureVector, error) {
+	fv, err := pipeline.FeatureVector(l.converter, l.normalizer, v, training)
+	if err != nil {
+		return nil, err
+	}
+	return FeatureVector(fv), nil
+}
+
 const (
-	anomalyFormatVersion = 1
+	anomalyFormatVersion = 3
 )
 
 func (l *lightLOFState) Save(ctx *core.Context, w io.Writer, params data.Map) error {
@@ -186,6 +263,12 @@ func (l *lightLOFState) Save(ctx *core.Context, w io.Writer, params data.Map) er
 	}); err != nil {
 		return err
 	}
+	if err := fvconv.Save(w, l.converter); err != nil {
+		return err
+	}
+	if err := normalizer.Save(w, l.normalizer); err != nil {
+		return err
+	}
 	return l.lightLOF.Save(w)
 }
 
@@ -195,7 +278,11 @@ func AddAndGetScore(ctx *core.Context, stateName string, featureVector data.Map)
 		return 0, err
 	}
 
-	score, err := l.lightLOF.Add(FeatureVector(featureVector))
+	fv, err := l.featureVector(featureVector, true)
+	if err != nil {
+		return 0, err
+	}
+	score, err := l.lightLOF.Add(fv)
 	if err != nil {
 		return 0, err
 	}
@@ -209,7 +296,11 @@ func CalcScore(ctx *core.Context, stateName string, featureVector data.Map) (flo
 		return 0, err
 	}
 
-	score, err := l.lightLOF.CalcScore(FeatureVector(featureVector))
+	fv, err := l.featureVector(featureVector, false)
+	if err != nil {
+		return 0, err
+	}
+	score, err := l.lightLOF.CalcScore(fv)
 	if err != nil {
 		return 0, err
 	}
@@ -228,3 +319,23 @@ func lookupLightLOFState(ctx *core.Context, stateName string) (*lightLOFState, e
 	}
 	return nil, fmt.Errorf("state '%v' cannot be converted to lightLOFState", stateName)
 }
+
+// Mix replaces the model of the state with the mixture of models of sources.
+// See LightLOF.Mix for details.
+func (s *lightLOFState) Mix(sources []core.SharedState) error {
+	ls := make([]*LightLOF, len(sources))
+	for i, src := range sources {
+		st, ok := src.(*lightLOFState)
+		if !ok {
+			return fmt.Errorf("source %v isn't a LightLOF state", i)
+		}
+		ls[i] = st.lightLOF
+	}
+	return s.lightLOF.Mix(ls)
+}
+
+// LoadMixSource loads a state saved by Save so that it can be mixed with the
+// state.
+func (s *lightLOFState) LoadMixSource(ctx *core.Context, r io.Reader) (core.SharedState, error) {
+	return (&LightLOFStateCreator{}).LoadState(ctx, r, data.Map{})
+}
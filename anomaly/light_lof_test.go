@@ -0,0 +1,31 @@
+package anomaly
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"math/rand"
+	"testing"
+)
+
+func TestLightLOFManyNeighbors(t *testing.T) {
+	Convey("Given a LightLOF model searching more than 64 neighbors", t, func() {
+		// Searching more than 64 neighbors partially sorts distances
+		// recursively, which once picked the farthest row when only one row
+		// was left to be chosen.
+		l, err := NewLightLOF(LSH, 64, 65, 65, 0, 0)
+		So(err, ShouldBeNil)
+		r := rand.New(rand.NewSource(0))
+		for i := 0; i < 200; i++ {
+			So(l.AddWithoutCalcScore(FeatureVector{
+				"x": data.Float(r.NormFloat64()),
+				"y": data.Float(r.NormFloat64()),
+			}), ShouldBeNil)
+		}
+
+		Convey("the score should be computed from the nearest rows", func() {
+			s, err := l.CalcScore(FeatureVector{"x": data.Float(0), "y": data.Float(0)})
+			So(err, ShouldBeNil)
+			So(s, ShouldAlmostEqual, 1.2399305, 1e-5)
+		})
+	})
+}
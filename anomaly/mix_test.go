@@ -0,0 +1,173 @@
+package anomaly
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/zeromberto/jubatus/mix"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLightLOFMix(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := LightLOFStateCreator{}
+	newLOFState := func(params data.Map) *lightLOFState {
+		p := data.Map{
+			"nearest_neighbor_algorithm":   data.String("euclid_lsh"),
+			"hash_num":                     data.Int(64),
+			"nearest_neighbor_num":         data.Int(5),
+			"reverse_nearest_neighbor_num": data.Int(10),
+		}
+		for k, v := range params {
+			p[k] = v
+		}
+		s, err := c.CreateState(ctx, p)
+		So(err, ShouldBeNil)
+		return s.(*lightLOFState)
+	}
+
+	Convey("Given LightLOF states having rows", t, func() {
+		a := newLOFState(nil)
+		b := newLOFState(nil)
+		for i := 0; i < 20; i++ {
+			So(a.lightLOF.AddWithoutCalcScore(FeatureVector{"x": data.Float(i), "y": data.Float(1)}), ShouldBeNil)
+			So(b.lightLOF.AddWithoutCalcScore(FeatureVector{"x": data.Float(1), "y": data.Float(i)}), ShouldBeNil)
+		}
+
+		Convey("when mixing them", func() {
+			target := newLOFState(nil)
+			So(target.Mix([]core.SharedState{a, b}), ShouldBeNil)
+
+			Convey("the mixed model should have rows of both sources", func() {
+				So(target.lightLOF.kdists, ShouldHaveLength, 40)
+				So(target.lightLOF.lrds, ShouldHaveLength, 40)
+			})
+
+			Convey("data similar to both sources should be normal", func() {
+				sa, err := target.lightLOF.CalcScore(FeatureVector{"x": data.Float(10), "y": data.Float(1)})
+				So(err, ShouldBeNil)
+				sb, err := target.lightLOF.CalcScore(FeatureVector{"x": data.Float(1), "y": data.Float(10)})
+				So(err, ShouldBeNil)
+				So(sa, ShouldBeLessThan, 2)
+				So(sb, ShouldBeLessThan, 2)
+			})
+		})
+
+		Convey("when mixing the same pair of states repeatedly", func() {
+			fv := FeatureVector{"x": data.Float(10), "y": data.Float(1)}
+			So(a.Mix([]core.SharedState{a, b}), ShouldBeNil)
+			So(b.Mix([]core.SharedState{a}), ShouldBeNil)
+			score, err := a.lightLOF.CalcScore(fv)
+			So(err, ShouldBeNil)
+
+			for i := 0; i < 4; i++ {
+				So(a.Mix([]core.SharedState{a, b}), ShouldBeNil)
+				So(b.Mix([]core.SharedState{a, b}), ShouldBeNil)
+			}
+
+			Convey("the number of rows shouldn't increase", func() {
+				So(a.lightLOF.kdists, ShouldHaveLength, 40)
+				So(b.lightLOF.kdists, ShouldHaveLength, 40)
+			})
+
+			Convey("scores shouldn't change", func() {
+				s, err := a.lightLOF.CalcScore(fv)
+				So(err, ShouldBeNil)
+				So(isInf32(s), ShouldBeFalse)
+				So(s, ShouldAlmostEqual, score, 1e-5)
+				s, err = b.lightLOF.CalcScore(fv)
+				So(err, ShouldBeNil)
+				So(s, ShouldAlmostEqual, score, 1e-5)
+			})
+		})
+
+		Convey("when mixing a state loaded from a saved mixed state", func() {
+			So(a.Mix([]core.SharedState{a, b}), ShouldBeNil)
+			buf := bytes.NewBuffer(nil)
+			So(a.Save(ctx, buf, data.Map{}), ShouldBeNil)
+			src, err := a.LoadMixSource(ctx, buf)
+			So(err, ShouldBeNil)
+			So(b.Mix([]core.SharedState{b, src}), ShouldBeNil)
+
+			Convey("rows shouldn't be duplicated", func() {
+				So(b.lightLOF.kdists, ShouldHaveLength, 40)
+			})
+		})
+
+		Convey("when mixing them into a state having a small max size", func() {
+			target := newLOFState(data.Map{
+				"unlearner": data.String("random"),
+				"max_size":  data.Int(30),
+			})
+			So(target.Mix([]core.SharedState{a, b}), ShouldBeNil)
+
+			Convey("the number of rows should be limited", func() {
+				So(target.lightLOF.kdists, ShouldHaveLength, 30)
+			})
+		})
+
+		Convey("when mixing with a state having a different algorithm", func() {
+			m := newLOFState(data.Map{
+				"nearest_neighbor_algorithm": data.String("minhash"),
+			})
+			So(m.lightLOF.AddWithoutCalcScore(FeatureVector{"x": data.Float(1)}), ShouldBeNil)
+			err := a.Mix([]core.SharedState{a, m})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestLightLOFRemoteMix(t *testing.T) {
+	newLOFState := func(ctx *core.Context) *lightLOFState {
+		s, err := (&LightLOFStateCreator{}).CreateState(ctx, data.Map{
+			"nearest_neighbor_algorithm":   data.String("euclid_lsh"),
+			"hash_num":                     data.Int(64),
+			"nearest_neighbor_num":         data.Int(5),
+			"reverse_nearest_neighbor_num": data.Int(10),
+		})
+		So(err, ShouldBeNil)
+		So(ctx.SharedStates.Add("lof", "jubaanomaly_light_lof", s), ShouldBeNil)
+		return s.(*lightLOFState)
+	}
+
+	Convey("Given a local LightLOF state and a peer serving a LightLOF state", t, func() {
+		ctx := core.NewContext(nil)
+		local := newLOFState(ctx)
+		peerCtx := core.NewContext(nil)
+		peer := newLOFState(peerCtx)
+		srv := httptest.NewServer(mix.Handler(peerCtx))
+		defer srv.Close()
+
+		Convey("when mixing them several times while both are trained", func() {
+			for round := 0; round < 4; round++ {
+				for i := 0; i < 5; i++ {
+					x := data.Float(round*5 + i)
+					So(local.lightLOF.AddWithoutCalcScore(FeatureVector{"x": x, "y": data.Float(1)}), ShouldBeNil)
+					So(peer.lightLOF.AddWithoutCalcScore(FeatureVector{"x": data.Float(1), "y": x}), ShouldBeNil)
+				}
+				n, err := mix.MixWithPeers(ctx, "lof", []string{srv.URL})
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 1)
+			}
+
+			Convey("both states should have each trained row once", func() {
+				So(local.lightLOF.kdists, ShouldHaveLength, 40)
+				So(peer.lightLOF.kdists, ShouldHaveLength, 40)
+			})
+
+			Convey("scores of normal data should be finite", func() {
+				for _, s := range []*lightLOFState{local, peer} {
+					score, err := s.lightLOF.CalcScore(FeatureVector{"x": data.Float(10), "y": data.Float(1)})
+					So(err, ShouldBeNil)
+					So(isInf32(score), ShouldBeFalse)
+					So(score, ShouldBeLessThan, 2)
+				}
+			})
+		})
+	})
+}
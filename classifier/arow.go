@@ -9,16 +9,27 @@ import (
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 	"io"
 	"math"
-	"sync"
+	"sort"
 )
 
 // AROW holds a model for classification.
 type AROW struct {
-	model  model
-	intern *intern.Intern
-	m      sync.RWMutex
+	linearModel
 
 	regWeight float32
+
+	// multiLabel is true when the model is trained in one-vs-rest manner.
+	multiLabel bool
+
+	// classWeights has weights scaling the update step of each label.
+	// Labels not in classWeights have weight 1.
+	classWeights map[Label]float32
+
+	// autoClassWeight is true when class weights are computed from
+	// labelCounts so that they're inversely proportional to the frequency
+	// of each label. classWeights is ignored in this mode.
+	autoClassWeight bool
+	labelCounts     map[Label]uint64
 }
 
 // NewAROW creates an AROW model. regWeight means sensitivity for data. When regWeight is large,
@@ -28,17 +39,99 @@ func NewAROW(regWeight float32) (*AROW, error) {
 		return nil, errors.New("regularization weight must be larger than zero")
 	}
 	return &AROW{
-		model:     make(model),
-		regWeight: regWeight,
-		intern:    intern.New(),
+		linearModel: linearModel{model: make(model), intern: intern.New(), withCov: true},
+		regWeight:   regWeight,
+		labelCounts: make(map[Label]uint64),
 	}, nil
 }
 
+// NewMultiLabelAROW creates an AROW model for multi-label classification.
+// The model is trained in one-vs-rest manner, that is, each label has an
+// independent binary classifier. regWeight is same as NewAROW.
+func NewMultiLabelAROW(regWeight float32) (*AROW, error) {
+	a, err := NewAROW(regWeight)
+	if err != nil {
+		return nil, err
+	}
+	a.multiLabel = true
+	return a, nil
+}
+
+// SetClassWeights sets weights scaling the update step of each label. A label
+// having a large weight is learned more aggressively than others, which helps
+// rare labels in imbalanced data. Labels not in ws have weight 1. Weights must
+// be larger than zero. It disables the automatic class weight mode.
+func (a *AROW) SetClassWeights(ws map[Label]float32) error {
+	cws := make(map[Label]float32, len(ws))
+	for l, w := range ws {
+		if w <= 0 {
+			return fmt.Errorf("class weight of %v must be larger than zero", l)
+		}
+		cws[l] = w
+	}
+
+	a.m.Lock()
+	defer a.m.Unlock()
+	a.classWeights = cws
+	a.autoClassWeight = false
+	return nil
+}
+
+// EnableAutoClassWeight enables the automatic class weight mode. In this
+// mode, the weight of each label is computed as n / (k * n_l), where n is the
+// number of trained labels, k is the number of distinct labels, and n_l is
+// the number of times the label was trained.
+func (a *AROW) EnableAutoClassWeight() {
+	a.m.Lock()
+	defer a.m.Unlock()
+	a.autoClassWeight = true
+}
+
+// ClassWeights returns class weights set by SetClassWeights.
+func (a *AROW) ClassWeights() map[Label]float32 {
+	a.m.RLock()
+	defer a.m.RUnlock()
+	ws := make(map[Label]float32, len(a.classWeights))
+	for l, w := range a.classWeights {
+		ws[l] = w
+	}
+	return ws
+}
+
+// AutoClassWeight returns true when the automatic class weight mode is enabled.
+func (a *AROW) AutoClassWeight() bool {
+	a.m.RLock()
+	defer a.m.RUnlock()
+	return a.autoClassWeight
+}
+
+// classWeight returns the weight of the label. It requires read lock.
+func (a *AROW) classWeight(label Label) float32 {
+	if a.autoClassWeight {
+		c := a.labelCounts[label]
+		if c == 0 {
+			return 1
+		}
+		var total uint64
+		for _, n := range a.labelCounts {
+			total += n
+		}
+		return float32(total) / (float32(len(a.labelCounts)) * float32(c))
+	}
+	if w, ok := a.classWeights[label]; ok {
+		return w
+	}
+	return 1
+}
+
 // Train trains a model with a feature vector and a label.
 func (a *AROW) Train(v FeatureVector, label Label) error {
 	if label == "" {
 		return errors.New("label must not be empty")
 	}
+	if a.multiLabel {
+		return a.TrainMultiLabel(v, []Label{label})
+	}
 
 	a.m.Lock()
 	defer a.m.Unlock()
@@ -51,6 +144,7 @@ func (a *AROW) Train(v FeatureVector, label Label) error {
 	if err != nil {
 		return err
 	}
+	a.labelCounts[label]++
 	scores := a.model.scores(fvForScores)
 	incorr, _ := scores.maxExcept(label)
 	margin := scores.margin(label, incorr)
@@ -62,7 +156,7 @@ func (a *AROW) Train(v FeatureVector, label Label) error {
 	variance := variance(fvFull, a.model[label], a.model[incorr])
 
 	beta := 1 / (variance + 1/a.regWeight)
-	alpha := (1 + margin) * beta
+	alpha := (1 + margin) * beta * a.classWeight(label)
 
 	var incorrWeights weights
 	if incorr != "" {
@@ -84,32 +178,127 @@ func (a *AROW) Train(v FeatureVector, label Label) error {
 	return nil
 }
 
-// Classify classifies a feature vector. This function returns
-// all labels and scores.
-func (a *AROW) Classify(v FeatureVector) (LScores, error) {
-	a.m.RLock()
-	defer a.m.RUnlock()
-	intfv, err := v.toInternalForScores(a.intern)
+// TrainMultiLabel trains a model with a feature vector and labels which the
+// vector has. Each label's weights are updated so that the score of the label
+// becomes positive when the label is in labels and negative otherwise. The
+// model must be created by NewMultiLabelAROW.
+func (a *AROW) TrainMultiLabel(v FeatureVector, labels []Label) error {
+	if !a.multiLabel {
+		return errors.New("AROW isn't for multi-label classification")
+	}
+	if len(labels) == 0 {
+		return errors.New("labels must not be empty")
+	}
+	positive := make(map[Label]struct{}, len(labels))
+	for _, l := range labels {
+		if l == "" {
+			return errors.New("label must not be empty")
+		}
+		positive[l] = struct{}{}
+	}
+
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	for l := range positive {
+		if _, ok := a.model[l]; !ok {
+			a.model[l] = make(weights)
+		}
+	}
+
+	fvForScores, fvFull, err := v.toInternal(a.intern)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	for l := range positive {
+		a.labelCounts[l]++
+	}
+	scores := a.model.scores(fvForScores)
+
+	for l, ws := range a.model {
+		var y float32 = -1
+		if _, ok := positive[l]; ok {
+			y = 1
+		}
+		margin := y * scores.score(l)
+		if margin >= 1 {
+			continue
+		}
+
+		var variance float32
+		for _, elem := range fvFull {
+			variance += ws.covariance(elem.dim) * elem.value * elem.value
+		}
+		beta := 1 / (variance + 1/a.regWeight)
+		alpha := (1 - margin) * beta
+		if y > 0 {
+			alpha *= a.classWeight(l)
+		}
+
+		for _, elem := range fvFull {
+			if y > 0 {
+				ws.positiveUpdate(alpha, beta, elem.dim, elem.value)
+			} else {
+				ws.negativeUpdate(alpha, beta, elem.dim, elem.value)
+			}
+		}
 	}
-	scores := a.model.scores(intfv)
-	return scores, nil
+	return nil
 }
 
-// Clear clears a model.
+// Clear clears a model and the number of times each label was trained.
 func (a *AROW) Clear() {
 	a.m.Lock()
 	defer a.m.Unlock()
-	a.model = make(model)
-	a.intern = intern.New()
+	a.clear()
+	a.labelCounts = make(map[Label]uint64)
+}
+
+// DeleteLabel deletes a label, its weights and the number of times it was
+// trained. It returns false when the model doesn't have the label.
+func (a *AROW) DeleteLabel(label Label) bool {
+	a.m.Lock()
+	defer a.m.Unlock()
+	delete(a.labelCounts, label)
+	return a.model.deleteLabel(label)
+}
+
+func (a *AROW) mixSnapshot() *mixSnapshot {
+	s := a.linearModel.mixSnapshot()
+	s.labelCounts = make(map[Label]uint64, len(a.labelCounts))
+	for l, n := range a.labelCounts {
+		s.labelCounts[l] = n
+	}
+	return s
+}
+
+func (a *AROW) setMixed(m model, in *intern.Intern, labelCounts map[Label]uint64) {
+	a.linearModel.setMixed(m, in, labelCounts)
+	a.labelCounts = labelCounts
 }
 
 var (
-	arowFormatVersion uint8 = 1
+	arowFormatVersion uint8 = 3
 )
 
 type arowMsgpack struct {
+	_struct         struct{} `codec:",toarray"`
+	Model           model
+	RegWeight       float32
+	MultiLabel      bool
+	ClassWeights    map[Label]float32
+	AutoClassWeight bool
+	LabelCounts     map[Label]uint64
+}
+
+type arowMsgpackV2 struct {
+	_struct    struct{} `codec:",toarray"`
+	Model      model
+	RegWeight  float32
+	MultiLabel bool
+}
+
+type arowMsgpackV1 struct {
 	_struct   struct{} `codec:",toarray"`
 	Model     model
 	RegWeight float32
@@ -126,8 +315,12 @@ func (a *AROW) Save(w io.Writer) error {
 
 	enc := codec.NewEncoder(w, classifierMsgpackHandle)
 	if err := enc.Encode(&arowMsgpack{
-		Model:     a.model,
-		RegWeight: a.regWeight,
+		Model:           a.model,
+		RegWeight:       a.regWeight,
+		MultiLabel:      a.multiLabel,
+		ClassWeights:    a.classWeights,
+		AutoClassWeight: a.autoClassWeight,
+		LabelCounts:     a.labelCounts,
 	}); err != nil {
 		return err
 	}
@@ -147,12 +340,53 @@ func LoadAROW(r io.Reader) (*AROW, error) {
 	switch formatVersion[0] {
 	case 1:
 		return loadAROWFormatV1(r)
+	case 2:
+		return loadAROWFormatV2(r)
+	case 3:
+		return loadAROWFormatV3(r)
 	default:
 		return nil, fmt.Errorf("unsupported format version of AROW container: %v", formatVersion[0])
 	}
 }
 
 func loadAROWFormatV1(r io.Reader) (*AROW, error) {
+	m := arowMsgpackV1{}
+	dec := codec.NewDecoder(r, classifierMsgpackHandle)
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	i, err := intern.Load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AROW{
+		linearModel: linearModel{model: m.Model, intern: i, withCov: true},
+		regWeight:   m.RegWeight,
+		labelCounts: make(map[Label]uint64),
+	}, nil
+}
+
+func loadAROWFormatV2(r io.Reader) (*AROW, error) {
+	m := arowMsgpackV2{}
+	dec := codec.NewDecoder(r, classifierMsgpackHandle)
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	i, err := intern.Load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AROW{
+		linearModel: linearModel{model: m.Model, intern: i, withCov: true},
+		regWeight:   m.RegWeight,
+		multiLabel:  m.MultiLabel,
+		labelCounts: make(map[Label]uint64),
+	}, nil
+}
+
+func loadAROWFormatV3(r io.Reader) (*AROW, error) {
 	m := arowMsgpack{}
 	dec := codec.NewDecoder(r, classifierMsgpackHandle)
 	if err := dec.Decode(&m); err != nil {
@@ -163,10 +397,17 @@ func loadAROWFormatV1(r io.Reader) (*AROW, error) {
 		return nil, err
 	}
 
+	lc := m.LabelCounts
+	if lc == nil {
+		lc = make(map[Label]uint64)
+	}
 	return &AROW{
-		model:     m.Model,
-		intern:    i,
-		regWeight: m.RegWeight,
+		linearModel:     linearModel{model: m.Model, intern: i, withCov: true},
+		regWeight:       m.RegWeight,
+		multiLabel:      m.MultiLabel,
+		classWeights:    m.ClassWeights,
+		autoClassWeight: m.AutoClassWeight,
+		labelCounts:     lc,
 	}, nil
 }
 
@@ -175,6 +416,11 @@ func (a *AROW) RegWeight() float32 {
 	return a.regWeight
 }
 
+// MultiLabel returns true when the model is for multi-label classification.
+func (a *AROW) MultiLabel() bool {
+	return a.multiLabel
+}
+
 // FeatureVector is a type for feature vectors.
 type FeatureVector data.Map
 
@@ -250,6 +496,15 @@ func (ws weights) update(alpha, beta float32, dim dim, x float32, f weightUpdate
 	ws[dim] = weight
 }
 
+// get returns the weight of dim. It returns the initial weight when dim
+// doesn't have a weight yet.
+func (ws weights) get(dim dim) weight {
+	if w, ok := ws[dim]; ok {
+		return w
+	}
+	return initialWeight()
+}
+
 type weightUpdateFunction func(w *weight, alpha, beta, x float32)
 
 func (w *weight) negativeUpdate(alpha, beta, x float32) {
@@ -307,6 +562,36 @@ func (s LScores) maxExcept(except Label) (label Label, score float32) {
 	return
 }
 
+// sortedLabels returns labels sorted in descending order of scores. Labels
+// having the same score are sorted in lexicographical order.
+func (s LScores) sortedLabels() []Label {
+	ls := make([]Label, 0, len(s))
+	for l := range s {
+		ls = append(ls, Label(l))
+	}
+	sort.Sort(labelsByScore{ls, s})
+	return ls
+}
+
+type labelsByScore struct {
+	labels []Label
+	scores LScores
+}
+
+func (s labelsByScore) Len() int {
+	return len(s.labels)
+}
+
+func (s labelsByScore) Less(i, j int) bool {
+	si := s.scores.score(s.labels[i])
+	sj := s.scores.score(s.labels[j])
+	return si > sj || (si == sj && s.labels[i] < s.labels[j])
+}
+
+func (s labelsByScore) Swap(i, j int) {
+	s.labels[i], s.labels[j] = s.labels[j], s.labels[i]
+}
+
 func (s LScores) margin(correct Label, incorrect Label) float32 {
 	return s.score(incorrect) - s.score(correct)
 }
@@ -315,15 +600,26 @@ func (s LScores) margin(correct Label, incorrect Label) float32 {
 func (s model) scores(v fVectorForScores) LScores {
 	scores := make(LScores)
 	for l, w := range s {
-		var score float32
-		for _, x := range v {
-			score += x.value * w[x.dim].Weight
-		}
-		scores[string(l)] = data.Float(score)
+		scores[string(l)] = data.Float(w.score(v, nil))
 	}
 	return scores
 }
 
+// score computes the score of a feature vector. When contribute isn't nil,
+// it's called with the index of each element of v and its weight. The score
+// is the sum of value * weight of all elements.
+func (ws weights) score(v fVectorForScores, contribute func(i int, weight float32)) float32 {
+	var score float32
+	for i, x := range v {
+		w := ws[x.dim].Weight
+		if contribute != nil {
+			contribute(i, w)
+		}
+		score += x.value * w
+	}
+	return score
+}
+
 func variance(v fVector, w1, w2 weights) float32 {
 	var variance float32
 	for _, elem := range v {
@@ -3,53 +3,42 @@ package classifier
 import (
 	"errors"
 	"fmt"
-	"github.com/ugorji/go/codec"
 	"github.com/zeromberto/jubatus/internal/pluginutil"
 	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
 	"gopkg.in/sensorbee/sensorbee.v0/core"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 	"io"
-	"math"
-	"reflect"
 )
 
-// classfierMsgpack has information of the saved file.
-type classifierMsgpack struct {
-	_struct   struct{} `codec:",toarray"`
-	Algorithm string
-}
-
-// AROWState is a state which support AROW classification algorithm.
-type AROWState struct {
-	arow               *AROW
-	labelField         string
-	featureVectorField string
-}
+// AROWState is a state having a classifier.
+//
+// Deprecated: AROWState is kept for compatibility. Use State, which can hold
+// a model of any classification algorithm.
+type AROWState = State
 
-var _ core.SavableSharedState = &AROWState{}
-
-type arowStateMsgpack struct {
-	_struct            struct{} `codec:",toarray"`
-	LabelField         string
-	FeatureVectorField string
+// AROWClassify classifies the input using the given model having stateName.
+//
+// Deprecated: AROWClassify is kept for compatibility. Use Classify.
+func AROWClassify(ctx *core.Context, stateName string, featureVector data.Map) (data.Map, error) {
+	return Classify(ctx, stateName, featureVector)
 }
 
-// AROWStateCreator is used by BQL to create or load AROWState as a UDS.
+// AROWStateCreator is used by BQL to create or load a State having AROW
+// as a UDS.
 type AROWStateCreator struct {
 }
 
 var _ udf.UDSLoader = &AROWStateCreator{}
 
-// CreateState creates a new state for AROW classifier.
+// CreateState creates a new state for AROW classifier. When jubatus_model
+// parameter is given, the model is imported from the model file of the
+// original Jubatus having the path and regularization_weight parameter isn't
+// used.
 func (c *AROWStateCreator) CreateState(ctx *core.Context, params data.Map) (core.SharedState, error) {
-	label, err := pluginutil.ExtractParamAsStringWithDefault(params, "label_field", "label")
-	if err != nil {
-		return nil, err
-	}
-	fv, err := pluginutil.ExtractParamAsStringWithDefault(params, "feature_vector_field", "feature_vector")
-	if err != nil {
-		return nil, err
+	if _, ok := params["jubatus_model"]; ok {
+		return createAROWStateFromJubatus(params)
 	}
+
 	rw, err := pluginutil.ExtractParamAndConvertToFloat(params, "regularization_weight")
 	if err != nil {
 		return nil, err
@@ -58,206 +47,62 @@ func (c *AROWStateCreator) CreateState(ctx *core.Context, params data.Map) (core
 		return nil, errors.New("regularization_weight parameter must be greater than zero")
 	}
 
-	a, err := NewAROW(float32(rw))
+	multiLabel, err := pluginutil.ExtractParamAsBoolWithDefault(params, "multi_label", false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize AROW: %v", err)
-	}
-
-	return &AROWState{
-		arow:               a,
-		labelField:         label,
-		featureVectorField: fv,
-	}, nil
-}
-
-var (
-	classifierMsgpackHandle = &codec.MsgpackHandle{
-		RawToString: true,
-	}
-)
-
-func init() {
-	classifierMsgpackHandle.MapType = reflect.TypeOf(map[string]interface{}{})
-}
-
-// LoadState loads a new state for AROW classifier.
-func (c *AROWStateCreator) LoadState(ctx *core.Context, r io.Reader, params data.Map) (core.SharedState, error) {
-	formatVersion := make([]byte, 1)
-	if _, err := r.Read(formatVersion); err != nil {
 		return nil, err
 	}
 
-	switch formatVersion[0] {
-	case 1:
-		return loadAROWStateFormatV1(ctx, r)
-	default:
-		return nil, fmt.Errorf("unsupported format version of AROWState container: %v", formatVersion[0])
-	}
-}
-
-func loadAROWStateFormatV1(ctx *core.Context, r io.Reader) (core.SharedState, error) {
-	var header classifierMsgpack
-	dec := codec.NewDecoder(r, classifierMsgpackHandle)
-	if err := dec.Decode(&header); err != nil {
-		return nil, err
-	}
-	if header.Algorithm != "arow" {
-		return nil, fmt.Errorf("unsupported classification algorithm: %v", header.Algorithm)
-	}
-
-	// This is the current format and no data type conversion is required.
-	s := &AROWState{}
-
-	var d arowStateMsgpack
-	if err := dec.Decode(&d); err != nil {
-		return nil, err
-	}
-	s.labelField = d.LabelField
-	s.featureVectorField = d.FeatureVectorField
-
-	arow, err := LoadAROW(r)
-	if err != nil {
-		return nil, err
-	}
-	s.arow = arow
-	return s, nil
-}
-
-// Terminate terminates the state.
-func (*AROWState) Terminate(ctx *core.Context) error {
-	return nil
-}
-
-// Write trains the machine learning model the state has with a given tuple.
-func (a *AROWState) Write(ctx *core.Context, t *core.Tuple) error {
-	vlabel, ok := t.Data[a.labelField]
-	if !ok {
-		return fmt.Errorf("%s field is missing", a.labelField)
-	}
-	label, err := data.AsString(vlabel)
-	if err != nil {
-		return fmt.Errorf("%s value is not a string: %v", a.labelField, err)
-	}
-
-	vfv, ok := t.Data[a.featureVectorField]
-	if !ok {
-		return fmt.Errorf("%s field is missing", a.featureVectorField)
+	var a *AROW
+	if multiLabel {
+		a, err = NewMultiLabelAROW(float32(rw))
+	} else {
+		a, err = NewAROW(float32(rw))
 	}
-	fv, err := data.AsMap(vfv)
 	if err != nil {
-		return fmt.Errorf("%s value is not a map: %v", a.labelField, err)
-	}
-
-	err = a.train(fv, label)
-	return err
-}
-
-func (a *AROWState) train(fv data.Map, l string) error {
-	return a.arow.Train(FeatureVector(fv), Label(l))
-}
-
-const (
-	classifierFormatVersion uint8 = 1
-)
-
-// Save is provided as a part of core.SavableSharedState.
-func (a *AROWState) Save(ctx *core.Context, w io.Writer, params data.Map) error {
-	if _, err := w.Write([]byte{classifierFormatVersion}); err != nil {
-		return err
-	}
-
-	// This is the format version of the root container and doesn't related to
-	// how each algorithm is saved.
-	enc := codec.NewEncoder(w, classifierMsgpackHandle)
-	if err := enc.Encode(&classifierMsgpack{
-		Algorithm: "arow",
-	}); err != nil {
-		return err
-	}
-
-	if err := enc.Encode(&arowStateMsgpack{
-		LabelField:         a.labelField,
-		FeatureVectorField: a.featureVectorField,
-	}); err != nil {
-		return err
+		return nil, fmt.Errorf("failed to initialize AROW: %v", err)
 	}
-	return a.arow.Save(w)
-}
-
-// AROWClassify classifies the input using the given model having stateName.
-func AROWClassify(ctx *core.Context, stateName string, featureVector data.Map) (data.Map, error) {
-	s, err := lookupAROWState(ctx, stateName)
-	if err != nil {
+	if err := setAROWClassWeights(a, params); err != nil {
 		return nil, err
 	}
-
-	scores, err := s.arow.Classify(FeatureVector(featureVector))
-	return data.Map(scores), err
+	return newState(params, "arow", a)
 }
 
-func lookupAROWState(ctx *core.Context, stateName string) (*AROWState, error) {
-	st, err := ctx.SharedStates.Get(stateName)
-	if err != nil {
-		return nil, err
-	}
-
-	if s, ok := st.(*AROWState); ok {
-		return s, nil
-	}
-	return nil, fmt.Errorf("state '%v' isn't an AROWState", stateName)
-}
-
-// ClassifiedLabel returns the label having the highest score in a
-// classification result.
-func ClassifiedLabel(scores data.Map) (string, error) {
-	if len(scores) == 0 {
-		return "", errors.New("attempt to get a label from an empty map")
-	}
-
-	// LScores.Max() cannot be used here because scores is passed by a user.
-	// LScores.Max() expects all values are float.
-	l, _, err := maxLabelScore(scores)
-	if err != nil {
-		return "", err
-	}
-	return l, nil
-}
-
-// ClassifiedScore returns the highest score in a classification result.
-func ClassifiedScore(scores data.Map) (float64, error) {
-	if len(scores) == 0 {
-		return 0, errors.New("attempt to get a score from an empty map")
-	}
-
-	_, s, err := maxLabelScore(scores)
-	if err != nil {
-		return 0, err
-	}
-	return s, nil
-}
-
-// maxLabelScore returns the max score and its label in a data.Map.
-// This function are same as LScores.Max() except error checking.
-func maxLabelScore(scores data.Map) (label string, score float64, err error) {
-	if len(scores) == 0 {
-		err = errors.New("attempt to find a max score from an empty map")
-		return "", 0, err
+// setAROWClassWeights sets class weights given by class_weights parameter.
+// The parameter is either a map from labels to weights or "auto", which
+// enables the automatic inverse-frequency mode.
+func setAROWClassWeights(a *AROW, params data.Map) error {
+	v, ok := params["class_weights"]
+	if !ok {
+		return nil
 	}
 
-	score = minusInf
-	for l, s := range scores {
-		sc, err := data.AsFloat(s)
-		if err != nil {
-			err = fmt.Errorf("score for %s is not a float: %v", l, err)
-			return "", 0, err
+	switch v.Type() {
+	case data.TypeString:
+		s, _ := data.AsString(v)
+		if s != "auto" {
+			return fmt.Errorf("class_weights parameter must be a map or \"auto\": %v", s)
 		}
-		if sc > score {
-			label = l
-			score = sc
+		a.EnableAutoClassWeight()
+		return nil
+
+	case data.TypeMap:
+		m, _ := data.AsMap(v)
+		ws := make(map[Label]float32, len(m))
+		for l, vw := range m {
+			w, err := data.ToFloat(vw)
+			if err != nil {
+				return fmt.Errorf("class weight of %v cannot be converted to float: %v", l, err)
+			}
+			ws[Label(l)] = float32(w)
 		}
-	}
+		return a.SetClassWeights(ws)
 
-	return label, score, nil
+	default:
+		return fmt.Errorf("class_weights parameter must be a map or \"auto\": %v", v)
+	}
 }
 
-var minusInf = math.Inf(-1)
+// LoadState loads a new state for AROW classifier.
+func (c *AROWStateCreator) LoadState(ctx *core.Context, r io.Reader, params data.Map) (core.SharedState, error) {
+	return loadState(ctx, r, "arow")
+}
@@ -2,6 +2,7 @@ package classifier
 
 import (
 	"bytes"
+	"fmt"
 	. "github.com/smartystreets/goconvey/convey"
 	"gopkg.in/sensorbee/sensorbee.v0/core"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
@@ -17,7 +18,7 @@ func TestAROWStateSaveLoad(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	a := as.(*AROWState)
+	a := as.(*State)
 
 	labels := []data.String{"a", "b", "c", "d"}
 	for i := 0; i < 100; i++ {
@@ -33,7 +34,7 @@ func TestAROWStateSaveLoad(t *testing.T) {
 		}
 	}
 
-	Convey("Given a trained AROWState", t, func() {
+	Convey("Given a trained State having AROW", t, func() {
 		Convey("when saving it", func() {
 			buf := bytes.NewBuffer(nil)
 			err := a.Save(ctx, buf, data.Map{})
@@ -50,9 +51,9 @@ func TestAROWStateSaveLoad(t *testing.T) {
 					So(a2, ShouldResemble, a)
 
 					fv := FeatureVector(data.Map{"n": data.Int(10)})
-					s, err := a.arow.Classify(fv)
+					s, err := a.classifier.Classify(fv)
 					So(err, ShouldBeNil)
-					s2, err := a2.(*AROWState).arow.Classify(fv)
+					s2, err := a2.(*State).classifier.Classify(fv)
 					So(err, ShouldBeNil)
 					So(s2, ShouldResemble, s)
 				})
@@ -60,3 +61,122 @@ func TestAROWStateSaveLoad(t *testing.T) {
 		})
 	})
 }
+
+func TestAROWStateClassWeights(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := AROWStateCreator{}
+
+	Convey("Given AROWStateCreator", t, func() {
+		Convey("when creating a state with a map of class weights", func() {
+			s, err := c.CreateState(ctx, data.Map{
+				"regularization_weight": data.Float(1),
+				"class_weights": data.Map{
+					"a": data.Float(2),
+					"b": data.Int(3),
+				},
+			})
+			So(err, ShouldBeNil)
+			a := s.(*State).classifier.(*AROW)
+
+			Convey("the model should have the weights", func() {
+				So(a.ClassWeights(), ShouldResemble, map[Label]float32{"a": 2, "b": 3})
+				So(a.AutoClassWeight(), ShouldBeFalse)
+			})
+
+			Convey("the weights should be saved", func() {
+				So(s.(*State).Write(ctx, &core.Tuple{Data: data.Map{
+					"label":          data.String("a"),
+					"feature_vector": data.Map{"x": data.Float(1)},
+				}}), ShouldBeNil)
+
+				buf := bytes.NewBuffer(nil)
+				So(s.(*State).Save(ctx, buf, data.Map{}), ShouldBeNil)
+				s2, err := c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				So(s2, ShouldResemble, s)
+			})
+		})
+
+		Convey("when creating a state with automatic class weights", func() {
+			s, err := c.CreateState(ctx, data.Map{
+				"regularization_weight": data.Float(1),
+				"class_weights":         data.String("auto"),
+			})
+			So(err, ShouldBeNil)
+			a := s.(*State).classifier.(*AROW)
+
+			Convey("the model should enable the automatic mode", func() {
+				So(a.AutoClassWeight(), ShouldBeTrue)
+			})
+
+			Convey("label counts should be saved", func() {
+				for _, l := range []string{"a", "a", "b"} {
+					So(s.(*State).Write(ctx, &core.Tuple{Data: data.Map{
+						"label":          data.String(l),
+						"feature_vector": data.Map{"x": data.Float(1)},
+					}}), ShouldBeNil)
+				}
+
+				buf := bytes.NewBuffer(nil)
+				So(s.(*State).Save(ctx, buf, data.Map{}), ShouldBeNil)
+				s2, err := c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				a2 := s2.(*State).classifier.(*AROW)
+				So(a2.AutoClassWeight(), ShouldBeTrue)
+				So(a2.labelCounts, ShouldResemble, map[Label]uint64{"a": 2, "b": 1})
+			})
+		})
+
+		Convey("when creating a state with invalid class weights", func() {
+			for _, cw := range []data.Value{
+				data.String("manual"),
+				data.Int(1),
+				data.Map{"a": data.String("x")},
+				data.Map{"a": data.Float(-1)},
+			} {
+				_, err := c.CreateState(ctx, data.Map{
+					"regularization_weight": data.Float(1),
+					"class_weights":         cw,
+				})
+
+				Convey(fmt.Sprintf("it should fail with %v", cw), func() {
+					So(err, ShouldNotBeNil)
+				})
+			}
+		})
+	})
+}
+
+func TestAROWClassify(t *testing.T) {
+	ctx := core.NewContext(nil)
+	s, err := (&AROWStateCreator{}).CreateState(ctx, data.Map{
+		"regularization_weight": data.Float(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SharedStates.Add("arow", "jubaclassifier_arow", s); err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Given an AROW state trained by code using AROWState", t, func() {
+		a, ok := s.(*AROWState)
+		So(ok, ShouldBeTrue)
+		So(a.Write(ctx, &core.Tuple{Data: data.Map{
+			"label":          data.String("a"),
+			"feature_vector": data.Map{"x": data.Float(1)},
+		}}), ShouldBeNil)
+
+		Convey("when classifying with AROWClassify", func() {
+			fv := data.Map{"x": data.Float(1)}
+			scores, err := AROWClassify(ctx, "arow", fv)
+			So(err, ShouldBeNil)
+
+			Convey("it should return the same result as Classify", func() {
+				expected, err := Classify(ctx, "arow", fv)
+				So(err, ShouldBeNil)
+				So(scores, ShouldResemble, expected)
+			})
+		})
+	})
+}
@@ -2,8 +2,10 @@ package classifier
 
 import (
 	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 	"math/rand"
+	"testing"
 )
 
 type shogun struct {
@@ -11,6 +13,23 @@ type shogun struct {
 	given  string
 }
 
+var shogunList = []shogun{
+	{"徳川", "家康"}, {"徳川", "秀忠"}, {"徳川", "家光"}, {"徳川", "家綱"},
+	{"徳川", "綱吉"}, {"徳川", "家宣"}, {"徳川", "家継"}, {"徳川", "吉宗"},
+	{"徳川", "家重"}, {"徳川", "家治"}, {"徳川", "家斉"}, {"徳川", "家慶"},
+	{"徳川", "家定"}, {"徳川", "家茂"},
+
+	{"足利", "尊氏"}, {"足利", "義詮"}, {"足利", "義満"}, {"足利", "義持"},
+	{"足利", "義量"}, {"足利", "義教"}, {"足利", "義勝"}, {"足利", "義政"},
+	{"足利", "義尚"}, {"足利", "義稙"}, {"足利", "義澄"}, {"足利", "義稙"},
+	{"足利", "義晴"}, {"足利", "義輝"}, {"足利", "義栄"},
+
+	{"北条", "時政"}, {"北条", "義時"}, {"北条", "泰時"}, {"北条", "経時"},
+	{"北条", "時頼"}, {"北条", "長時"}, {"北条", "政村"}, {"北条", "時宗"},
+	{"北条", "貞時"}, {"北条", "師時"}, {"北条", "宗宣"}, {"北条", "煕時"},
+	{"北条", "基時"}, {"北条", "高時"}, {"北条", "貞顕"},
+}
+
 func unigram(s string) FeatureVector {
 	fv := make(FeatureVector)
 	for _, r := range s {
@@ -20,23 +39,6 @@ func unigram(s string) FeatureVector {
 }
 
 func Example() {
-	shogunList := []shogun{
-		{"徳川", "家康"}, {"徳川", "秀忠"}, {"徳川", "家光"}, {"徳川", "家綱"},
-		{"徳川", "綱吉"}, {"徳川", "家宣"}, {"徳川", "家継"}, {"徳川", "吉宗"},
-		{"徳川", "家重"}, {"徳川", "家治"}, {"徳川", "家斉"}, {"徳川", "家慶"},
-		{"徳川", "家定"}, {"徳川", "家茂"},
-
-		{"足利", "尊氏"}, {"足利", "義詮"}, {"足利", "義満"}, {"足利", "義持"},
-		{"足利", "義量"}, {"足利", "義教"}, {"足利", "義勝"}, {"足利", "義政"},
-		{"足利", "義尚"}, {"足利", "義稙"}, {"足利", "義澄"}, {"足利", "義稙"},
-		{"足利", "義晴"}, {"足利", "義輝"}, {"足利", "義栄"},
-
-		{"北条", "時政"}, {"北条", "義時"}, {"北条", "泰時"}, {"北条", "経時"},
-		{"北条", "時頼"}, {"北条", "長時"}, {"北条", "政村"}, {"北条", "時宗"},
-		{"北条", "貞時"}, {"北条", "師時"}, {"北条", "宗宣"}, {"北条", "煕時"},
-		{"北条", "基時"}, {"北条", "高時"}, {"北条", "貞顕"},
-	}
-
 	shuffledShogunList := make([]shogun, len(shogunList))
 	perm := rand.Perm(len(shogunList))
 	for i, v := range perm {
@@ -64,3 +66,113 @@ func Example() {
 	// 足利
 	// 北条
 }
+
+func TestMultiLabelAROW(t *testing.T) {
+	Convey("Given a multi-label AROW", t, func() {
+		a, err := NewMultiLabelAROW(1)
+		So(err, ShouldBeNil)
+		So(a.MultiLabel(), ShouldBeTrue)
+
+		for i := 0; i < 10; i++ {
+			So(a.TrainMultiLabel(FeatureVector{"x": data.Float(1)}, []Label{"x"}), ShouldBeNil)
+			So(a.TrainMultiLabel(FeatureVector{"y": data.Float(1)}, []Label{"y"}), ShouldBeNil)
+			So(a.TrainMultiLabel(FeatureVector{"x": data.Float(1), "y": data.Float(1)}, []Label{"x", "y"}), ShouldBeNil)
+			So(a.TrainMultiLabel(FeatureVector{"z": data.Float(1)}, []Label{"z"}), ShouldBeNil)
+		}
+
+		Convey("when classifying a vector having features of two labels", func() {
+			scores, err := a.Classify(FeatureVector{"x": data.Float(1), "y": data.Float(1)})
+			So(err, ShouldBeNil)
+
+			Convey("both labels should have positive scores", func() {
+				So(scores.score("x"), ShouldBeGreaterThan, 0)
+				So(scores.score("y"), ShouldBeGreaterThan, 0)
+				So(scores.score("z"), ShouldBeLessThan, 0)
+			})
+		})
+
+		Convey("when training it with empty labels", func() {
+			err := a.TrainMultiLabel(FeatureVector{"x": data.Float(1)}, nil)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given a single-label AROW", t, func() {
+		a, err := NewAROW(1)
+		So(err, ShouldBeNil)
+
+		Convey("when training it with multiple labels", func() {
+			err := a.TrainMultiLabel(FeatureVector{"x": data.Float(1)}, []Label{"x", "y"})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestAROWClassWeights(t *testing.T) {
+	train := func(a *AROW) {
+		for i := 0; i < 20; i++ {
+			So(a.Train(FeatureVector{"x": data.Float(1)}, "common"), ShouldBeNil)
+			if i%10 == 0 {
+				So(a.Train(FeatureVector{"x": data.Float(1), "y": data.Float(1)}, "rare"), ShouldBeNil)
+			}
+		}
+	}
+	rareScore := func(a *AROW) float32 {
+		s, err := a.Classify(FeatureVector{"x": data.Float(1), "y": data.Float(1)})
+		So(err, ShouldBeNil)
+		return s.score("rare") - s.score("common")
+	}
+
+	Convey("Given AROW models trained with imbalanced labels", t, func() {
+		plain, err := NewAROW(0.1)
+		So(err, ShouldBeNil)
+		train(plain)
+
+		Convey("when a model has a large weight for the rare label", func() {
+			weighted, err := NewAROW(0.1)
+			So(err, ShouldBeNil)
+			So(weighted.SetClassWeights(map[Label]float32{"rare": 10}), ShouldBeNil)
+			train(weighted)
+
+			Convey("it should prefer the rare label more than the plain model", func() {
+				So(rareScore(weighted), ShouldBeGreaterThan, rareScore(plain))
+			})
+		})
+
+		Convey("when a model uses automatic class weights", func() {
+			auto, err := NewAROW(0.1)
+			So(err, ShouldBeNil)
+			auto.EnableAutoClassWeight()
+			train(auto)
+
+			Convey("weights should be inversely proportional to label counts", func() {
+				So(auto.labelCounts, ShouldResemble, map[Label]uint64{"common": 20, "rare": 2})
+				So(auto.classWeight("common"), ShouldAlmostEqual, 22.0/40, 1e-6)
+				So(auto.classWeight("rare"), ShouldAlmostEqual, 22.0/4, 1e-6)
+			})
+
+			Convey("it should prefer the rare label more than the plain model", func() {
+				So(rareScore(auto), ShouldBeGreaterThan, rareScore(plain))
+			})
+		})
+	})
+
+	Convey("Given an AROW model", t, func() {
+		a, err := NewAROW(1)
+		So(err, ShouldBeNil)
+
+		Convey("when setting a non-positive class weight", func() {
+			err := a.SetClassWeights(map[Label]float32{"a": 0})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
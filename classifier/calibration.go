@@ -0,0 +1,214 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	jubamath "github.com/zeromberto/jubatus/internal/math"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"math"
+	"sync"
+)
+
+const (
+	// InvalidCalibration represents an invalid calibration method.
+	InvalidCalibration Calibration = iota
+	// SoftmaxCalibration converts scores to probabilities by softmax with
+	// temperature.
+	SoftmaxCalibration
+	// SigmoidCalibration converts each score to a probability by a sigmoid
+	// function whose parameters are fitted online (Platt scaling).
+	SigmoidCalibration
+)
+
+// Calibration is an enum type which represents methods to convert scores to
+// probabilities.
+type Calibration int
+
+// calibrator converts scores of a classifier to probabilities.
+type calibrator struct {
+	method       Calibration
+	temperature  float32
+	learningRate float32
+
+	// parameters of the sigmoid function 1 / (1 + exp(-(a*score + b))).
+	sigmoidA float32
+	sigmoidB float32
+
+	m sync.RWMutex
+}
+
+func newDefaultCalibrator() *calibrator {
+	return &calibrator{
+		method:       SoftmaxCalibration,
+		temperature:  1,
+		learningRate: 0.01,
+		sigmoidA:     1,
+	}
+}
+
+// newCalibrator creates a calibrator from parameters of a UDS.
+func newCalibrator(params data.Map) (*calibrator, error) {
+	c := newDefaultCalibrator()
+
+	method, err := pluginutil.ExtractParamAsStringWithDefault(params, "calibration", "softmax")
+	if err != nil {
+		return nil, err
+	}
+	switch method {
+	case "softmax":
+		c.method = SoftmaxCalibration
+	case "sigmoid":
+		c.method = SigmoidCalibration
+	default:
+		return nil, fmt.Errorf("invalid calibration: %v", method)
+	}
+
+	if _, ok := params["temperature"]; ok {
+		t, err := pluginutil.ExtractParamAndConvertToFloat(params, "temperature")
+		if err != nil {
+			return nil, err
+		}
+		if t <= 0 {
+			return nil, errors.New("temperature parameter must be greater than zero")
+		}
+		c.temperature = float32(t)
+	}
+
+	if _, ok := params["calibration_learning_rate"]; ok {
+		lr, err := pluginutil.ExtractParamAndConvertToFloat(params, "calibration_learning_rate")
+		if err != nil {
+			return nil, err
+		}
+		if lr <= 0 {
+			return nil, errors.New("calibration_learning_rate parameter must be greater than zero")
+		}
+		c.learningRate = float32(lr)
+	}
+	return c, nil
+}
+
+// needsTraining returns true when the calibrator has to be trained with
+// scores of training data.
+func (c *calibrator) needsTraining() bool {
+	return c.method == SigmoidCalibration
+}
+
+// train fits parameters of the sigmoid function by a step of stochastic
+// gradient descent on the logistic loss. The correct label is a positive
+// example and the others are negative examples.
+func (c *calibrator) train(scores LScores, correct Label) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for l := range scores {
+		var y float32
+		if Label(l) == correct {
+			y = 1
+		}
+		s := scores.score(Label(l))
+		g := sigmoid(c.sigmoidA*s+c.sigmoidB) - y
+		c.sigmoidA -= c.learningRate * g * s
+		c.sigmoidB -= c.learningRate * g
+	}
+}
+
+// probabilities converts scores to probabilities. Probabilities converted by
+// SigmoidCalibration are computed for each label independently and their sum
+// isn't always one.
+func (c *calibrator) probabilities(scores LScores) (map[Label]float64, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	ret := make(map[Label]float64, len(scores))
+	switch c.method {
+	case SigmoidCalibration:
+		for l := range scores {
+			ret[Label(l)] = float64(sigmoid(c.sigmoidA*scores.score(Label(l)) + c.sigmoidB))
+		}
+
+	default:
+		scaled := make(data.Map, len(scores))
+		for l := range scores {
+			scaled[l] = data.Float(scores.score(Label(l)) / c.temperature)
+		}
+		ps, err := jubamath.Softmax(scaled)
+		if err != nil {
+			return nil, err
+		}
+		for l, p := range ps {
+			f, err := data.AsFloat(p)
+			if err != nil {
+				return nil, err
+			}
+			ret[Label(l)] = f
+		}
+	}
+	return ret, nil
+}
+
+func sigmoid(x float32) float32 {
+	return float32(1 / (1 + math.Exp(-float64(x))))
+}
+
+const (
+	calibratorFormatVersion uint8 = 1
+)
+
+type calibratorMsgpack struct {
+	_struct      struct{} `codec:",toarray"`
+	Method       Calibration
+	Temperature  float32
+	LearningRate float32
+	SigmoidA     float32
+	SigmoidB     float32
+}
+
+func (c *calibrator) save(w io.Writer) error {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	if _, err := w.Write([]byte{calibratorFormatVersion}); err != nil {
+		return err
+	}
+
+	enc := codec.NewEncoder(w, classifierMsgpackHandle)
+	return enc.Encode(&calibratorMsgpack{
+		Method:       c.method,
+		Temperature:  c.temperature,
+		LearningRate: c.learningRate,
+		SigmoidA:     c.sigmoidA,
+		SigmoidB:     c.sigmoidB,
+	})
+}
+
+func loadCalibrator(r io.Reader) (*calibrator, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadCalibratorFormatV1(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of calibrator container: %v", formatVersion[0])
+	}
+}
+
+func loadCalibratorFormatV1(r io.Reader) (*calibrator, error) {
+	var d calibratorMsgpack
+	dec := codec.NewDecoder(r, classifierMsgpackHandle)
+	if err := dec.Decode(&d); err != nil {
+		return nil, err
+	}
+	return &calibrator{
+		method:       d.Method,
+		temperature:  d.Temperature,
+		learningRate: d.LearningRate,
+		sigmoidA:     d.SigmoidA,
+		sigmoidB:     d.SigmoidB,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package classifier
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestCalibrator(t *testing.T) {
+	scores := LScores{
+		"a": data.Float(2),
+		"b": data.Float(1),
+		"c": data.Float(-1),
+	}
+
+	Convey("Given a softmax calibrator", t, func() {
+		c, err := newCalibrator(data.Map{})
+		So(err, ShouldBeNil)
+
+		Convey("when computing probabilities", func() {
+			ps, err := c.probabilities(scores)
+			So(err, ShouldBeNil)
+
+			Convey("they should sum to one", func() {
+				So(ps["a"]+ps["b"]+ps["c"], ShouldAlmostEqual, 1, 1e-6)
+			})
+
+			Convey("they should be ordered as scores", func() {
+				So(ps["a"], ShouldBeGreaterThan, ps["b"])
+				So(ps["b"], ShouldBeGreaterThan, ps["c"])
+			})
+		})
+
+		Convey("when increasing the temperature", func() {
+			hot, err := newCalibrator(data.Map{"temperature": data.Float(10)})
+			So(err, ShouldBeNil)
+
+			Convey("probabilities should be smoothed", func() {
+				ps, err := c.probabilities(scores)
+				So(err, ShouldBeNil)
+				hps, err := hot.probabilities(scores)
+				So(err, ShouldBeNil)
+				So(hps["a"], ShouldBeLessThan, ps["a"])
+				So(hps["c"], ShouldBeGreaterThan, ps["c"])
+			})
+		})
+	})
+
+	Convey("Given a sigmoid calibrator", t, func() {
+		c, err := newCalibrator(data.Map{
+			"calibration":               data.String("sigmoid"),
+			"calibration_learning_rate": data.Float(0.1),
+		})
+		So(err, ShouldBeNil)
+		So(c.needsTraining(), ShouldBeTrue)
+
+		Convey("when training it with scores whose max label is always correct", func() {
+			before, err := c.probabilities(scores)
+			So(err, ShouldBeNil)
+			for i := 0; i < 1000; i++ {
+				c.train(scores, "a")
+			}
+
+			Convey("probabilities should separate the correct label from others", func() {
+				after, err := c.probabilities(scores)
+				So(err, ShouldBeNil)
+				So(after["a"], ShouldBeGreaterThan, 0.5)
+				So(after["b"], ShouldBeLessThan, 0.5)
+				So(after["b"], ShouldBeLessThan, before["b"])
+				So(after["c"], ShouldBeLessThan, before["c"])
+			})
+		})
+	})
+
+	Convey("Given invalid calibration parameters", t, func() {
+		for _, params := range []data.Map{
+			{"calibration": data.String("isotonic")},
+			{"temperature": data.Float(0)},
+			{"calibration_learning_rate": data.Float(-1)},
+		} {
+			_, err := newCalibrator(params)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
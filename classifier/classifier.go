@@ -0,0 +1,100 @@
+package classifier
+
+import (
+	"io"
+)
+
+// Classifier is an interface which all classification algorithms implement.
+type Classifier interface {
+	// Train trains a model with a feature vector and a label.
+	Train(v FeatureVector, label Label) error
+
+	// Classify classifies a feature vector. It returns all labels and
+	// their scores.
+	Classify(v FeatureVector) (LScores, error)
+
+	// Clear clears a model.
+	Clear()
+
+	// Labels returns all labels the model has.
+	Labels() []Label
+
+	// SetLabel registers a label to the model without training. It returns
+	// false when the model already has the label.
+	SetLabel(label Label) (bool, error)
+
+	// DeleteLabel deletes a label and everything learned for it from the
+	// model. It returns false when the model doesn't have the label.
+	DeleteLabel(label Label) bool
+
+	// Save saves the current state of a model. The saved data must be able
+	// to be loaded by the loader function of the algorithm.
+	Save(w io.Writer) error
+}
+
+// MultiLabelClassifier is an interface which classification algorithms
+// supporting multi-label classification implement.
+type MultiLabelClassifier interface {
+	Classifier
+
+	// MultiLabel returns true when the model is for multi-label
+	// classification.
+	MultiLabel() bool
+
+	// TrainMultiLabel trains a model with a feature vector and labels.
+	TrainMultiLabel(v FeatureVector, labels []Label) error
+}
+
+// LinearClassifier is an interface which classification algorithms having
+// a weight vector for each label implement.
+type LinearClassifier interface {
+	Classifier
+
+	// Weights returns weights of all features of the label. It returns
+	// false when the model doesn't have the label.
+	Weights(label Label) ([]FeatureWeight, bool)
+
+	// ActiveDims returns the number of dimensions having a non-zero weight
+	// in any label.
+	ActiveDims() int
+
+	// Contributions returns how much each feature of v contributes to the
+	// score of the label.
+	Contributions(v FeatureVector, label Label) ([]Contribution, error)
+
+	// EnableFeatureHashing makes the model map features to at most maxSize
+	// dimensions by hashing. It must be called before training.
+	EnableFeatureHashing(maxSize int) error
+
+	// HashMaxSize returns the max number of dimensions when feature hashing
+	// is enabled. It returns zero otherwise.
+	HashMaxSize() int
+
+	// Prune removes weights which hardly affect scores and feature names
+	// which no weight refers to. It returns the number of removed weights
+	// and the number of removed feature names.
+	Prune(threshold float32) (int, int)
+}
+
+var (
+	_ MultiLabelClassifier = &AROW{}
+
+	_ LinearClassifier = &AROW{}
+	_ LinearClassifier = &Perceptron{}
+	_ LinearClassifier = &PassiveAggressive{}
+	_ LinearClassifier = &CW{}
+	_ LinearClassifier = &NHERD{}
+
+	_ linearMixer = &AROW{}
+	_ linearMixer = &Perceptron{}
+	_ linearMixer = &PassiveAggressive{}
+	_ linearMixer = &CW{}
+	_ linearMixer = &NHERD{}
+
+	_ Classifier = &AROW{}
+	_ Classifier = &Perceptron{}
+	_ Classifier = &PassiveAggressive{}
+	_ Classifier = &CW{}
+	_ Classifier = &NHERD{}
+	_ Classifier = &NearestNeighbor{}
+)
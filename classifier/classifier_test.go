@@ -0,0 +1,130 @@
+package classifier
+
+import (
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"testing"
+)
+
+// trainShoguns trains c with shogunList shuffled by a fixed seed.
+func trainShoguns(c Classifier, epochs int) {
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < epochs; i++ {
+		for _, j := range r.Perm(len(shogunList)) {
+			s := shogunList[j]
+			So(c.Train(unigram(s.given), Label(s.family)), ShouldBeNil)
+		}
+	}
+}
+
+// shouldClassifyShoguns asserts that c classifies unknown names correctly.
+func shouldClassifyShoguns(c Classifier) {
+	for given, family := range map[string]Label{
+		"慶喜": "徳川",
+		"義昭": "足利",
+		"守時": "北条",
+	} {
+		scores, err := c.Classify(unigram(given))
+		So(err, ShouldBeNil)
+		l, _ := scores.Max()
+		So(l, ShouldEqual, family)
+	}
+}
+
+// newClassifiers creates a classifier of each algorithm.
+func newClassifiers() map[string]Classifier {
+	cs := map[string]Classifier{
+		"perceptron": NewPerceptron(),
+	}
+	cs["arow"], _ = NewAROW(1)
+	cs["pa"], _ = NewPassiveAggressive(PA, 0)
+	cs["pa1"], _ = NewPassiveAggressive(PA1, 1)
+	cs["pa2"], _ = NewPassiveAggressive(PA2, 1)
+	cs["cw"], _ = NewCW(1)
+	cs["nherd"], _ = NewNHERD(1)
+	cs["nearest_neighbor"], _ = NewNearestNeighbor(LSH, 64, 3, 1, 0, 0)
+	return cs
+}
+
+func TestLabels(t *testing.T) {
+	for name, c := range newClassifiers() {
+		Convey(fmt.Sprintf("Given a trained %v classifier", name), t, func() {
+			c.Clear()
+			trainShoguns(c, 1)
+
+			Convey("when getting labels", func() {
+				ls := c.Labels()
+
+				Convey("it should return all trained labels", func() {
+					So(ls, ShouldHaveLength, 3)
+					So(ls, ShouldContain, Label("徳川"))
+					So(ls, ShouldContain, Label("足利"))
+					So(ls, ShouldContain, Label("北条"))
+				})
+			})
+
+			Convey("when setting a new label", func() {
+				ok, err := c.SetLabel("織田")
+				So(err, ShouldBeNil)
+
+				Convey("it should succeed", func() {
+					So(ok, ShouldBeTrue)
+					So(c.Labels(), ShouldContain, Label("織田"))
+				})
+
+				Convey("classification results should have the label", func() {
+					scores, err := c.Classify(unigram("信長"))
+					So(err, ShouldBeNil)
+					So(scores, ShouldContainKey, "織田")
+				})
+			})
+
+			Convey("when setting an existing label", func() {
+				ok, err := c.SetLabel("徳川")
+
+				Convey("it should return false", func() {
+					So(err, ShouldBeNil)
+					So(ok, ShouldBeFalse)
+				})
+			})
+
+			Convey("when setting an empty label", func() {
+				_, err := c.SetLabel("")
+
+				Convey("it should fail", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("when deleting a label", func() {
+				ok := c.DeleteLabel("徳川")
+
+				Convey("it should succeed", func() {
+					So(ok, ShouldBeTrue)
+					So(c.Labels(), ShouldNotContain, Label("徳川"))
+				})
+
+				Convey("classification results shouldn't have the label", func() {
+					scores, err := c.Classify(unigram("家康"))
+					So(err, ShouldBeNil)
+					So(scores, ShouldNotContainKey, "徳川")
+					So(scores, ShouldHaveLength, 2)
+				})
+
+				Convey("the label should be able to be trained again", func() {
+					So(c.Train(unigram("家康"), "徳川"), ShouldBeNil)
+					So(c.Labels(), ShouldContain, Label("徳川"))
+				})
+			})
+
+			Convey("when deleting a nonexistent label", func() {
+				ok := c.DeleteLabel("織田")
+
+				Convey("it should return false", func() {
+					So(ok, ShouldBeFalse)
+				})
+			})
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/internal/intern"
+	"io"
+	"math"
+)
+
+// CW holds a model for classification using confidence weighted learning.
+type CW struct {
+	linearModel
+
+	regWeight float32
+}
+
+// NewCW creates a CW model. regWeight means sensitivity for data. When regWeight is large,
+// the model learns quickly but harms from noise. regWeight must be larger than zero.
+func NewCW(regWeight float32) (*CW, error) {
+	if regWeight <= 0 {
+		return nil, errors.New("regularization weight must be larger than zero")
+	}
+	return &CW{
+		linearModel: linearModel{model: make(model), intern: intern.New(), withCov: true},
+		regWeight:   regWeight,
+	}, nil
+}
+
+// Train trains a model with a feature vector and a label.
+func (c *CW) Train(v FeatureVector, label Label) error {
+	if label == "" {
+		return errors.New("label must not be empty")
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if _, ok := c.model[label]; !ok {
+		c.model[label] = make(weights)
+	}
+
+	fvForScores, fvFull, err := v.toInternal(c.intern)
+	if err != nil {
+		return err
+	}
+	scores := c.model.scores(fvForScores)
+	incorr, _ := scores.maxExcept(label)
+	margin := -scores.margin(label, incorr)
+	variance := variance(fvFull, c.model[label], c.model[incorr])
+
+	C := c.regWeight
+	b := 1 + 2*C*margin
+	gamma := -b + float32(math.Sqrt(float64(b*b-8*C*(margin-C*variance))))
+	if gamma <= 0 {
+		return nil
+	}
+	gamma /= 4 * C * variance
+
+	var incorrWeights weights
+	if incorr != "" {
+		incorrWeights = c.model[incorr]
+	}
+	corrWeights := c.model[label]
+
+	for _, elem := range fvFull {
+		dim := elem.dim
+		x := elem.value
+		covStep := 2 * gamma * x * x * C
+
+		w := corrWeights.get(dim)
+		corrWeights[dim] = weight{
+			Weight:     w.Weight + gamma*w.Covariance*x,
+			Covariance: 1 / (1/w.Covariance + covStep),
+		}
+
+		if incorr != "" {
+			w := incorrWeights.get(dim)
+			incorrWeights[dim] = weight{
+				Weight:     w.Weight - gamma*w.Covariance*x,
+				Covariance: 1 / (1/w.Covariance + covStep),
+			}
+		}
+	}
+
+	return nil
+}
+
+var (
+	cwFormatVersion uint8 = 1
+)
+
+type cwMsgpack struct {
+	_struct   struct{} `codec:",toarray"`
+	Model     model
+	RegWeight float32
+}
+
+// Save saves the current state of CW.
+func (c *CW) Save(w io.Writer) error {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	if _, err := w.Write([]byte{cwFormatVersion}); err != nil {
+		return err
+	}
+
+	enc := codec.NewEncoder(w, classifierMsgpackHandle)
+	if err := enc.Encode(&cwMsgpack{
+		Model:     c.model,
+		RegWeight: c.regWeight,
+	}); err != nil {
+		return err
+	}
+	return c.intern.Save(w)
+}
+
+// LoadCW loads CW from the saved data.
+func LoadCW(r io.Reader) (*CW, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadCWFormatV1(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of CW container: %v", formatVersion[0])
+	}
+}
+
+func loadCWFormatV1(r io.Reader) (*CW, error) {
+	m := cwMsgpack{}
+	dec := codec.NewDecoder(r, classifierMsgpackHandle)
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	i, err := intern.Load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CW{
+		linearModel: linearModel{model: m.Model, intern: i, withCov: true},
+		regWeight:   m.RegWeight,
+	}, nil
+}
+
+// RegWeight returns regularization weight.
+func (c *CW) RegWeight() float32 {
+	return c.regWeight
+}
@@ -0,0 +1,40 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+)
+
+// CWStateCreator is used by BQL to create or load a State having CW
+// as a UDS.
+type CWStateCreator struct {
+}
+
+var _ udf.UDSLoader = &CWStateCreator{}
+
+// CreateState creates a new state for CW classifier.
+func (c *CWStateCreator) CreateState(ctx *core.Context, params data.Map) (core.SharedState, error) {
+	rw, err := pluginutil.ExtractParamAndConvertToFloat(params, "regularization_weight")
+	if err != nil {
+		return nil, err
+	}
+	if rw <= 0 {
+		return nil, errors.New("regularization_weight parameter must be greater than zero")
+	}
+
+	cw, err := NewCW(float32(rw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize CW: %v", err)
+	}
+	return newState(params, "cw", cw)
+}
+
+// LoadState loads a new state for CW classifier.
+func (c *CWStateCreator) LoadState(ctx *core.Context, r io.Reader, params data.Map) (core.SharedState, error) {
+	return loadState(ctx, r, "cw")
+}
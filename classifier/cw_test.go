@@ -0,0 +1,35 @@
+package classifier
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+func ExampleCW() {
+	shuffledShogunList := make([]shogun, len(shogunList))
+	perm := rand.Perm(len(shogunList))
+	for i, v := range perm {
+		shuffledShogunList[v] = shogunList[i]
+	}
+
+	var cw, _ = NewCW(1)
+	for _, s := range shuffledShogunList {
+		fv := unigram(s.given)
+		cw.Train(fv, Label(s.family))
+	}
+
+	scores, _ := cw.Classify(unigram("慶喜"))
+	l, _ := scores.Max()
+	fmt.Println(l)
+	scores, _ = cw.Classify(unigram("義昭"))
+	l, _ = scores.Max()
+	fmt.Println(l)
+	scores, _ = cw.Classify(unigram("守時"))
+	l, _ = scores.Max()
+	fmt.Println(l)
+
+	// Output:
+	// 徳川
+	// 足利
+	// 北条
+}
@@ -0,0 +1,186 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"math"
+	"sort"
+)
+
+// FeatureWeights returns the top n positive and negative weights of each
+// label of the linear model the state having stateName has. The result is a
+// map from labels to maps having the following fields:
+//
+//	positive: an array of {feature, weight} in descending order of weights
+//	negative: an array of {feature, weight} in ascending order of weights
+//	active_dimensions: the number of features having non-zero weights
+//
+// Each element of positive and negative also has covariance when the
+// algorithm learns it, i.e. AROW, CW and NHERD.
+func FeatureWeights(ctx *core.Context, stateName string, n int) (data.Map, error) {
+	if n <= 0 {
+		return nil, errors.New("the number of weights must be greater than zero")
+	}
+	s, err := lookupState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+	lc, ok := s.classifier.(LinearClassifier)
+	if !ok {
+		return nil, fmt.Errorf("%v doesn't have a linear model", s.algorithm)
+	}
+	withCov := hasCovariance(lc)
+
+	ret := data.Map{}
+	for _, l := range lc.Labels() {
+		fws, ok := lc.Weights(l)
+		if !ok {
+			// The label was deleted after calling Labels.
+			continue
+		}
+		sort.Sort(featureWeightsByWeight(fws))
+
+		pos := data.Array{}
+		for i := 0; i < len(fws) && len(pos) < n && fws[i].Weight > 0; i++ {
+			pos = append(pos, fws[i].toMap(withCov))
+		}
+		neg := data.Array{}
+		for i := len(fws) - 1; i >= 0 && len(neg) < n && fws[i].Weight < 0; i-- {
+			neg = append(neg, fws[i].toMap(withCov))
+		}
+		active := 0
+		for _, fw := range fws {
+			if fw.Weight != 0 {
+				active++
+			}
+		}
+
+		ret[string(l)] = data.Map{
+			"positive":          pos,
+			"negative":          neg,
+			"active_dimensions": data.Int(active),
+		}
+	}
+	return ret, nil
+}
+
+// ActiveDimensions returns the number of features having a non-zero weight
+// in any label of the linear model the state having stateName has.
+func ActiveDimensions(ctx *core.Context, stateName string) (int, error) {
+	s, err := lookupState(ctx, stateName)
+	if err != nil {
+		return 0, err
+	}
+	lc, ok := s.classifier.(LinearClassifier)
+	if !ok {
+		return 0, fmt.Errorf("%v doesn't have a linear model", s.algorithm)
+	}
+	return lc.ActiveDims(), nil
+}
+
+// ClassifyExplain classifies a feature vector with the linear model the state
+// having stateName has and explains the result. It returns a map having the
+// following fields:
+//
+//	label: the label having the highest score
+//	score: the score of the label
+//	contributions: an array of {feature, value, weight, contribution} in
+//	               descending order of the absolute value of contributions
+//
+// contribution is value * weight, and the sum of contributions is the score.
+func ClassifyExplain(ctx *core.Context, stateName string, featureVector data.Map) (data.Map, error) {
+	s, err := lookupState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+	lc, ok := s.classifier.(LinearClassifier)
+	if !ok {
+		return nil, fmt.Errorf("%v doesn't have a linear model", s.algorithm)
+	}
+
+	fv, err := s.featureVector(featureVector, false)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := lc.Classify(fv)
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) == 0 {
+		return nil, errors.New("the model doesn't have any label")
+	}
+	l, score := scores.Max()
+	cs, err := lc.Contributions(fv, l)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(contributionsByMagnitude(cs))
+
+	arr := make(data.Array, len(cs))
+	for i, c := range cs {
+		arr[i] = data.Map{
+			"feature":      data.String(c.Feature),
+			"value":        data.Float(c.Value),
+			"weight":       data.Float(c.Weight),
+			"contribution": data.Float(c.Value * c.Weight),
+		}
+	}
+	return data.Map{
+		"label":         data.String(l),
+		"score":         data.Float(score),
+		"contributions": arr,
+	}, nil
+}
+
+func hasCovariance(c Classifier) bool {
+	m, ok := c.(linearMixer)
+	return ok && m.learnsCovariance()
+}
+
+func (fw *FeatureWeight) toMap(withCov bool) data.Map {
+	m := data.Map{
+		"feature": data.String(fw.Feature),
+		"weight":  data.Float(fw.Weight),
+	}
+	if withCov {
+		m["covariance"] = data.Float(fw.Covariance)
+	}
+	return m
+}
+
+// featureWeightsByWeight sorts weights in descending order. Features having
+// the same weight are sorted in lexicographical order.
+type featureWeightsByWeight []FeatureWeight
+
+func (s featureWeightsByWeight) Len() int {
+	return len(s)
+}
+
+func (s featureWeightsByWeight) Less(i, j int) bool {
+	return s[i].Weight > s[j].Weight || (s[i].Weight == s[j].Weight && s[i].Feature < s[j].Feature)
+}
+
+func (s featureWeightsByWeight) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// contributionsByMagnitude sorts contributions in descending order of their
+// absolute values. Features having the same magnitude are sorted in
+// lexicographical order.
+type contributionsByMagnitude []Contribution
+
+func (s contributionsByMagnitude) Len() int {
+	return len(s)
+}
+
+func (s contributionsByMagnitude) Less(i, j int) bool {
+	ci := math.Abs(float64(s[i].Value * s[i].Weight))
+	cj := math.Abs(float64(s[j].Value * s[j].Weight))
+	return ci > cj || (ci == cj && s[i].Feature < s[j].Feature)
+}
+
+func (s contributionsByMagnitude) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
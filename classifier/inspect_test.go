@@ -0,0 +1,253 @@
+package classifier
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestFeatureWeights(t *testing.T) {
+	ctx := core.NewContext(nil)
+	for _, c := range []struct {
+		name    string
+		creator interface {
+			CreateState(*core.Context, data.Map) (core.SharedState, error)
+		}
+		withCov bool
+	}{
+		{"jubaclassifier_arow", &AROWStateCreator{}, true},
+		{"jubaclassifier_perceptron", &PerceptronStateCreator{}, false},
+	} {
+		s, err := c.creator.CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(1),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ctx.SharedStates.Add(c.name, c.name, s); err != nil {
+			t.Fatal(err)
+		}
+		st := s.(*State)
+		for i := 0; i < 10; i++ {
+			for _, d := range []data.Map{
+				{"label": data.String("x"), "feature_vector": data.Map{"x": data.Float(1), "common": data.Float(1)}},
+				{"label": data.String("y"), "feature_vector": data.Map{"y": data.Float(1), "common": data.Float(1)}},
+			} {
+				if err := st.Write(ctx, &core.Tuple{Data: d}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		Convey("Given a state of "+c.name, t, func() {
+			Convey("when getting top 1 weights", func() {
+				ws, err := FeatureWeights(ctx, c.name, 1)
+				So(err, ShouldBeNil)
+
+				Convey("it should have all labels", func() {
+					So(ws, ShouldHaveLength, 2)
+				})
+
+				Convey("the feature of the label should be the most positive", func() {
+					m := ws["x"].(data.Map)["positive"].(data.Array)[0].(data.Map)
+					So(m["feature"], ShouldEqual, data.String("x"))
+					if c.withCov {
+						So(m, ShouldContainKey, "covariance")
+					} else {
+						So(m, ShouldNotContainKey, "covariance")
+					}
+				})
+
+				Convey("the feature of another label should be the most negative", func() {
+					m := ws["x"].(data.Map)["negative"].(data.Array)[0].(data.Map)
+					So(m["feature"], ShouldEqual, data.String("y"))
+				})
+			})
+
+			Convey("when getting the number of active dimensions", func() {
+				n, err := ActiveDimensions(ctx, c.name)
+
+				Convey("it should count all features", func() {
+					So(err, ShouldBeNil)
+					So(n, ShouldEqual, 3)
+				})
+			})
+		})
+	}
+
+	Convey("Given a state of jubaclassifier_nn", t, func() {
+		c := NearestNeighborStateCreator{}
+		s, err := c.CreateState(ctx, data.Map{
+			"nearest_neighbor_algorithm": data.String("lsh"),
+			"hash_num":                   data.Int(64),
+			"nearest_neighbor_num":       data.Int(3),
+		})
+		So(err, ShouldBeNil)
+		So(ctx.SharedStates.Add("nn", "jubaclassifier_nn", s), ShouldBeNil)
+
+		Convey("when getting weights", func() {
+			_, err := FeatureWeights(ctx, "nn", 1)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestClassifyExplain(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := AROWStateCreator{}
+	s, err := c.CreateState(ctx, data.Map{
+		"regularization_weight": data.Float(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SharedStates.Add("explain", "jubaclassifier_arow", s); err != nil {
+		t.Fatal(err)
+	}
+	st := s.(*State)
+	for i := 0; i < 10; i++ {
+		for _, d := range []data.Map{
+			{"label": data.String("x"), "feature_vector": data.Map{"x": data.Float(1), "common": data.Float(1)}},
+			{"label": data.String("y"), "feature_vector": data.Map{"y": data.Float(1), "common": data.Float(1)}},
+		} {
+			if err := st.Write(ctx, &core.Tuple{Data: d}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	Convey("Given a trained state", t, func() {
+		Convey("when explaining a classification result", func() {
+			fv := data.Map{"x": data.Float(2), "common": data.Float(1), "unknown": data.Float(1)}
+			e, err := ClassifyExplain(ctx, "explain", fv)
+			So(err, ShouldBeNil)
+
+			Convey("it should have the classified label", func() {
+				So(e["label"], ShouldEqual, data.String("x"))
+			})
+
+			Convey("the sum of contributions should be the score", func() {
+				cs := e["contributions"].(data.Array)
+				So(cs, ShouldHaveLength, 3)
+				So(cs[0].(data.Map)["feature"], ShouldEqual, data.String("x"))
+				So(cs[2].(data.Map)["feature"], ShouldEqual, data.String("unknown"))
+
+				var sum float64
+				for _, c := range cs {
+					f, _ := data.AsFloat(c.(data.Map)["contribution"])
+					sum += f
+				}
+				score, _ := data.AsFloat(e["score"])
+				So(sum, ShouldAlmostEqual, score, 1e-6)
+			})
+		})
+	})
+}
+
+func TestFeatureHashing(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := AROWStateCreator{}
+
+	Convey("Given a state with hash_max_size", t, func() {
+		s, err := c.CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(1),
+			"hash_max_size":         data.Int(8),
+		})
+		So(err, ShouldBeNil)
+		st := s.(*State)
+		So(ctx.SharedStates.Add("hashing", "jubaclassifier_arow", s), ShouldBeNil)
+		Reset(func() {
+			ctx.SharedStates.Remove("hashing")
+		})
+
+		Convey("when training it with many features", func() {
+			for i := 0; i < 100; i++ {
+				So(st.Write(ctx, &core.Tuple{Data: data.Map{
+					"label":          data.String(fmt.Sprint("l", i%2)),
+					"feature_vector": data.Map{fmt.Sprint("f", i): data.Float(1)},
+				}}), ShouldBeNil)
+			}
+
+			Convey("the number of dimensions should be bounded", func() {
+				n, err := ActiveDimensions(ctx, "hashing")
+				So(err, ShouldBeNil)
+				So(n, ShouldBeLessThanOrEqualTo, 8)
+			})
+
+			Convey("feature names should be hashed dimensions", func() {
+				ws, err := FeatureWeights(ctx, "hashing", 1)
+				So(err, ShouldBeNil)
+				m := ws["l0"].(data.Map)["positive"].(data.Array)[0].(data.Map)
+				f, _ := data.AsString(m["feature"])
+				So(f, ShouldStartWith, "#")
+			})
+
+			Convey("the sum of contributions should be the score", func() {
+				// Features more than hash_max_size share dimensions.
+				fv := data.Map{}
+				for i := 0; i < 20; i++ {
+					fv[fmt.Sprint("f", i)] = data.Float(i + 1)
+				}
+				fv["unseen"] = data.Float(1)
+				e, err := ClassifyExplain(ctx, "hashing", fv)
+				So(err, ShouldBeNil)
+
+				cs := e["contributions"].(data.Array)
+				So(cs, ShouldHaveLength, 21)
+				var sum float64
+				for _, c := range cs {
+					f, _ := data.AsFloat(c.(data.Map)["contribution"])
+					sum += f
+				}
+				score, _ := data.AsFloat(e["score"])
+				So(sum, ShouldAlmostEqual, score, 1e-4)
+			})
+
+			Convey("the loaded state should hash features in the same way", func() {
+				buf := bytes.NewBuffer(nil)
+				So(st.Save(ctx, buf, data.Map{}), ShouldBeNil)
+				s2, err := c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				So(s2.(*State).classifier.(*AROW).HashMaxSize(), ShouldEqual, 8)
+
+				fv := FeatureVector{"f1": data.Float(1), "unseen": data.Float(1)}
+				sc, err := st.classifier.Classify(fv)
+				So(err, ShouldBeNil)
+				sc2, err := s2.(*State).classifier.Classify(fv)
+				So(err, ShouldBeNil)
+				So(sc2, ShouldResemble, sc)
+			})
+		})
+
+		Convey("when clearing it", func() {
+			st.classifier.Clear()
+
+			Convey("it should keep hashing", func() {
+				So(st.classifier.(*AROW).HashMaxSize(), ShouldEqual, 8)
+			})
+		})
+	})
+
+	Convey("Given NearestNeighborStateCreator", t, func() {
+		c := NearestNeighborStateCreator{}
+
+		Convey("when creating a state with hash_max_size", func() {
+			_, err := c.CreateState(ctx, data.Map{
+				"nearest_neighbor_algorithm": data.String("lsh"),
+				"hash_num":                   data.Int(64),
+				"nearest_neighbor_num":       data.Int(3),
+				"hash_max_size":              data.Int(8),
+			})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,101 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zeromberto/jubatus/internal/jubatus"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+)
+
+// LoadJubatusAROW loads an AROW model from a model file saved by
+// jubaclassifier of the original Jubatus using AROW method. Weights and
+// covariances of features and labels are imported as they are. Numbers of
+// trained data of labels are imported as label counts used by the automatic
+// class weight.
+func LoadJubatusAROW(r io.Reader) (*AROW, error) {
+	f, err := jubatus.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	return loadJubatusAROW(f)
+}
+
+func loadJubatusAROW(f *jubatus.File) (*AROW, error) {
+	method, params, err := f.Method()
+	if err != nil {
+		return nil, err
+	}
+	if method != "AROW" {
+		return nil, fmt.Errorf("the model isn't AROW but %v", method)
+	}
+	rw, ok := params["regularization_weight"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("regularization_weight of the model is invalid: %v", params["regularization_weight"])
+	}
+	a, err := NewAROW(float32(rw))
+	if err != nil {
+		return nil, err
+	}
+
+	st, counts, err := f.LinearClassifier()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range st.Labels {
+		if _, err := a.model.setLabel(Label(l)); err != nil {
+			return nil, err
+		}
+	}
+	for l, n := range counts {
+		if _, err := a.model.setLabel(Label(l)); err != nil {
+			return nil, err
+		}
+		a.labelCounts[Label(l)] = n
+	}
+
+	for feature, vs := range st.Weights {
+		d := dim(a.intern.Get(feature))
+		for l, v := range vs {
+			w := initialWeight()
+			w.Weight = float32(v.V1)
+			// Updates never make covariance zero, so zero means that it
+			// isn't stored.
+			if v.V2 != 0 {
+				w.Covariance = float32(v.V2)
+			}
+			a.model[Label(l)][d] = w
+		}
+	}
+	return a, nil
+}
+
+// createAROWStateFromJubatus creates a state having an AROW model imported
+// from the file given by jubatus_model parameter.
+func createAROWStateFromJubatus(params data.Map) (*State, error) {
+	path, err := pluginutil.ExtractParamAsString(params, "jubatus_model")
+	if err != nil {
+		return nil, err
+	}
+	multiLabel, err := pluginutil.ExtractParamAsBoolWithDefault(params, "multi_label", false)
+	if err != nil {
+		return nil, err
+	}
+	if multiLabel {
+		return nil, errors.New("a model imported from Jubatus doesn't support multi_label parameter")
+	}
+
+	f, err := jubatus.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the Jubatus model: %v", err)
+	}
+	a, err := loadJubatusAROW(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot import the Jubatus model: %v", err)
+	}
+	if err := setAROWClassWeights(a, params); err != nil {
+		return nil, err
+	}
+	return newState(params, "arow", a)
+}
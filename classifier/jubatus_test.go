@@ -0,0 +1,148 @@
+package classifier
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/zeromberto/jubatus/internal/jubatus"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func writeJubatusAROW(t *testing.T) []byte {
+	s := jubatus.PackStorage(&jubatus.Storage{
+		Weights: map[string]map[string]jubatus.Val3{
+			"color$red@str#bin/bin":  {"r": {V1: 1, V2: 0.5}, "b": {V1: -1, V2: 0.5}},
+			"color$blue@str#bin/bin": {"r": {V1: -1, V2: 0.5}, "b": {V1: 1, V2: 0.5}},
+		},
+		Labels: []string{"r", "b", "g"},
+	})
+	labels := []interface{}{
+		map[interface{}]interface{}{"r": uint64(3), "b": uint64(1), "g": uint64(0)},
+		map[interface{}]interface{}{},
+		[]interface{}{uint64(0)},
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := jubatus.Write(buf, &jubatus.File{
+		Version: [3]uint32{1, 0, 0},
+		Type:    "classifier",
+		Config:  `{"method":"AROW","parameter":{"regularization_weight":0.5}}`,
+		Data:    []interface{}{[]interface{}{s, labels}, []interface{}{}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoadJubatusAROW(t *testing.T) {
+	b := writeJubatusAROW(t)
+
+	Convey("Given an AROW model file of Jubatus", t, func() {
+		Convey("when loading it", func() {
+			a, err := LoadJubatusAROW(bytes.NewReader(b))
+			So(err, ShouldBeNil)
+
+			Convey("it should have the parameter", func() {
+				So(a.RegWeight(), ShouldEqual, 0.5)
+			})
+
+			Convey("it should have all labels", func() {
+				So(a.Labels(), ShouldHaveLength, 3)
+				So(a.labelCounts, ShouldResemble, map[Label]uint64{"r": 3, "b": 1, "g": 0})
+			})
+
+			Convey("it should classify data", func() {
+				s, err := a.Classify(FeatureVector{"color$red@str#bin/bin": data.Float(1)})
+				So(err, ShouldBeNil)
+				l, score := s.Max()
+				So(l, ShouldEqual, "r")
+				So(score, ShouldEqual, 1)
+			})
+
+			Convey("it should have covariances", func() {
+				fws, ok := a.Weights("r")
+				So(ok, ShouldBeTrue)
+				So(fws, ShouldHaveLength, 2)
+				for _, fw := range fws {
+					So(fw.Covariance, ShouldEqual, 0.5)
+				}
+			})
+		})
+
+		Convey("when loading a truncated file", func() {
+			_, err := LoadJubatusAROW(bytes.NewReader(b[:len(b)-1]))
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given a PA model file of Jubatus", t, func() {
+		buf := bytes.NewBuffer(nil)
+		So(jubatus.Write(buf, &jubatus.File{
+			Type:   "classifier",
+			Config: `{"method":"PA"}`,
+			Data:   []interface{}{},
+		}), ShouldBeNil)
+
+		Convey("when loading it as AROW", func() {
+			_, err := LoadJubatusAROW(buf)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestAROWStateFromJubatus(t *testing.T) {
+	ctx := core.NewContext(nil)
+	f, err := ioutil.TempFile("", "jubatus_arow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(writeJubatusAROW(t)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	Convey("Given a Jubatus model file", t, func() {
+		Convey("when creating an AROW state with jubatus_model parameter", func() {
+			s, err := (&AROWStateCreator{}).CreateState(ctx, data.Map{
+				"jubatus_model": data.String(f.Name()),
+			})
+
+			Convey("it should have the imported model", func() {
+				So(err, ShouldBeNil)
+				a := s.(*State).classifier.(*AROW)
+				So(a.RegWeight(), ShouldEqual, 0.5)
+				So(a.Labels(), ShouldHaveLength, 3)
+			})
+		})
+
+		Convey("when creating a multi-label AROW state with jubatus_model parameter", func() {
+			_, err := (&AROWStateCreator{}).CreateState(ctx, data.Map{
+				"jubatus_model": data.String(f.Name()),
+				"multi_label":   data.Bool(true),
+			})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when creating an AROW state with a nonexistent file", func() {
+			_, err := (&AROWStateCreator{}).CreateState(ctx, data.Map{
+				"jubatus_model": data.String(f.Name() + ".nonexistent"),
+			})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
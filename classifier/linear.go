@@ -0,0 +1,315 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zeromberto/jubatus/internal/intern"
+	"github.com/zeromberto/jubatus/internal/nested"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"math"
+	"sync"
+)
+
+// This file has functions shared by linear classifiers.
+
+// linearModel has the model of a linear classifier and feature names mapped
+// to its dimensions. Linear classifiers embed it and implement only training
+// and serialization themselves.
+type linearModel struct {
+	model  model
+	intern *intern.Intern
+	m      sync.RWMutex
+
+	// withCov is true when the algorithm learns covariance, i.e. AROW, CW
+	// and NHERD.
+	withCov bool
+}
+
+// Classify classifies a feature vector. This function returns
+// all labels and scores.
+func (l *linearModel) Classify(v FeatureVector) (LScores, error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	intfv, err := v.toInternalForScores(l.intern)
+	if err != nil {
+		return nil, err
+	}
+	scores := l.model.scores(intfv)
+	return scores, nil
+}
+
+// Clear clears a model.
+func (l *linearModel) Clear() {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.clear()
+}
+
+// clear clears a model. It requires write lock.
+func (l *linearModel) clear() {
+	l.model = make(model)
+	l.intern.Clear()
+}
+
+// Labels returns all labels the model has.
+func (l *linearModel) Labels() []Label {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	return l.model.labels()
+}
+
+// SetLabel registers a label with zero weights. It returns false when the
+// model already has the label.
+func (l *linearModel) SetLabel(label Label) (bool, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.model.setLabel(label)
+}
+
+// DeleteLabel deletes a label and its weights. It returns false when the
+// model doesn't have the label.
+func (l *linearModel) DeleteLabel(label Label) bool {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.model.deleteLabel(label)
+}
+
+// Weights returns weights of all features of the label. It returns false when
+// the model doesn't have the label.
+func (l *linearModel) Weights(label Label) ([]FeatureWeight, bool) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	return l.model.featureWeights(label, l.intern)
+}
+
+// ActiveDims returns the number of dimensions having a non-zero weight in
+// any label.
+func (l *linearModel) ActiveDims() int {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	return l.model.activeDims()
+}
+
+// Contributions returns how much each feature of v contributes to the score
+// of the label.
+func (l *linearModel) Contributions(v FeatureVector, label Label) ([]Contribution, error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	return l.model.contributions(v, label, l.intern)
+}
+
+// EnableFeatureHashing makes the model map features to at most maxSize
+// dimensions by hashing instead of storing all feature names. It must be
+// called before training.
+func (l *linearModel) EnableFeatureHashing(maxSize int) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.intern.EnableHashing(maxSize)
+}
+
+// HashMaxSize returns the max number of dimensions when feature hashing is
+// enabled. It returns zero otherwise.
+func (l *linearModel) HashMaxSize() int {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	return l.intern.HashMaxSize()
+}
+
+// Prune removes weights whose absolute values aren't greater than threshold.
+// Weights whose covariance is still the initial value are also removed when
+// the algorithm learns covariance. Feature names which no weight refers to
+// are removed afterwards. It returns the number of removed weights and the
+// number of removed feature names.
+func (l *linearModel) Prune(threshold float32) (int, int) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.model.prune(threshold, l.withCov, l.intern)
+}
+
+func (l *linearModel) learnsCovariance() bool {
+	return l.withCov
+}
+
+func (l *linearModel) mutex() *sync.RWMutex {
+	return &l.m
+}
+
+func (l *linearModel) mixSnapshot() *mixSnapshot {
+	return newMixSnapshot(l.model, l.intern)
+}
+
+func (l *linearModel) setMixed(m model, in *intern.Intern, labelCounts map[Label]uint64) {
+	l.model = m
+	l.intern = in
+}
+
+// update adds step*x to the weights of the correct label and subtracts it from
+// the weights of the incorrect label. When incorrect is empty, only the weights
+// of the correct label are updated.
+// jubatus::core::classifier::linear_classifier::update_weight
+func (m model) update(v fVector, step float32, correct, incorrect Label) {
+	corrWeights := m[correct]
+	var incorrWeights weights
+	if incorrect != "" {
+		incorrWeights = m[incorrect]
+	}
+
+	for _, elem := range v {
+		corrWeights.add(elem.dim, step*elem.value)
+		if incorrWeights != nil {
+			incorrWeights.add(elem.dim, -step*elem.value)
+		}
+	}
+}
+
+func (ws weights) add(dim dim, delta float32) {
+	var weight weight
+	if w, ok := ws[dim]; ok {
+		weight = w
+	} else {
+		weight = initialWeight()
+	}
+	weight.Weight += delta
+	ws[dim] = weight
+}
+
+func (v fVector) squaredNorm() float32 {
+	var norm2 float32
+	for _, elem := range v {
+		norm2 += elem.value * elem.value
+	}
+	return norm2
+}
+
+func (m model) labels() []Label {
+	ls := make([]Label, 0, len(m))
+	for l := range m {
+		ls = append(ls, l)
+	}
+	return ls
+}
+
+func (m model) setLabel(label Label) (bool, error) {
+	if label == "" {
+		return false, errors.New("label must not be empty")
+	}
+	if _, ok := m[label]; ok {
+		return false, nil
+	}
+	m[label] = make(weights)
+	return true, nil
+}
+
+func (m model) deleteLabel(label Label) bool {
+	if _, ok := m[label]; !ok {
+		return false
+	}
+	delete(m, label)
+	return true
+}
+
+// FeatureWeight is a weight of a feature learned by a linear classifier.
+type FeatureWeight struct {
+	// Feature is the name of the feature. When feature hashing is enabled,
+	// it's "#" followed by the hashed dimension.
+	Feature string
+	Weight  float32
+
+	// Covariance is only meaningful for algorithms which learn it, such as
+	// AROW. Other algorithms always have the initial value.
+	Covariance float32
+}
+
+func (m model) featureWeights(label Label, in *intern.Intern) ([]FeatureWeight, bool) {
+	ws, ok := m[label]
+	if !ok {
+		return nil, false
+	}
+	inv := in.Inverse()
+	fws := make([]FeatureWeight, 0, len(ws))
+	for d, w := range ws {
+		f, ok := inv[int(d)]
+		if !ok {
+			// Feature names cannot be restored when feature hashing is
+			// enabled.
+			f = fmt.Sprintf("#%d", d)
+		}
+		fws = append(fws, FeatureWeight{
+			Feature:    f,
+			Weight:     w.Weight,
+			Covariance: w.Covariance,
+		})
+	}
+	return fws, true
+}
+
+func (m model) activeDims() int {
+	dims := make(map[dim]struct{})
+	for _, ws := range m {
+		for d, w := range ws {
+			if w.Weight != 0 {
+				dims[d] = struct{}{}
+			}
+		}
+	}
+	return len(dims)
+}
+
+// Contribution is a contribution of a feature to a score. The contribution
+// is Value * Weight.
+type Contribution struct {
+	Feature string
+	Value   float32
+	Weight  float32
+}
+
+// contributions computes contributions of features with weights.score, which
+// model.scores also uses. Features which the model doesn't know have zero
+// weights.
+func (m model) contributions(v FeatureVector, label Label, in *intern.Intern) ([]Contribution, error) {
+	ws, ok := m[label]
+	if !ok {
+		return nil, fmt.Errorf("the model doesn't have label %v", label)
+	}
+	fv := make(fVectorForScores, 0, len(v))
+	cs := make([]Contribution, 0, len(v))
+	err := nested.Flatten(data.Map(v), func(key string, value float32) {
+		fv = append(fv, fElement{dim(in.GetOrZero(key)), value})
+		cs = append(cs, Contribution{
+			Feature: key,
+			Value:   value,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	ws.score(fv, func(i int, w float32) {
+		cs[i].Weight = w
+	})
+	return cs, nil
+}
+
+// prune removes weights whose absolute values aren't greater than threshold.
+// When withCov is true, weights whose covariance is still the initial value
+// are also removed. Features which no weight refers to are removed from in.
+func (m model) prune(threshold float32, withCov bool, in *intern.Intern) (int, int) {
+	initCov := initialWeight().Covariance
+	removed := 0
+	used := make(map[dim]struct{})
+	for _, ws := range m {
+		for d, w := range ws {
+			if float32(math.Abs(float64(w.Weight))) <= threshold || (withCov && w.Covariance == initCov) {
+				delete(ws, d)
+				removed++
+				continue
+			}
+			used[d] = struct{}{}
+		}
+	}
+
+	features := in.Compact(func(id int) bool {
+		_, ok := used[dim(id)]
+		return ok
+	})
+	return removed, features
+}
@@ -0,0 +1,211 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zeromberto/jubatus/internal/intern"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"io"
+	"sync"
+)
+
+// linearMixer is implemented by linear classifiers which can be mixed.
+type linearMixer interface {
+	LinearClassifier
+
+	// mutex returns the lock of the model.
+	mutex() *sync.RWMutex
+
+	// mixSnapshot returns a copy of the model which can be read without
+	// locking the classifier. It requires read lock.
+	mixSnapshot() *mixSnapshot
+
+	// learnsCovariance returns true when the algorithm learns covariance.
+	learnsCovariance() bool
+
+	// setMixed replaces the model with a mixed one. labelCounts is the
+	// number of times each label was trained in all sources. It requires
+	// write lock.
+	setMixed(m model, in *intern.Intern, labelCounts map[Label]uint64)
+}
+
+// mixSnapshot is a copy of a linear model. Dimensions of the model are
+// mapped to feature names by features unless hashMaxSize is greater than
+// zero. labelCounts is nil when the algorithm doesn't count labels.
+type mixSnapshot struct {
+	model       model
+	features    map[int]string
+	hashMaxSize int
+	labelCounts map[Label]uint64
+}
+
+// mixMutex serializes mixes. Otherwise, states mixing each other at the same
+// time would deadlock because each of them locks its own model before reading
+// the others.
+var mixMutex sync.Mutex
+
+func newMixSnapshot(m model, in *intern.Intern) *mixSnapshot {
+	c := make(model, len(m))
+	for l, ws := range m {
+		cws := make(weights, len(ws))
+		for d, w := range ws {
+			cws[d] = w
+		}
+		c[l] = cws
+	}
+	return &mixSnapshot{
+		model:       c,
+		features:    in.Inverse(),
+		hashMaxSize: in.HashMaxSize(),
+	}
+}
+
+// Mix replaces the model of the state with the mixture of models of sources.
+// Sources must be classifier states having the same linear algorithm and the
+// same hash_max_size parameter. The state itself can be in sources.
+//
+// Weights of each label are averaged over sources having the label. For
+// algorithms learning covariance, i.e. AROW, CW and NHERD, weights are
+// averaged with the inverse of covariance as the weight of the average so
+// that confident weights dominate the result. The covariance becomes the
+// harmonic mean of covariance of sources. A feature which a source doesn't
+// have is regarded as having the initial weight in the source. The number of
+// times each label was trained, which AROW uses for automatic class weights,
+// is summed up.
+//
+// The model of the state is locked during the mix so that data trained
+// meanwhile isn't lost.
+func (s *State) Mix(sources []core.SharedState) error {
+	if len(sources) == 0 {
+		return errors.New("at least one source is required")
+	}
+	dst, ok := s.classifier.(linearMixer)
+	if !ok {
+		return fmt.Errorf("%v doesn't support mixing", s.algorithm)
+	}
+
+	srcs := make([]linearMixer, len(sources))
+	for i, src := range sources {
+		st, ok := src.(*State)
+		if !ok {
+			return fmt.Errorf("source %v isn't a classifier state", i)
+		}
+		if st.algorithm != s.algorithm {
+			return fmt.Errorf("source %v has a different algorithm: %v", i, st.algorithm)
+		}
+		srcs[i] = st.classifier.(linearMixer)
+	}
+
+	hashMaxSize := dst.HashMaxSize()
+	mixMutex.Lock()
+	defer mixMutex.Unlock()
+	dm := dst.mutex()
+	dm.Lock()
+	defer dm.Unlock()
+
+	snapshots := make([]*mixSnapshot, len(srcs))
+	for i, src := range srcs {
+		if sm := src.mutex(); sm != dm {
+			sm.RLock()
+			snapshots[i] = src.mixSnapshot()
+			sm.RUnlock()
+		} else {
+			snapshots[i] = src.mixSnapshot()
+		}
+	}
+
+	m, in, err := mixModels(snapshots, dst.learnsCovariance(), hashMaxSize)
+	if err != nil {
+		return err
+	}
+	dst.setMixed(m, in, mixLabelCounts(snapshots))
+	return nil
+}
+
+func mixLabelCounts(snapshots []*mixSnapshot) map[Label]uint64 {
+	lc := make(map[Label]uint64)
+	for _, snap := range snapshots {
+		for l, n := range snap.labelCounts {
+			lc[l] += n
+		}
+	}
+	return lc
+}
+
+func mixModels(snapshots []*mixSnapshot, withCov bool, hashMaxSize int) (model, *intern.Intern, error) {
+	type accumulator struct {
+		// weightSum is the sum of weights, or weights divided by covariance
+		// when withCov is true.
+		weightSum    float64
+		precisionSum float64
+		n            int
+	}
+
+	in := intern.New()
+	if hashMaxSize > 0 {
+		if err := in.EnableHashing(hashMaxSize); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	accs := map[Label]map[dim]*accumulator{}
+	labelNum := map[Label]int{}
+	for i, snap := range snapshots {
+		if snap.hashMaxSize != hashMaxSize {
+			return nil, nil, fmt.Errorf("source %v has a different hash_max_size: %v", i, snap.hashMaxSize)
+		}
+
+		for l, ws := range snap.model {
+			labelNum[l]++
+			la, ok := accs[l]
+			if !ok {
+				la = map[dim]*accumulator{}
+				accs[l] = la
+			}
+
+			for d, w := range ws {
+				if hashMaxSize == 0 {
+					d = dim(in.Get(snap.features[int(d)]))
+				}
+				a, ok := la[d]
+				if !ok {
+					a = &accumulator{}
+					la[d] = a
+				}
+				if withCov {
+					a.weightSum += float64(w.Weight / w.Covariance)
+					a.precisionSum += float64(1 / w.Covariance)
+				} else {
+					a.weightSum += float64(w.Weight)
+				}
+				a.n++
+			}
+		}
+	}
+
+	initial := initialWeight()
+	m := make(model, len(accs))
+	for l, la := range accs {
+		n := labelNum[l]
+		ws := make(weights, len(la))
+		for d, a := range la {
+			w := initial
+			if withCov {
+				p := a.precisionSum + float64(n-a.n)/float64(initial.Covariance)
+				w.Weight = float32(a.weightSum / p)
+				w.Covariance = float32(float64(n) / p)
+			} else {
+				w.Weight = float32(a.weightSum / float64(n))
+			}
+			ws[d] = w
+		}
+		m[l] = ws
+	}
+	return m, in, nil
+}
+
+// LoadMixSource loads a state saved by Save of a state having the same
+// algorithm so that it can be mixed with the state.
+func (s *State) LoadMixSource(ctx *core.Context, r io.Reader) (core.SharedState, error) {
+	return loadState(ctx, r, s.algorithm)
+}
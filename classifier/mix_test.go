@@ -0,0 +1,133 @@
+package classifier
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestMixAROW(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := AROWStateCreator{}
+	newAROWState := func() *State {
+		s, err := c.CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(1),
+		})
+		So(err, ShouldBeNil)
+		return s.(*State)
+	}
+
+	Convey("Given AROW states trained with different data", t, func() {
+		target := newAROWState()
+		a := newAROWState()
+		b := newAROWState()
+		for i := 0; i < 10; i++ {
+			So(a.classifier.Train(FeatureVector{"x": data.Float(1)}, "x"), ShouldBeNil)
+			So(a.classifier.Train(FeatureVector{"y": data.Float(1)}, "y"), ShouldBeNil)
+			So(b.classifier.Train(FeatureVector{"z": data.Float(1)}, "z"), ShouldBeNil)
+			So(b.classifier.Train(FeatureVector{"y": data.Float(1)}, "y"), ShouldBeNil)
+		}
+
+		Convey("when mixing them", func() {
+			So(target.Mix([]core.SharedState{a, b}), ShouldBeNil)
+
+			Convey("the mixed model should classify data of both sources", func() {
+				for _, l := range []string{"x", "y", "z"} {
+					s, err := target.classifier.Classify(FeatureVector{l: data.Float(1)})
+					So(err, ShouldBeNil)
+					ml, _ := s.Max()
+					So(ml, ShouldEqual, Label(l))
+				}
+			})
+
+			Convey("a weight learned by both sources should be precision-weighted", func() {
+				ta := target.classifier.(*AROW)
+				aa := a.classifier.(*AROW)
+				ba := b.classifier.(*AROW)
+				w := ta.model["y"].get(dim(ta.intern.GetOrZero("y")))
+				wa := aa.model["y"].get(dim(aa.intern.GetOrZero("y")))
+				wb := ba.model["y"].get(dim(ba.intern.GetOrZero("y")))
+
+				p := 1/wa.Covariance + 1/wb.Covariance
+				So(w.Weight, ShouldAlmostEqual, (wa.Weight/wa.Covariance+wb.Weight/wb.Covariance)/p, 1e-6)
+				So(w.Covariance, ShouldAlmostEqual, 2/p, 1e-6)
+			})
+
+			Convey("the number of times each label was trained should be summed", func() {
+				So(target.classifier.(*AROW).labelCounts, ShouldResemble, map[Label]uint64{
+					"x": 10,
+					"y": 20,
+					"z": 10,
+				})
+			})
+		})
+
+		Convey("when mixing a state into itself with another", func() {
+			So(a.Mix([]core.SharedState{a, b}), ShouldBeNil)
+
+			Convey("it should have labels of both sources", func() {
+				So(len(a.classifier.Labels()), ShouldEqual, 3)
+			})
+		})
+
+		Convey("when mixing with a saved and loaded state", func() {
+			buf := bytes.NewBuffer(nil)
+			So(a.Save(ctx, buf, data.Map{}), ShouldBeNil)
+			src, err := target.LoadMixSource(ctx, buf)
+			So(err, ShouldBeNil)
+			So(target.Mix([]core.SharedState{src}), ShouldBeNil)
+
+			Convey("it should have the same model as the saved state", func() {
+				So(len(target.classifier.Labels()), ShouldEqual, 2)
+				s, err := target.classifier.Classify(FeatureVector{"x": data.Float(1)})
+				So(err, ShouldBeNil)
+				ml, _ := s.Max()
+				So(ml, ShouldEqual, Label("x"))
+			})
+		})
+
+		Convey("when mixing with a state having a different algorithm", func() {
+			p, err := (&PerceptronStateCreator{}).CreateState(ctx, data.Map{})
+			So(err, ShouldBeNil)
+			err = target.Mix([]core.SharedState{a, p})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when mixing with a state having a different hash_max_size", func() {
+			h, err := c.CreateState(ctx, data.Map{
+				"regularization_weight": data.Float(1),
+				"hash_max_size":         data.Int(16),
+			})
+			So(err, ShouldBeNil)
+			err = target.Mix([]core.SharedState{a, h})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestMixPerceptron(t *testing.T) {
+	Convey("Given Perceptrons having weights", t, func() {
+		a := NewPerceptron()
+		b := NewPerceptron()
+		a.model = model{"x": weights{dim(a.intern.Get("f")): weight{Weight: 2, Covariance: 1}}}
+		b.model = model{"x": weights{dim(b.intern.Get("g")): weight{Weight: 4, Covariance: 1}}}
+
+		Convey("when mixing them", func() {
+			m, in, err := mixModels([]*mixSnapshot{a.mixSnapshot(), b.mixSnapshot()}, false, 0)
+			So(err, ShouldBeNil)
+
+			Convey("weights should be averaged", func() {
+				So(m["x"][dim(in.GetOrZero("f"))].Weight, ShouldEqual, 1)
+				So(m["x"][dim(in.GetOrZero("g"))].Weight, ShouldEqual, 2)
+			})
+		})
+	})
+}
@@ -0,0 +1,337 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/internal/nearest"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// NearestNeighbor holds a model for classification based on nearest neighbor
+// search. It stores labeled rows and classifies a feature vector by weighted
+// voting among the labels of its nearest rows.
+type NearestNeighbor struct {
+	nn     nearest.Neighbor
+	labels []Label
+	nnNum  int
+
+	// freeIDs has IDs of rows whose labels were deleted. Those rows have
+	// empty labels and are reused by Train.
+	freeIDs []nearest.ID
+
+	// localSensitivity is a coefficient of distances used to weight votes.
+	localSensitivity float32
+
+	// labelSet has all labels which has been trained.
+	labelSet map[Label]struct{}
+
+	// for random unlearner
+	maxSize int
+	seed    int64
+	rg      *rand.Rand
+
+	m sync.RWMutex
+}
+
+// NNAlgorithm is an enum type which represents nearest neighbor algorithms.
+type NNAlgorithm = nearest.Algorithm
+
+const (
+	// InvalidNNAlgorithm represents an invalid nearest neighbor algorithm.
+	InvalidNNAlgorithm = nearest.InvalidAlgorithm
+	// LSH represents locality sensitive hashing.
+	LSH = nearest.LSHAlgorithm
+	// Minhash represents minhash.
+	Minhash = nearest.MinhashAlgorithm
+	// EuclidLSH represents locality sensitive hashing with euclidean distance.
+	EuclidLSH = nearest.EuclidLSHAlgorithm
+)
+
+// NewNearestNeighbor creates a NearestNeighbor model. nnNum is the number of
+// nearest rows which vote for labels. localSensitivity controls how much
+// distances affect votes. When it's zero, all nnNum rows have the same
+// weight. maxSize is the max number of rows. When the model has maxSize rows,
+// a row is randomly chosen and replaced with a new one. maxSize == 0 means the
+// model never unlearns rows.
+func NewNearestNeighbor(nnAlgo NNAlgorithm, hashNum, nnNum int, localSensitivity float32, maxSize int, seed int64) (*NearestNeighbor, error) {
+	const maxSizeLimit = 0x7fffffff
+
+	if hashNum <= 0 {
+		return nil, errors.New("number of hash bits must be greater than zero")
+	}
+	if nnNum <= 0 {
+		return nil, errors.New("number of nearest neighbor must be greater than zero")
+	}
+	if localSensitivity < 0 {
+		return nil, errors.New("local sensitivity must not be less than zero")
+	}
+	if maxSize < 0 {
+		return nil, errors.New("max size must be greater than or equal to zero")
+	}
+	if maxSize > maxSizeLimit {
+		return nil, fmt.Errorf("max size must be less than or equal to %v", maxSizeLimit)
+	}
+
+	nn, err := nearest.New(nnAlgo, hashNum)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxSize == 0 {
+		maxSize = maxSizeLimit
+	}
+
+	return &NearestNeighbor{
+		nn:               nn,
+		nnNum:            nnNum,
+		localSensitivity: localSensitivity,
+		labelSet:         make(map[Label]struct{}),
+		maxSize:          maxSize,
+		seed:             seed,
+		rg:               rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// Train adds a feature vector with a label to a model.
+func (n *NearestNeighbor) Train(v FeatureVector, label Label) error {
+	if label == "" {
+		return errors.New("label must not be empty")
+	}
+
+	nnfv, err := nearest.FeatureVectorFromMap(data.Map(v))
+	if err != nil {
+		return err
+	}
+
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	var id nearest.ID
+	if l := len(n.freeIDs); l > 0 {
+		id = n.freeIDs[l-1]
+		n.freeIDs = n.freeIDs[:l-1]
+		n.labels[id-1] = label
+	} else if len(n.labels) < n.maxSize {
+		n.labels = append(n.labels, label)
+		id = nearest.ID(len(n.labels))
+	} else {
+		// unlearn
+		id = nearest.ID(n.rg.Intn(n.maxSize)) + 1
+		n.labels[id-1] = label
+	}
+	n.nn.SetRow(id, nnfv)
+	n.labelSet[label] = struct{}{}
+	return nil
+}
+
+// Classify classifies a feature vector. This function returns
+// all labels and scores.
+// jubatus::core::classifier::nearest_neighbor_classifier::classify_with_scores
+func (n *NearestNeighbor) Classify(v FeatureVector) (LScores, error) {
+	nnfv, err := nearest.FeatureVectorFromMap(data.Map(v))
+	if err != nil {
+		return nil, err
+	}
+
+	n.m.RLock()
+	defer n.m.RUnlock()
+
+	votes := make(map[Label]float32, len(n.labelSet))
+	for l := range n.labelSet {
+		votes[l] = 0
+	}
+	// Rows of deleted labels can be included in the result, so extra rows
+	// are required.
+	voted := 0
+	for _, d := range n.nn.NeighborRowFromFV(nnfv, n.nnNum+len(n.freeIDs)) {
+		l := n.labels[d.ID-1]
+		if l == "" {
+			continue
+		}
+		votes[l] += float32(math.Exp(float64(-n.localSensitivity * d.Dist)))
+		voted++
+		if voted >= n.nnNum {
+			break
+		}
+	}
+
+	scores := make(LScores, len(votes))
+	for l, s := range votes {
+		scores[string(l)] = data.Float(s)
+	}
+	return scores, nil
+}
+
+// Clear clears a model.
+func (n *NearestNeighbor) Clear() {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	n.nn.Clear()
+	n.labels = nil
+	n.freeIDs = nil
+	n.labelSet = make(map[Label]struct{})
+}
+
+// Labels returns all labels the model has.
+func (n *NearestNeighbor) Labels() []Label {
+	n.m.RLock()
+	defer n.m.RUnlock()
+
+	ls := make([]Label, 0, len(n.labelSet))
+	for l := range n.labelSet {
+		ls = append(ls, l)
+	}
+	return ls
+}
+
+// SetLabel registers a label without rows. It returns false when the model
+// already has the label.
+func (n *NearestNeighbor) SetLabel(label Label) (bool, error) {
+	if label == "" {
+		return false, errors.New("label must not be empty")
+	}
+
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if _, ok := n.labelSet[label]; ok {
+		return false, nil
+	}
+	n.labelSet[label] = struct{}{}
+	return true, nil
+}
+
+// DeleteLabel deletes a label and all rows having it. It returns false when
+// the model doesn't have the label.
+func (n *NearestNeighbor) DeleteLabel(label Label) bool {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if _, ok := n.labelSet[label]; !ok {
+		return false
+	}
+	delete(n.labelSet, label)
+
+	// nearest.Neighbor cannot remove rows. Rows are marked as free instead.
+	for i, l := range n.labels {
+		if l == label {
+			n.labels[i] = ""
+			n.freeIDs = append(n.freeIDs, nearest.ID(i+1))
+		}
+	}
+	return true
+}
+
+const (
+	nearestNeighborFormatVersion uint8 = 2
+)
+
+type nearestNeighborMsgpack struct {
+	_struct struct{} `codec:",toarray"`
+
+	Labels           []Label
+	NNNum            int
+	LocalSensitivity float32
+	LabelSet         []Label
+
+	MaxSize int
+	Seed    int64
+}
+
+// Save saves the current state of NearestNeighbor.
+func (n *NearestNeighbor) Save(w io.Writer) error {
+	n.m.RLock()
+	defer n.m.RUnlock()
+
+	if _, err := w.Write([]byte{nearestNeighborFormatVersion}); err != nil {
+		return err
+	}
+
+	labelSet := make([]Label, 0, len(n.labelSet))
+	for l := range n.labelSet {
+		labelSet = append(labelSet, l)
+	}
+
+	enc := codec.NewEncoder(w, classifierMsgpackHandle)
+	if err := enc.Encode(&nearestNeighborMsgpack{
+		Labels:           n.labels,
+		NNNum:            n.nnNum,
+		LocalSensitivity: n.localSensitivity,
+		LabelSet:         labelSet,
+
+		MaxSize: n.maxSize,
+		Seed:    n.seed,
+	}); err != nil {
+		return err
+	}
+	return nearest.Save(n.nn, w)
+}
+
+// LoadNearestNeighbor loads NearestNeighbor from the saved data.
+func LoadNearestNeighbor(r io.Reader) (*NearestNeighbor, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadNearestNeighborFormatV1(r)
+	case 2:
+		return loadNearestNeighborFormatV2(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of NearestNeighbor container: %v", formatVersion[0])
+	}
+}
+
+func loadNearestNeighborFormatV1(r io.Reader) (*NearestNeighbor, error) {
+	// The format version 1 doesn't have the seed of the unlearner. The seed
+	// is zero in that case.
+	return loadNearestNeighbor(r)
+}
+
+func loadNearestNeighborFormatV2(r io.Reader) (*NearestNeighbor, error) {
+	return loadNearestNeighbor(r)
+}
+
+func loadNearestNeighbor(r io.Reader) (*NearestNeighbor, error) {
+	m := nearestNeighborMsgpack{}
+	dec := codec.NewDecoder(r, classifierMsgpackHandle)
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	nn, err := nearest.Load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	labelSet := make(map[Label]struct{}, len(m.LabelSet))
+	for _, l := range m.LabelSet {
+		labelSet[l] = struct{}{}
+	}
+	var freeIDs []nearest.ID
+	for i, l := range m.Labels {
+		if l == "" {
+			freeIDs = append(freeIDs, nearest.ID(i+1))
+		}
+	}
+
+	return &NearestNeighbor{
+		nn:               nn,
+		labels:           m.Labels,
+		nnNum:            m.NNNum,
+		freeIDs:          freeIDs,
+		localSensitivity: m.LocalSensitivity,
+		labelSet:         labelSet,
+
+		maxSize: m.MaxSize,
+		seed:    m.Seed,
+		rg:      rand.New(rand.NewSource(m.Seed)),
+	}, nil
+}
@@ -0,0 +1,40 @@
+package classifier
+
+import (
+	"fmt"
+	"github.com/zeromberto/jubatus/internal/nearest"
+	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+)
+
+// NearestNeighborStateCreator is used by BQL to create or load a State having
+// NearestNeighbor as a UDS.
+type NearestNeighborStateCreator struct {
+}
+
+var _ udf.UDSLoader = &NearestNeighborStateCreator{}
+
+// CreateState creates a new state for NearestNeighbor classifier.
+func (c *NearestNeighborStateCreator) CreateState(ctx *core.Context, params data.Map) (core.SharedState, error) {
+	nnParams, err := nearest.ExtractParams(params)
+	if err != nil {
+		return nil, err
+	}
+	localSensitivity, err := nearest.ExtractLocalSensitivity(params)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := NewNearestNeighbor(nnParams.Algorithm, nnParams.HashNum, nnParams.NNNum, localSensitivity, nnParams.MaxSize, nnParams.Seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize NearestNeighbor: %v", err)
+	}
+	return newState(params, "nearest_neighbor", n)
+}
+
+// LoadState loads a new state for NearestNeighbor classifier.
+func (c *NearestNeighborStateCreator) LoadState(ctx *core.Context, r io.Reader, params data.Map) (core.SharedState, error) {
+	return loadState(ctx, r, "nearest_neighbor")
+}
@@ -0,0 +1,70 @@
+package classifier
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestNearestNeighborStateSaveLoad(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := NearestNeighborStateCreator{}
+	as, err := c.CreateState(ctx, data.Map{
+		"nearest_neighbor_algorithm": data.String("euclid_lsh"),
+		"hash_num":                   data.Int(64),
+		"nearest_neighbor_num":       data.Int(5),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := as.(*State)
+
+	labels := []data.String{"a", "b", "c", "d"}
+	for i := 0; i < 100; i++ {
+		if err := a.Write(ctx, &core.Tuple{
+			Data: data.Map{
+				"label": labels[i%len(labels)],
+				"feature_vector": data.Map{
+					"n": data.Int(i),
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	Convey("Given a trained State having NearestNeighbor", t, func() {
+		Convey("when saving it", func() {
+			buf := bytes.NewBuffer(nil)
+			err := a.Save(ctx, buf, data.Map{})
+
+			Convey("it should succeed.", func() {
+				So(err, ShouldBeNil)
+
+				Convey("and the loaded state should be same.", func() {
+					a2, err := c.LoadState(ctx, buf, data.Map{})
+					So(err, ShouldBeNil)
+
+					m := a.classifier.(*NearestNeighbor)
+					m2 := a2.(*State).classifier.(*NearestNeighbor)
+					So(m2.nn, ShouldResemble, m.nn)
+					So(m2.labels, ShouldResemble, m.labels)
+					So(m2.nnNum, ShouldEqual, m.nnNum)
+					So(m2.localSensitivity, ShouldEqual, m.localSensitivity)
+					So(m2.labelSet, ShouldResemble, m.labelSet)
+					So(m2.maxSize, ShouldEqual, m.maxSize)
+					So(m2.rg, ShouldNotBeNil)
+
+					fv := FeatureVector(data.Map{"n": data.Int(10)})
+					s, err := a.classifier.Classify(fv)
+					So(err, ShouldBeNil)
+					s2, err := a2.(*State).classifier.Classify(fv)
+					So(err, ShouldBeNil)
+					So(s2, ShouldResemble, s)
+				})
+			})
+		})
+	})
+}
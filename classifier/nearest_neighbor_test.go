@@ -0,0 +1,80 @@
+package classifier
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestNearestNeighbor(t *testing.T) {
+	for _, algo := range []NNAlgorithm{LSH, Minhash, EuclidLSH} {
+		Convey(fmt.Sprintf("Given a NearestNeighbor classifier with algorithm %v", algo), t, func() {
+			n, err := NewNearestNeighbor(algo, 64, 3, 1, 0, 0)
+			So(err, ShouldBeNil)
+
+			for i := 0; i < 10; i++ {
+				So(n.Train(FeatureVector{"x": data.Float(1), "y": data.Float(0.1 * float64(i))}, "x"), ShouldBeNil)
+				So(n.Train(FeatureVector{"x": data.Float(0.1 * float64(i)), "y": data.Float(1)}, "y"), ShouldBeNil)
+			}
+
+			Convey("when classifying a feature vector close to rows of a label", func() {
+				scores, err := n.Classify(FeatureVector{"x": data.Float(1), "y": data.Float(0.05)})
+				So(err, ShouldBeNil)
+
+				Convey("it should return the label", func() {
+					l, _ := scores.Max()
+					So(l, ShouldEqual, "x")
+				})
+
+				Convey("it should return scores of all labels", func() {
+					So(len(scores), ShouldEqual, 2)
+				})
+			})
+
+			Convey("when clearing it", func() {
+				n.Clear()
+
+				Convey("classification should return no scores", func() {
+					scores, err := n.Classify(FeatureVector{"x": data.Float(1)})
+					So(err, ShouldBeNil)
+					So(scores, ShouldBeEmpty)
+				})
+			})
+		})
+	}
+
+	Convey("Given a NearestNeighbor classifier with random unlearner", t, func() {
+		n, err := NewNearestNeighbor(LSH, 64, 3, 1, 5, 42)
+		So(err, ShouldBeNil)
+
+		Convey("when adding more rows than max size", func() {
+			for i := 0; i < 20; i++ {
+				So(n.Train(FeatureVector{"x": data.Float(i)}, "x"), ShouldBeNil)
+			}
+
+			Convey("it shouldn't have more rows than max size", func() {
+				So(len(n.labels), ShouldEqual, 5)
+			})
+		})
+
+		Convey("when saving and loading it", func() {
+			buf := bytes.NewBuffer(nil)
+			So(n.Save(buf), ShouldBeNil)
+			n2, err := LoadNearestNeighbor(buf)
+			So(err, ShouldBeNil)
+
+			Convey("it should unlearn the same rows as the original one", func() {
+				So(n2.seed, ShouldEqual, 42)
+				for i := 0; i < 20; i++ {
+					fv := FeatureVector{"x": data.Float(i)}
+					l := Label(fmt.Sprint(i))
+					So(n.Train(fv, l), ShouldBeNil)
+					So(n2.Train(fv, l), ShouldBeNil)
+				}
+				So(n2.labels, ShouldResemble, n.labels)
+			})
+		})
+	})
+}
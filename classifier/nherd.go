@@ -0,0 +1,151 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/internal/intern"
+	"io"
+)
+
+// NHERD holds a model for classification using normal herd.
+type NHERD struct {
+	linearModel
+
+	regWeight float32
+}
+
+// NewNHERD creates a NHERD model. regWeight means sensitivity for data. When regWeight is large,
+// the model learns quickly but harms from noise. regWeight must be larger than zero.
+func NewNHERD(regWeight float32) (*NHERD, error) {
+	if regWeight <= 0 {
+		return nil, errors.New("regularization weight must be larger than zero")
+	}
+	return &NHERD{
+		linearModel: linearModel{model: make(model), intern: intern.New(), withCov: true},
+		regWeight:   regWeight,
+	}, nil
+}
+
+// Train trains a model with a feature vector and a label.
+func (n *NHERD) Train(v FeatureVector, label Label) error {
+	if label == "" {
+		return errors.New("label must not be empty")
+	}
+
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if _, ok := n.model[label]; !ok {
+		n.model[label] = make(weights)
+	}
+
+	fvForScores, fvFull, err := v.toInternal(n.intern)
+	if err != nil {
+		return err
+	}
+	scores := n.model.scores(fvForScores)
+	incorr, _ := scores.maxExcept(label)
+	margin := -scores.margin(label, incorr)
+	if margin >= 1 {
+		return nil
+	}
+	variance := variance(fvFull, n.model[label], n.model[incorr])
+
+	C := n.regWeight
+	step := (1 - margin) / (variance + 1/C)
+	covCoeff := 2*C + C*C*variance
+
+	var incorrWeights weights
+	if incorr != "" {
+		incorrWeights = n.model[incorr]
+	}
+	corrWeights := n.model[label]
+
+	for _, elem := range fvFull {
+		dim := elem.dim
+		x := elem.value
+
+		w := corrWeights.get(dim)
+		corrWeights[dim] = weight{
+			Weight:     w.Weight + step*x*w.Covariance,
+			Covariance: 1 / (1/w.Covariance + covCoeff*x*x),
+		}
+
+		if incorr != "" {
+			w := incorrWeights.get(dim)
+			incorrWeights[dim] = weight{
+				Weight:     w.Weight - step*x*w.Covariance,
+				Covariance: 1 / (1/w.Covariance + covCoeff*x*x),
+			}
+		}
+	}
+
+	return nil
+}
+
+var (
+	nherdFormatVersion uint8 = 1
+)
+
+type nherdMsgpack struct {
+	_struct   struct{} `codec:",toarray"`
+	Model     model
+	RegWeight float32
+}
+
+// Save saves the current state of NHERD.
+func (n *NHERD) Save(w io.Writer) error {
+	n.m.RLock()
+	defer n.m.RUnlock()
+
+	if _, err := w.Write([]byte{nherdFormatVersion}); err != nil {
+		return err
+	}
+
+	enc := codec.NewEncoder(w, classifierMsgpackHandle)
+	if err := enc.Encode(&nherdMsgpack{
+		Model:     n.model,
+		RegWeight: n.regWeight,
+	}); err != nil {
+		return err
+	}
+	return n.intern.Save(w)
+}
+
+// LoadNHERD loads NHERD from the saved data.
+func LoadNHERD(r io.Reader) (*NHERD, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadNHERDFormatV1(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of NHERD container: %v", formatVersion[0])
+	}
+}
+
+func loadNHERDFormatV1(r io.Reader) (*NHERD, error) {
+	m := nherdMsgpack{}
+	dec := codec.NewDecoder(r, classifierMsgpackHandle)
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	i, err := intern.Load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NHERD{
+		linearModel: linearModel{model: m.Model, intern: i, withCov: true},
+		regWeight:   m.RegWeight,
+	}, nil
+}
+
+// RegWeight returns regularization weight.
+func (n *NHERD) RegWeight() float32 {
+	return n.regWeight
+}
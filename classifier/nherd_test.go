@@ -0,0 +1,35 @@
+package classifier
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+func ExampleNHERD() {
+	shuffledShogunList := make([]shogun, len(shogunList))
+	perm := rand.Perm(len(shogunList))
+	for i, v := range perm {
+		shuffledShogunList[v] = shogunList[i]
+	}
+
+	var nherd, _ = NewNHERD(1)
+	for _, s := range shuffledShogunList {
+		fv := unigram(s.given)
+		nherd.Train(fv, Label(s.family))
+	}
+
+	scores, _ := nherd.Classify(unigram("慶喜"))
+	l, _ := scores.Max()
+	fmt.Println(l)
+	scores, _ = nherd.Classify(unigram("義昭"))
+	l, _ = scores.Max()
+	fmt.Println(l)
+	scores, _ = nherd.Classify(unigram("守時"))
+	l, _ = scores.Max()
+	fmt.Println(l)
+
+	// Output:
+	// 徳川
+	// 足利
+	// 北条
+}
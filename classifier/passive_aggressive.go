@@ -0,0 +1,191 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/internal/intern"
+	"io"
+	"math"
+)
+
+// PassiveAggressive holds a model for classification. It supports PA, PA1,
+// and PA2 algorithms.
+type PassiveAggressive struct {
+	linearModel
+
+	variant   PAVariant
+	regWeight float32
+}
+
+const (
+	// InvalidPAVariant represents an invalid variant of passive aggressive.
+	InvalidPAVariant PAVariant = iota
+	// PA represents the original passive aggressive algorithm.
+	PA
+	// PA1 represents passive aggressive with the step size bounded by
+	// regularization weight.
+	PA1
+	// PA2 represents passive aggressive with the step size smoothed by
+	// regularization weight.
+	PA2
+)
+
+// PAVariant is an enum type which represents variants of passive aggressive
+// algorithm.
+type PAVariant int
+
+// String returns the name of the variant. The name is also used as an
+// algorithm name of a saved state.
+func (v PAVariant) String() string {
+	switch v {
+	case PA:
+		return "pa"
+	case PA1:
+		return "pa1"
+	case PA2:
+		return "pa2"
+	default:
+		return "invalid"
+	}
+}
+
+// NewPassiveAggressive creates a PassiveAggressive model. regWeight means
+// sensitivity for data and is only used by PA1 and PA2. When regWeight is
+// large, the model learns quickly but harms from noise. regWeight must be
+// larger than zero for PA1 and PA2.
+func NewPassiveAggressive(variant PAVariant, regWeight float32) (*PassiveAggressive, error) {
+	switch variant {
+	case PA:
+	case PA1, PA2:
+		if regWeight <= 0 {
+			return nil, errors.New("regularization weight must be larger than zero")
+		}
+	default:
+		return nil, errors.New("invalid passive aggressive variant")
+	}
+	return &PassiveAggressive{
+		linearModel: linearModel{model: make(model), intern: intern.New()},
+		variant:     variant,
+		regWeight:   regWeight,
+	}, nil
+}
+
+// Train trains a model with a feature vector and a label.
+func (p *PassiveAggressive) Train(v FeatureVector, label Label) error {
+	if label == "" {
+		return errors.New("label must not be empty")
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if _, ok := p.model[label]; !ok {
+		p.model[label] = make(weights)
+	}
+
+	fvForScores, fvFull, err := v.toInternal(p.intern)
+	if err != nil {
+		return err
+	}
+	scores := p.model.scores(fvForScores)
+	incorr, _ := scores.maxExcept(label)
+	loss := 1 + scores.margin(label, incorr)
+	if loss < 0 {
+		return nil
+	}
+
+	// zero vector generates inf or nan.
+	norm := fvFull.squaredNorm()
+	if norm == 0 {
+		return nil
+	}
+
+	p.model.update(fvFull, p.stepWidth(loss, norm), label, incorr)
+	return nil
+}
+
+func (p *PassiveAggressive) stepWidth(loss, squaredNorm float32) float32 {
+	switch p.variant {
+	case PA1:
+		return float32(math.Min(float64(p.regWeight), float64(loss/(2*squaredNorm))))
+	case PA2:
+		return loss / (2*squaredNorm + 1/(2*p.regWeight))
+	default:
+		return loss / (2 * squaredNorm)
+	}
+}
+
+var (
+	paFormatVersion uint8 = 1
+)
+
+type paMsgpack struct {
+	_struct   struct{} `codec:",toarray"`
+	Model     model
+	Variant   PAVariant
+	RegWeight float32
+}
+
+// Save saves the current state of PassiveAggressive.
+func (p *PassiveAggressive) Save(w io.Writer) error {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	if _, err := w.Write([]byte{paFormatVersion}); err != nil {
+		return err
+	}
+
+	enc := codec.NewEncoder(w, classifierMsgpackHandle)
+	if err := enc.Encode(&paMsgpack{
+		Model:     p.model,
+		Variant:   p.variant,
+		RegWeight: p.regWeight,
+	}); err != nil {
+		return err
+	}
+	return p.intern.Save(w)
+}
+
+// LoadPassiveAggressive loads PassiveAggressive from the saved data.
+func LoadPassiveAggressive(r io.Reader) (*PassiveAggressive, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadPassiveAggressiveFormatV1(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of PassiveAggressive container: %v", formatVersion[0])
+	}
+}
+
+func loadPassiveAggressiveFormatV1(r io.Reader) (*PassiveAggressive, error) {
+	m := paMsgpack{}
+	dec := codec.NewDecoder(r, classifierMsgpackHandle)
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	i, err := intern.Load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PassiveAggressive{
+		linearModel: linearModel{model: m.Model, intern: i},
+		variant:     m.Variant,
+		regWeight:   m.RegWeight,
+	}, nil
+}
+
+// Variant returns the variant of passive aggressive algorithm.
+func (p *PassiveAggressive) Variant() PAVariant {
+	return p.variant
+}
+
+// RegWeight returns regularization weight.
+func (p *PassiveAggressive) RegWeight() float32 {
+	return p.regWeight
+}
@@ -0,0 +1,46 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+)
+
+// PassiveAggressiveStateCreator is used by BQL to create or load a State
+// having PassiveAggressive as a UDS. Variant decides which variant of
+// passive aggressive algorithm the state uses.
+type PassiveAggressiveStateCreator struct {
+	Variant PAVariant
+}
+
+var _ udf.UDSLoader = &PassiveAggressiveStateCreator{}
+
+// CreateState creates a new state for PassiveAggressive classifier.
+func (c *PassiveAggressiveStateCreator) CreateState(ctx *core.Context, params data.Map) (core.SharedState, error) {
+	var rw float64
+	if c.Variant != PA {
+		var err error
+		rw, err = pluginutil.ExtractParamAndConvertToFloat(params, "regularization_weight")
+		if err != nil {
+			return nil, err
+		}
+		if rw <= 0 {
+			return nil, errors.New("regularization_weight parameter must be greater than zero")
+		}
+	}
+
+	p, err := NewPassiveAggressive(c.Variant, float32(rw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize PassiveAggressive: %v", err)
+	}
+	return newState(params, c.Variant.String(), p)
+}
+
+// LoadState loads a new state for PassiveAggressive classifier.
+func (c *PassiveAggressiveStateCreator) LoadState(ctx *core.Context, r io.Reader, params data.Map) (core.SharedState, error) {
+	return loadState(ctx, r, c.Variant.String())
+}
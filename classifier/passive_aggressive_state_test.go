@@ -0,0 +1,69 @@
+package classifier
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestPassiveAggressiveStateSaveLoad(t *testing.T) {
+	ctx := core.NewContext(nil)
+
+	for _, v := range []PAVariant{PA, PA1, PA2} {
+		c := PassiveAggressiveStateCreator{Variant: v}
+		ps, err := c.CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(0.001),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := ps.(*State)
+
+		labels := []data.String{"a", "b", "c", "d"}
+		for i := 0; i < 100; i++ {
+			if err := p.Write(ctx, &core.Tuple{
+				Data: data.Map{
+					"label": labels[i%len(labels)],
+					"feature_vector": data.Map{
+						"n": data.Int(i),
+					},
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		Convey(fmt.Sprintf("Given a trained State having %v", v), t, func() {
+			Convey("when saving it", func() {
+				buf := bytes.NewBuffer(nil)
+				err := p.Save(ctx, buf, data.Map{})
+
+				Convey("it should succeed.", func() {
+					So(err, ShouldBeNil)
+
+					Convey("and the loaded state should be same.", func() {
+						p2, err := c.LoadState(ctx, buf, data.Map{})
+						So(err, ShouldBeNil)
+						So(p2, ShouldResemble, p)
+
+						fv := FeatureVector(data.Map{"n": data.Int(10)})
+						s, err := p.classifier.Classify(fv)
+						So(err, ShouldBeNil)
+						s2, err := p2.(*State).classifier.Classify(fv)
+						So(err, ShouldBeNil)
+						So(s2, ShouldResemble, s)
+					})
+
+					Convey("and it shouldn't be loaded as another variant.", func() {
+						c2 := PassiveAggressiveStateCreator{Variant: v%3 + 1}
+						_, err := c2.LoadState(ctx, buf, data.Map{})
+						So(err, ShouldNotBeNil)
+					})
+				})
+			})
+		})
+	}
+}
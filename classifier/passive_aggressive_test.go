@@ -0,0 +1,41 @@
+package classifier
+
+import (
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestPassiveAggressive(t *testing.T) {
+	for _, v := range []PAVariant{PA, PA1, PA2} {
+		Convey(fmt.Sprintf("Given a %v classifier trained with shogun names", v), t, func() {
+			p, err := NewPassiveAggressive(v, 1)
+			So(err, ShouldBeNil)
+			trainShoguns(p, 1)
+
+			Convey("when classifying unknown names", func() {
+				Convey("it should return correct families", func() {
+					shouldClassifyShoguns(p)
+				})
+			})
+		})
+	}
+
+	Convey("Given invalid parameters", t, func() {
+		Convey("when creating PA1 with a non-positive regularization weight", func() {
+			_, err := NewPassiveAggressive(PA1, 0)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when creating an invalid variant", func() {
+			_, err := NewPassiveAggressive(InvalidPAVariant, 1)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/internal/intern"
+	"io"
+)
+
+// Perceptron holds a model for classification.
+type Perceptron struct {
+	linearModel
+}
+
+// NewPerceptron creates a Perceptron model.
+func NewPerceptron() *Perceptron {
+	return &Perceptron{
+		linearModel: linearModel{model: make(model), intern: intern.New()},
+	}
+}
+
+// Train trains a model with a feature vector and a label.
+func (p *Perceptron) Train(v FeatureVector, label Label) error {
+	if label == "" {
+		return errors.New("label must not be empty")
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if _, ok := p.model[label]; !ok {
+		p.model[label] = make(weights)
+	}
+
+	fvForScores, fvFull, err := v.toInternal(p.intern)
+	if err != nil {
+		return err
+	}
+	scores := p.model.scores(fvForScores)
+	incorr, _ := scores.maxExcept(label)
+
+	// A tie between the correct label and an incorrect label is regarded as
+	// a mistake so that the result doesn't depend on the order of labels.
+	if scores.margin(label, incorr) < 0 {
+		return nil
+	}
+
+	p.model.update(fvFull, 1, label, incorr)
+	return nil
+}
+
+var (
+	perceptronFormatVersion uint8 = 1
+)
+
+type perceptronMsgpack struct {
+	_struct struct{} `codec:",toarray"`
+	Model   model
+}
+
+// Save saves the current state of Perceptron.
+func (p *Perceptron) Save(w io.Writer) error {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	if _, err := w.Write([]byte{perceptronFormatVersion}); err != nil {
+		return err
+	}
+
+	enc := codec.NewEncoder(w, classifierMsgpackHandle)
+	if err := enc.Encode(&perceptronMsgpack{
+		Model: p.model,
+	}); err != nil {
+		return err
+	}
+	return p.intern.Save(w)
+}
+
+// LoadPerceptron loads Perceptron from the saved data.
+func LoadPerceptron(r io.Reader) (*Perceptron, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadPerceptronFormatV1(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of Perceptron container: %v", formatVersion[0])
+	}
+}
+
+func loadPerceptronFormatV1(r io.Reader) (*Perceptron, error) {
+	m := perceptronMsgpack{}
+	dec := codec.NewDecoder(r, classifierMsgpackHandle)
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	i, err := intern.Load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Perceptron{
+		linearModel: linearModel{model: m.Model, intern: i},
+	}, nil
+}
@@ -0,0 +1,57 @@
+package classifier
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestPerceptronStateSaveLoad(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := PerceptronStateCreator{}
+	ps, err := c.CreateState(ctx, data.Map{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := ps.(*State)
+
+	labels := []data.String{"a", "b", "c", "d"}
+	for i := 0; i < 100; i++ {
+		if err := p.Write(ctx, &core.Tuple{
+			Data: data.Map{
+				"label": labels[i%len(labels)],
+				"feature_vector": data.Map{
+					"n": data.Int(i),
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	Convey("Given a trained State having Perceptron", t, func() {
+		Convey("when saving it", func() {
+			buf := bytes.NewBuffer(nil)
+			err := p.Save(ctx, buf, data.Map{})
+
+			Convey("it should succeed.", func() {
+				So(err, ShouldBeNil)
+
+				Convey("and the loaded state should be same.", func() {
+					p2, err := c.LoadState(ctx, buf, data.Map{})
+					So(err, ShouldBeNil)
+					So(p2, ShouldResemble, p)
+
+					fv := FeatureVector(data.Map{"n": data.Int(10)})
+					s, err := p.classifier.Classify(fv)
+					So(err, ShouldBeNil)
+					s2, err := p2.(*State).classifier.Classify(fv)
+					So(err, ShouldBeNil)
+					So(s2, ShouldResemble, s)
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,32 @@
+package classifier
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestPerceptron(t *testing.T) {
+	Convey("Given a Perceptron classifier trained with shogun names", t, func() {
+		p := NewPerceptron()
+		trainShoguns(p, 10)
+
+		Convey("when classifying the trained names", func() {
+			Convey("it should return correct families", func() {
+				for _, s := range shogunList {
+					scores, err := p.Classify(unigram(s.given))
+					So(err, ShouldBeNil)
+					l, _ := scores.Max()
+					So(l, ShouldEqual, s.family)
+				}
+			})
+		})
+
+		Convey("when training it with an empty label", func() {
+			err := p.Train(unigram("家康"), "")
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -8,12 +8,26 @@ import (
 
 func init() {
 	udf.MustRegisterGlobalUDSCreator("jubaclassifier_arow", &classifier.AROWStateCreator{})
+	udf.MustRegisterGlobalUDSCreator("jubaclassifier_perceptron", &classifier.PerceptronStateCreator{})
+	udf.MustRegisterGlobalUDSCreator("jubaclassifier_pa", &classifier.PassiveAggressiveStateCreator{Variant: classifier.PA})
+	udf.MustRegisterGlobalUDSCreator("jubaclassifier_pa1", &classifier.PassiveAggressiveStateCreator{Variant: classifier.PA1})
+	udf.MustRegisterGlobalUDSCreator("jubaclassifier_pa2", &classifier.PassiveAggressiveStateCreator{Variant: classifier.PA2})
+	udf.MustRegisterGlobalUDSCreator("jubaclassifier_cw", &classifier.CWStateCreator{})
+	udf.MustRegisterGlobalUDSCreator("jubaclassifier_nherd", &classifier.NHERDStateCreator{})
+	udf.MustRegisterGlobalUDSCreator("jubaclassifier_nn", &classifier.NearestNeighborStateCreator{})
 
-	// The name jubaclassify is not only for AROW, but for all classifier algorithms.
-	// We have implemented only AROW, so we use the name for arowClassify for now.
-	// When we have to implement another classification algorithm, generalize jubaclassify
-	// to other algorithms. For example, define classifier.Classifier and adjust all algorithms to it.
-	udf.MustRegisterGlobalUDF("jubaclassify", udf.MustConvertGeneric(classifier.AROWClassify))
+	// jubaclassify works with states of all classification algorithms.
+	udf.MustRegisterGlobalUDF("jubaclassify", udf.MustConvertGeneric(classifier.Classify))
+	udf.MustRegisterGlobalUDF("jubaclassify_top_k", udf.MustConvertGeneric(classifier.ClassifyTopK))
+	udf.MustRegisterGlobalUDF("jubaclassify_multi_label", udf.MustConvertGeneric(classifier.ClassifyMultiLabel))
+	udf.MustRegisterGlobalUDF("jubaclassify_explain", udf.MustConvertGeneric(classifier.ClassifyExplain))
+
+	udf.MustRegisterGlobalUDF("jubaclassifier_labels", udf.MustConvertGeneric(classifier.Labels))
+	udf.MustRegisterGlobalUDF("jubaclassifier_set_label", udf.MustConvertGeneric(classifier.SetLabel))
+	udf.MustRegisterGlobalUDF("jubaclassifier_delete_label", udf.MustConvertGeneric(classifier.DeleteLabel))
+	udf.MustRegisterGlobalUDF("jubaclassifier_weights", udf.MustConvertGeneric(classifier.FeatureWeights))
+	udf.MustRegisterGlobalUDF("jubaclassifier_active_dimensions", udf.MustConvertGeneric(classifier.ActiveDimensions))
+	udf.MustRegisterGlobalUDF("jubaclassifier_prune", udf.MustConvertGeneric(classifier.Prune))
 
 	// TODO: consider to rename
 	udf.MustRegisterGlobalUDF("juba_classified_label", udf.MustConvertGeneric(classifier.ClassifiedLabel))
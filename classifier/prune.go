@@ -0,0 +1,148 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"sync/atomic"
+)
+
+// pruner prunes a linear model every time the given number of tuples is
+// written to a State.
+type pruner struct {
+	// count is the number of writes since the state was created or loaded.
+	// It must be accessed atomically and placed first to be 64-bit aligned.
+	count uint64
+
+	// interval is the number of writes between prunings. Pruning is disabled
+	// when it's zero.
+	interval  uint64
+	threshold float32
+}
+
+func newDefaultPruner() *pruner {
+	return &pruner{}
+}
+
+// newPruner creates a pruner from parameters of a UDS.
+func newPruner(params data.Map, algorithm string, c Classifier) (*pruner, error) {
+	p := newDefaultPruner()
+
+	interval, err := pluginutil.ExtractParamAsIntWithDefault(params, "prune_interval", 0)
+	if err != nil {
+		return nil, err
+	}
+	if interval < 0 {
+		return nil, errors.New("prune_interval parameter must not be less than zero")
+	}
+	if interval > 0 {
+		if _, ok := c.(LinearClassifier); !ok {
+			return nil, fmt.Errorf("%v doesn't support prune_interval parameter", algorithm)
+		}
+	}
+	p.interval = uint64(interval)
+
+	if _, ok := params["prune_threshold"]; ok {
+		t, err := pluginutil.ExtractParamAndConvertToFloat(params, "prune_threshold")
+		if err != nil {
+			return nil, err
+		}
+		if t < 0 {
+			return nil, errors.New("prune_threshold parameter must not be less than zero")
+		}
+		p.threshold = float32(t)
+	}
+	return p, nil
+}
+
+// tick counts a write and prunes c when the count reaches the interval.
+func (p *pruner) tick(c Classifier) {
+	if p.interval == 0 {
+		return
+	}
+	if atomic.AddUint64(&p.count, 1)%p.interval != 0 {
+		return
+	}
+	if lc, ok := c.(LinearClassifier); ok {
+		lc.Prune(p.threshold)
+	}
+}
+
+const (
+	prunerFormatVersion uint8 = 1
+)
+
+type prunerMsgpack struct {
+	_struct   struct{} `codec:",toarray"`
+	Interval  uint64
+	Threshold float32
+}
+
+func (p *pruner) save(w io.Writer) error {
+	if _, err := w.Write([]byte{prunerFormatVersion}); err != nil {
+		return err
+	}
+
+	enc := codec.NewEncoder(w, classifierMsgpackHandle)
+	return enc.Encode(&prunerMsgpack{
+		Interval:  p.interval,
+		Threshold: p.threshold,
+	})
+}
+
+func loadPruner(r io.Reader) (*pruner, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadPrunerFormatV1(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of pruner container: %v", formatVersion[0])
+	}
+}
+
+func loadPrunerFormatV1(r io.Reader) (*pruner, error) {
+	var d prunerMsgpack
+	dec := codec.NewDecoder(r, classifierMsgpackHandle)
+	if err := dec.Decode(&d); err != nil {
+		return nil, err
+	}
+	return &pruner{
+		interval:  d.Interval,
+		threshold: d.Threshold,
+	}, nil
+}
+
+// Prune removes weights whose absolute values aren't greater than threshold
+// from the linear model the state having stateName has. For algorithms
+// learning covariance, i.e. AROW, CW and NHERD, weights whose covariance is
+// still the initial value are also removed. Feature names which no weight
+// refers to are removed afterwards. It returns a map having the number of
+// removed weights as "weights" and the number of removed feature names as
+// "features".
+func Prune(ctx *core.Context, stateName string, threshold float64) (data.Map, error) {
+	if threshold < 0 {
+		return nil, errors.New("threshold must not be less than zero")
+	}
+	s, err := lookupState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+	lc, ok := s.classifier.(LinearClassifier)
+	if !ok {
+		return nil, fmt.Errorf("%v doesn't have a linear model", s.algorithm)
+	}
+
+	ws, fs := lc.Prune(float32(threshold))
+	return data.Map{
+		"weights":  data.Int(ws),
+		"features": data.Int(fs),
+	}, nil
+}
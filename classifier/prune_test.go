@@ -0,0 +1,144 @@
+package classifier
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	Convey("Given a trained Perceptron", t, func() {
+		p := NewPerceptron()
+		So(p.Train(FeatureVector{"a": data.Float(1), "b": data.Float(0.1)}, "x"), ShouldBeNil)
+		So(p.Train(FeatureVector{"c": data.Float(1)}, "y"), ShouldBeNil)
+
+		Convey("when pruning it", func() {
+			ws, fs := p.Prune(0.5)
+
+			Convey("small weights should be removed", func() {
+				So(ws, ShouldBeGreaterThan, 0)
+				for _, l := range p.Labels() {
+					w, _ := p.Weights(l)
+					for _, fw := range w {
+						So(fw.Feature, ShouldNotEqual, "b")
+					}
+				}
+			})
+
+			Convey("unused feature names should be removed", func() {
+				So(fs, ShouldEqual, 1)
+				So(p.intern.GetOrZero("b"), ShouldEqual, 0)
+				So(p.intern.GetOrZero("a"), ShouldNotEqual, 0)
+			})
+		})
+	})
+
+	Convey("Given an AROW having an untrained weight", t, func() {
+		a, err := NewAROW(1)
+		So(err, ShouldBeNil)
+		So(a.Train(FeatureVector{"a": data.Float(1)}, "x"), ShouldBeNil)
+		a.model["x"][dim(a.intern.Get("b"))] = initialWeight()
+
+		Convey("when pruning it with zero threshold", func() {
+			ws, fs := a.Prune(0)
+
+			Convey("the weight having the initial covariance should be removed", func() {
+				So(ws, ShouldEqual, 1)
+				So(fs, ShouldEqual, 1)
+				So(a.ActiveDims(), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestPruneState(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := PerceptronStateCreator{}
+
+	Convey("Given a state pruned periodically", t, func() {
+		s, err := c.CreateState(ctx, data.Map{
+			"prune_interval":  data.Int(2),
+			"prune_threshold": data.Float(0.5),
+		})
+		So(err, ShouldBeNil)
+		st := s.(*State)
+		p := st.classifier.(*Perceptron)
+
+		Convey("when writing a tuple", func() {
+			So(st.Write(ctx, &core.Tuple{Data: data.Map{
+				"label":          data.String("x"),
+				"feature_vector": data.Map{"a": data.Float(1), "b": data.Float(0.1)},
+			}}), ShouldBeNil)
+
+			Convey("it shouldn't be pruned yet", func() {
+				So(p.intern.GetOrZero("b"), ShouldNotEqual, 0)
+			})
+
+			Convey("and writing another tuple", func() {
+				So(st.Write(ctx, &core.Tuple{Data: data.Map{
+					"label":          data.String("y"),
+					"feature_vector": data.Map{"c": data.Float(1)},
+				}}), ShouldBeNil)
+
+				Convey("it should be pruned", func() {
+					So(p.intern.GetOrZero("b"), ShouldEqual, 0)
+				})
+			})
+		})
+
+		Convey("when saving it", func() {
+			buf := bytes.NewBuffer(nil)
+			So(st.Save(ctx, buf, data.Map{}), ShouldBeNil)
+
+			Convey("the loaded state should have the same setting", func() {
+				s2, err := c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				So(s2.(*State).pruner, ShouldResemble, st.pruner)
+			})
+		})
+	})
+
+	Convey("Given a state registered to the context", t, func() {
+		s, err := c.CreateState(ctx, data.Map{})
+		So(err, ShouldBeNil)
+		So(ctx.SharedStates.Add("prune", "jubaclassifier_perceptron", s), ShouldBeNil)
+		Reset(func() {
+			ctx.SharedStates.Remove("prune")
+		})
+		So(s.(*State).Write(ctx, &core.Tuple{Data: data.Map{
+			"label":          data.String("x"),
+			"feature_vector": data.Map{"a": data.Float(1), "b": data.Float(0.1)},
+		}}), ShouldBeNil)
+
+		Convey("when pruning it by the UDF", func() {
+			res, err := Prune(ctx, "prune", 0.5)
+
+			Convey("it should report the numbers of removed weights and features", func() {
+				So(err, ShouldBeNil)
+				So(res, ShouldResemble, data.Map{
+					"weights":  data.Int(1),
+					"features": data.Int(1),
+				})
+			})
+		})
+	})
+
+	Convey("Given NearestNeighborStateCreator", t, func() {
+		c := NearestNeighborStateCreator{}
+
+		Convey("when creating a state with prune_interval", func() {
+			_, err := c.CreateState(ctx, data.Map{
+				"nearest_neighbor_algorithm": data.String("lsh"),
+				"hash_num":                   data.Int(64),
+				"nearest_neighbor_num":       data.Int(3),
+				"prune_interval":             data.Int(10),
+			})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,653 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/fvconv"
+	"github.com/zeromberto/jubatus/internal/pipeline"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"github.com/zeromberto/jubatus/mix"
+	"github.com/zeromberto/jubatus/normalizer"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// classfierMsgpack has information of the saved file.
+type classifierMsgpack struct {
+	_struct   struct{} `codec:",toarray"`
+	Algorithm string
+}
+
+// State is a state which holds a model of a classification algorithm. The
+// same state type is used for all algorithms so that UDFs such as
+// jubaclassify can handle any of them.
+type State struct {
+	classifier         Classifier
+	algorithm          string
+	labelField         string
+	featureVectorField string
+	calibrator         *calibrator
+	pruner             *pruner
+
+	// converter converts raw values into feature vectors. Values are used
+	// as feature vectors when it's nil.
+	converter *fvconv.Converter
+
+	// normalizer normalizes feature vectors. They aren't normalized when
+	// it's nil.
+	normalizer *normalizer.Normalizer
+}
+
+var (
+	_ core.SavableSharedState = &State{}
+	_ mix.RemoteMixer         = &State{}
+)
+
+type stateMsgpack struct {
+	_struct            struct{} `codec:",toarray"`
+	LabelField         string
+	FeatureVectorField string
+}
+
+// newState creates a new State holding c. It extracts parameters common to
+// all classification algorithms from params.
+func newState(params data.Map, algorithm string, c Classifier) (*State, error) {
+	label, err := pluginutil.ExtractParamAsStringWithDefault(params, "label_field", "label")
+	if err != nil {
+		return nil, err
+	}
+	fv, err := pluginutil.ExtractParamAsStringWithDefault(params, "feature_vector_field", "feature_vector")
+	if err != nil {
+		return nil, err
+	}
+	cal, err := newCalibrator(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := setFeatureHashing(params, algorithm, c); err != nil {
+		return nil, err
+	}
+	p, err := newPruner(params, algorithm, c)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := fvconv.NewFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+	norm, err := normalizer.NewFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &State{
+		classifier:         c,
+		algorithm:          algorithm,
+		labelField:         label,
+		featureVectorField: fv,
+		calibrator:         cal,
+		pruner:             p,
+		converter:          conv,
+		normalizer:         norm,
+	}, nil
+}
+
+// setFeatureHashing enables feature hashing of c when hash_max_size
+// parameter is given. Only linear classifiers support feature hashing.
+func setFeatureHashing(params data.Map, algorithm string, c Classifier) error {
+	if _, ok := params["hash_max_size"]; !ok {
+		return nil
+	}
+	size, err := pluginutil.ExtractParamAsInt(params, "hash_max_size")
+	if err != nil {
+		return err
+	}
+	if size <= 0 {
+		return errors.New("hash_max_size parameter must be greater than zero")
+	}
+	lc, ok := c.(LinearClassifier)
+	if !ok {
+		return fmt.Errorf("%v doesn't support hash_max_size parameter", algorithm)
+	}
+	return lc.EnableFeatureHashing(int(size))
+}
+
+var (
+	classifierMsgpackHandle = &codec.MsgpackHandle{
+		RawToString: true,
+	}
+)
+
+func init() {
+	classifierMsgpackHandle.MapType = reflect.TypeOf(map[string]interface{}{})
+}
+
+// loadState loads a state saved by State.Save. algorithm is the name of the
+// algorithm which the caller expects.
+func loadState(ctx *core.Context, r io.Reader, algorithm string) (*State, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadStateFormatV1(ctx, r, algorithm)
+	case 2:
+		return loadStateFormatV2(ctx, r, algorithm)
+	case 3:
+		return loadStateFormatV3(ctx, r, algorithm)
+	case 4:
+		return loadStateFormatV4(ctx, r, algorithm)
+	case 5:
+		return loadStateFormatV5(ctx, r, algorithm)
+	default:
+		return nil, fmt.Errorf("unsupported format version of classifier state container: %v", formatVersion[0])
+	}
+}
+
+func loadStateFormatV1(ctx *core.Context, r io.Reader, algorithm string) (*State, error) {
+	s, err := loadStateHeader(r, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	// The format version 1 doesn't have a calibrator and a pruner.
+	s.calibrator = newDefaultCalibrator()
+	s.pruner = newDefaultPruner()
+
+	c, err := loadClassifier(s.algorithm, r)
+	if err != nil {
+		return nil, err
+	}
+	s.classifier = c
+	return s, nil
+}
+
+func loadStateFormatV2(ctx *core.Context, r io.Reader, algorithm string) (*State, error) {
+	s, err := loadStateHeader(r, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	cal, err := loadCalibrator(r)
+	if err != nil {
+		return nil, err
+	}
+	s.calibrator = cal
+
+	// The format version 2 doesn't have a pruner.
+	s.pruner = newDefaultPruner()
+
+	c, err := loadClassifier(s.algorithm, r)
+	if err != nil {
+		return nil, err
+	}
+	s.classifier = c
+	return s, nil
+}
+
+func loadStateFormatV3(ctx *core.Context, r io.Reader, algorithm string) (*State, error) {
+	s, err := loadStateHeader(r, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	// The format version 3 doesn't have a converter.
+	cal, err := loadCalibrator(r)
+	if err != nil {
+		return nil, err
+	}
+	s.calibrator = cal
+
+	p, err := loadPruner(r)
+	if err != nil {
+		return nil, err
+	}
+	s.pruner = p
+
+	c, err := loadClassifier(s.algorithm, r)
+	if err != nil {
+		return nil, err
+	}
+	s.classifier = c
+	return s, nil
+}
+
+func loadStateFormatV4(ctx *core.Context, r io.Reader, algorithm string) (*State, error) {
+	s, err := loadStateHeader(r, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	// The format version 4 doesn't have a normalizer.
+	cal, err := loadCalibrator(r)
+	if err != nil {
+		return nil, err
+	}
+	s.calibrator = cal
+
+	p, err := loadPruner(r)
+	if err != nil {
+		return nil, err
+	}
+	s.pruner = p
+
+	conv, err := fvconv.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.converter = conv
+
+	c, err := loadClassifier(s.algorithm, r)
+	if err != nil {
+		return nil, err
+	}
+	s.classifier = c
+	return s, nil
+}
+
+func loadStateFormatV5(ctx *core.Context, r io.Reader, algorithm string) (*State, error) {
+	s, err := loadStateHeader(r, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	// This is the current format and no data type conversion is required.
+	cal, err := loadCalibrator(r)
+	if err != nil {
+		return nil, err
+	}
+	s.calibrator = cal
+
+	p, err := loadPruner(r)
+	if err != nil {
+		return nil, err
+	}
+	s.pruner = p
+
+	conv, err := fvconv.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.converter = conv
+
+	norm, err := normalizer.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.normalizer = norm
+
+	c, err := loadClassifier(s.algorithm, r)
+	if err != nil {
+		return nil, err
+	}
+	s.classifier = c
+	return s, nil
+}
+
+// loadStateHeader loads the algorithm name and parameters of a State. This
+// part is common to all format versions.
+func loadStateHeader(r io.Reader, algorithm string) (*State, error) {
+	var header classifierMsgpack
+	dec := codec.NewDecoder(r, classifierMsgpackHandle)
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Algorithm != algorithm {
+		return nil, fmt.Errorf("unsupported classification algorithm: %v", header.Algorithm)
+	}
+
+	var d stateMsgpack
+	if err := dec.Decode(&d); err != nil {
+		return nil, err
+	}
+	return &State{
+		algorithm:          header.Algorithm,
+		labelField:         d.LabelField,
+		featureVectorField: d.FeatureVectorField,
+	}, nil
+}
+
+func loadClassifier(algorithm string, r io.Reader) (Classifier, error) {
+	switch algorithm {
+	case "arow":
+		return LoadAROW(r)
+	case "perceptron":
+		return LoadPerceptron(r)
+	case "pa", "pa1", "pa2":
+		return LoadPassiveAggressive(r)
+	case "cw":
+		return LoadCW(r)
+	case "nherd":
+		return LoadNHERD(r)
+	case "nearest_neighbor":
+		return LoadNearestNeighbor(r)
+	default:
+		return nil, fmt.Errorf("unsupported classification algorithm: %v", algorithm)
+	}
+}
+
+// Terminate terminates the state.
+func (*State) Terminate(ctx *core.Context) error {
+	return nil
+}
+
+// Write trains the machine learning model the state has with a given tuple.
+// The label field can be an array of strings when the state has a model for
+// multi-label classification.
+func (s *State) Write(ctx *core.Context, t *core.Tuple) error {
+	vlabel, ok := t.Data[s.labelField]
+	if !ok {
+		return fmt.Errorf("%s field is missing", s.labelField)
+	}
+
+	vfv, ok := t.Data[s.featureVectorField]
+	if !ok {
+		return fmt.Errorf("%s field is missing", s.featureVectorField)
+	}
+	m, err := data.AsMap(vfv)
+	if err != nil {
+		return fmt.Errorf("%s value is not a map: %v", s.featureVectorField, err)
+	}
+	fv, err := s.featureVector(m, true)
+	if err != nil {
+		return err
+	}
+
+	if vlabel.Type() == data.TypeArray {
+		err = s.trainMultiLabel(fv, vlabel)
+	} else {
+		err = s.train(fv, vlabel)
+	}
+	if err != nil {
+		return err
+	}
+	s.pruner.tick(s.classifier)
+	return nil
+}
+
+// featureVector converts a value into a feature vector with the converter
+// and the normalizer of the state.
+func (s *State) featureVector(v data.Map, training bool) (FeatureVector, error) {
+	fv, err := pipeline.FeatureVector(s.converter, s.normalizer, v, training)
+	if err != nil {
+		return nil, err
+	}
+	return FeatureVector(fv), nil
+}
+
+func (s *State) train(fv FeatureVector, vlabel data.Value) error {
+	label, err := data.AsString(vlabel)
+	if err != nil {
+		return fmt.Errorf("%s value is not a string: %v", s.labelField, err)
+	}
+
+	if s.calibrator.needsTraining() {
+		scores, err := s.classifier.Classify(fv)
+		if err != nil {
+			return err
+		}
+		s.calibrator.train(scores, Label(label))
+	}
+	return s.classifier.Train(fv, Label(label))
+}
+
+func (s *State) trainMultiLabel(fv FeatureVector, vlabels data.Value) error {
+	c, ok := s.classifier.(MultiLabelClassifier)
+	if !ok || !c.MultiLabel() {
+		return fmt.Errorf("%s value must be a string because the model isn't for multi-label classification", s.labelField)
+	}
+
+	a, _ := data.AsArray(vlabels)
+	labels := make([]Label, len(a))
+	for i, v := range a {
+		l, err := data.AsString(v)
+		if err != nil {
+			return fmt.Errorf("%s value has an element which isn't a string: %v", s.labelField, err)
+		}
+		labels[i] = Label(l)
+	}
+	return c.TrainMultiLabel(fv, labels)
+}
+
+const (
+	classifierFormatVersion uint8 = 5
+)
+
+// Save is provided as a part of core.SavableSharedState.
+func (s *State) Save(ctx *core.Context, w io.Writer, params data.Map) error {
+	if _, err := w.Write([]byte{classifierFormatVersion}); err != nil {
+		return err
+	}
+
+	// This is the format version of the root container and doesn't related to
+	// how each algorithm is saved.
+	enc := codec.NewEncoder(w, classifierMsgpackHandle)
+	if err := enc.Encode(&classifierMsgpack{
+		Algorithm: s.algorithm,
+	}); err != nil {
+		return err
+	}
+
+	if err := enc.Encode(&stateMsgpack{
+		LabelField:         s.labelField,
+		FeatureVectorField: s.featureVectorField,
+	}); err != nil {
+		return err
+	}
+	if err := s.calibrator.save(w); err != nil {
+		return err
+	}
+	if err := s.pruner.save(w); err != nil {
+		return err
+	}
+	if err := fvconv.Save(w, s.converter); err != nil {
+		return err
+	}
+	if err := normalizer.Save(w, s.normalizer); err != nil {
+		return err
+	}
+	return s.classifier.Save(w)
+}
+
+// Classify classifies the input using the given model having stateName.
+// The state can hold a model of any classification algorithm.
+func Classify(ctx *core.Context, stateName string, featureVector data.Map) (data.Map, error) {
+	s, err := lookupState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+
+	fv, err := s.featureVector(featureVector, false)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := s.classifier.Classify(fv)
+	return data.Map(scores), err
+}
+
+// ClassifyTopK classifies the input using the given model having stateName
+// and returns at most k labels having the highest scores. Each element of
+// the result is a map having "label", "score", and "probability". Elements
+// are sorted in descending order of scores. How probabilities are computed
+// depends on the calibration parameter of the state.
+func ClassifyTopK(ctx *core.Context, stateName string, featureVector data.Map, k int) (data.Array, error) {
+	if k <= 0 {
+		return nil, errors.New("k must be greater than zero")
+	}
+
+	s, err := lookupState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+
+	fv, err := s.featureVector(featureVector, false)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := s.classifier.Classify(fv)
+	if err != nil {
+		return nil, err
+	}
+	probs, err := s.calibrator.probabilities(scores)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := scores.sortedLabels()
+	if len(ls) > k {
+		ls = ls[:k]
+	}
+	ret := make(data.Array, len(ls))
+	for i, l := range ls {
+		ret[i] = data.Map{
+			"label":       data.String(l),
+			"score":       data.Float(scores.score(l)),
+			"probability": data.Float(probs[l]),
+		}
+	}
+	return ret, nil
+}
+
+// ClassifyMultiLabel classifies the input using the given model having
+// stateName and returns all labels whose scores are greater than threshold.
+// Labels are sorted in descending order of scores.
+func ClassifyMultiLabel(ctx *core.Context, stateName string, featureVector data.Map, threshold float64) (data.Array, error) {
+	s, err := lookupState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+
+	fv, err := s.featureVector(featureVector, false)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := s.classifier.Classify(fv)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := data.Array{}
+	for _, l := range scores.sortedLabels() {
+		if float64(scores.score(l)) <= threshold {
+			break
+		}
+		ret = append(ret, data.String(l))
+	}
+	return ret, nil
+}
+
+// Labels returns all labels of the model the state having stateName has.
+// Labels are sorted in lexicographical order.
+func Labels(ctx *core.Context, stateName string) (data.Array, error) {
+	s, err := lookupState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := s.classifier.Labels()
+	strs := make([]string, len(ls))
+	for i, l := range ls {
+		strs[i] = string(l)
+	}
+	sort.Strings(strs)
+
+	ret := make(data.Array, len(strs))
+	for i, l := range strs {
+		ret[i] = data.String(l)
+	}
+	return ret, nil
+}
+
+// SetLabel registers a label to the model the state having stateName has
+// without training. It returns false when the model already has the label.
+func SetLabel(ctx *core.Context, stateName string, label string) (bool, error) {
+	s, err := lookupState(ctx, stateName)
+	if err != nil {
+		return false, err
+	}
+	return s.classifier.SetLabel(Label(label))
+}
+
+// DeleteLabel deletes a label from the model the state having stateName
+// has. It returns false when the model doesn't have the label.
+func DeleteLabel(ctx *core.Context, stateName string, label string) (bool, error) {
+	s, err := lookupState(ctx, stateName)
+	if err != nil {
+		return false, err
+	}
+	return s.classifier.DeleteLabel(Label(label)), nil
+}
+
+func lookupState(ctx *core.Context, stateName string) (*State, error) {
+	st, err := ctx.SharedStates.Get(stateName)
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := st.(*State); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("state '%v' isn't a classifier state", stateName)
+}
+
+// ClassifiedLabel returns the label having the highest score in a
+// classification result.
+func ClassifiedLabel(scores data.Map) (string, error) {
+	if len(scores) == 0 {
+		return "", errors.New("attempt to get a label from an empty map")
+	}
+
+	// LScores.Max() cannot be used here because scores is passed by a user.
+	// LScores.Max() expects all values are float.
+	l, _, err := maxLabelScore(scores)
+	if err != nil {
+		return "", err
+	}
+	return l, nil
+}
+
+// ClassifiedScore returns the highest score in a classification result.
+func ClassifiedScore(scores data.Map) (float64, error) {
+	if len(scores) == 0 {
+		return 0, errors.New("attempt to get a score from an empty map")
+	}
+
+	_, s, err := maxLabelScore(scores)
+	if err != nil {
+		return 0, err
+	}
+	return s, nil
+}
+
+// maxLabelScore returns the max score and its label in a data.Map.
+// This function are same as LScores.Max() except error checking.
+func maxLabelScore(scores data.Map) (label string, score float64, err error) {
+	if len(scores) == 0 {
+		err = errors.New("attempt to find a max score from an empty map")
+		return "", 0, err
+	}
+
+	score = minusInf
+	for l, s := range scores {
+		sc, err := data.AsFloat(s)
+		if err != nil {
+			err = fmt.Errorf("score for %s is not a float: %v", l, err)
+			return "", 0, err
+		}
+		if sc > score {
+			label = l
+			score = sc
+		}
+	}
+
+	return label, score, nil
+}
+
+var minusInf = math.Inf(-1)
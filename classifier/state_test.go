@@ -0,0 +1,261 @@
+package classifier
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestClassifyTopK(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := AROWStateCreator{}
+	s, err := c.CreateState(ctx, data.Map{
+		"regularization_weight": data.Float(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SharedStates.Add("shogun", "jubaclassifier_arow", s); err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Given a trained state", t, func() {
+		s.(*State).classifier.Clear()
+		trainShoguns(s.(*State).classifier, 1)
+
+		Convey("when getting the top 2 labels", func() {
+			res, err := ClassifyTopK(ctx, "shogun", data.Map(unigram("義昭")), 2)
+			So(err, ShouldBeNil)
+
+			Convey("it should return 2 results in descending order of scores", func() {
+				So(res, ShouldHaveLength, 2)
+				first, _ := data.AsMap(res[0])
+				second, _ := data.AsMap(res[1])
+				So(first["label"], ShouldEqual, data.String("足利"))
+
+				s1, _ := data.AsFloat(first["score"])
+				s2, _ := data.AsFloat(second["score"])
+				So(s1, ShouldBeGreaterThanOrEqualTo, s2)
+
+				p1, _ := data.AsFloat(first["probability"])
+				p2, _ := data.AsFloat(second["probability"])
+				So(p1, ShouldBeGreaterThanOrEqualTo, p2)
+				So(p1, ShouldBeLessThanOrEqualTo, 1)
+			})
+		})
+
+		Convey("when getting more labels than the model has", func() {
+			res, err := ClassifyTopK(ctx, "shogun", data.Map(unigram("義昭")), 10)
+			So(err, ShouldBeNil)
+
+			Convey("it should return all labels", func() {
+				So(res, ShouldHaveLength, 3)
+			})
+		})
+
+		Convey("when getting top k with non-positive k", func() {
+			_, err := ClassifyTopK(ctx, "shogun", data.Map(unigram("義昭")), 0)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestLoadStateFormatV1(t *testing.T) {
+	ctx := core.NewContext(nil)
+	a, err := NewAROW(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Given a state saved in the format version 1", t, func() {
+		a.Clear()
+		trainShoguns(a, 1)
+
+		// Build the format version 1 which doesn't have a calibrator.
+		buf := bytes.NewBuffer([]byte{1})
+		enc := codec.NewEncoder(buf, classifierMsgpackHandle)
+		So(enc.Encode(&classifierMsgpack{Algorithm: "arow"}), ShouldBeNil)
+		So(enc.Encode(&stateMsgpack{
+			LabelField:         "label",
+			FeatureVectorField: "feature_vector",
+		}), ShouldBeNil)
+		So(a.Save(buf), ShouldBeNil)
+
+		Convey("when loading it", func() {
+			c := AROWStateCreator{}
+			s, err := c.LoadState(ctx, buf, data.Map{})
+
+			Convey("it should succeed with the default calibrator", func() {
+				So(err, ShouldBeNil)
+				st := s.(*State)
+				So(st.labelField, ShouldEqual, "label")
+				So(st.calibrator, ShouldResemble, newDefaultCalibrator())
+				So(st.pruner, ShouldResemble, newDefaultPruner())
+				So(st.classifier, ShouldResemble, a)
+			})
+		})
+	})
+}
+
+func TestMultiLabelState(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := AROWStateCreator{}
+	s, err := c.CreateState(ctx, data.Map{
+		"regularization_weight": data.Float(1),
+		"multi_label":           data.Bool(true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SharedStates.Add("tags", "jubaclassifier_arow", s); err != nil {
+		t.Fatal(err)
+	}
+	st := s.(*State)
+
+	Convey("Given a multi-label state", t, func() {
+		st.classifier.Clear()
+
+		Convey("when writing tuples having arrays of labels", func() {
+			for i := 0; i < 10; i++ {
+				for _, d := range []data.Map{
+					{"label": data.Array{data.String("x")}, "feature_vector": data.Map{"x": data.Float(1)}},
+					{"label": data.Array{data.String("y")}, "feature_vector": data.Map{"y": data.Float(1)}},
+					{"label": data.Array{data.String("x"), data.String("y")}, "feature_vector": data.Map{"x": data.Float(1), "y": data.Float(1)}},
+					{"label": data.String("z"), "feature_vector": data.Map{"z": data.Float(1)}},
+				} {
+					So(st.Write(ctx, &core.Tuple{Data: d}), ShouldBeNil)
+				}
+			}
+
+			Convey("ClassifyMultiLabel should return labels having positive scores", func() {
+				ls, err := ClassifyMultiLabel(ctx, "tags", data.Map{"x": data.Float(1), "y": data.Float(1)}, 0)
+				So(err, ShouldBeNil)
+				So(ls, ShouldHaveLength, 2)
+				So(ls, ShouldContain, data.String("x"))
+				So(ls, ShouldContain, data.String("y"))
+			})
+
+			Convey("saving and loading it should keep multi-label mode", func() {
+				buf := bytes.NewBuffer(nil)
+				So(st.Save(ctx, buf, data.Map{}), ShouldBeNil)
+				s2, err := c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				So(s2, ShouldResemble, st)
+				So(s2.(*State).classifier.(*AROW).MultiLabel(), ShouldBeTrue)
+			})
+		})
+
+		Convey("when writing a tuple having an array containing a non-string", func() {
+			err := st.Write(ctx, &core.Tuple{Data: data.Map{
+				"label":          data.Array{data.Int(1)},
+				"feature_vector": data.Map{"x": data.Float(1)},
+			}})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given a single-label state", t, func() {
+		s, err := c.CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(1),
+		})
+		So(err, ShouldBeNil)
+
+		Convey("when writing a tuple having an array of labels", func() {
+			err := s.(*State).Write(ctx, &core.Tuple{Data: data.Map{
+				"label":          data.Array{data.String("x")},
+				"feature_vector": data.Map{"x": data.Float(1)},
+			}})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestStateWithConverter(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := AROWStateCreator{}
+	s, err := c.CreateState(ctx, data.Map{
+		"regularization_weight": data.Float(1),
+		"converter": data.Map{
+			"string_rules": data.Array{
+				data.Map{"key": data.String("*"), "type": data.String("space")},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SharedStates.Add("text", "jubaclassifier_arow", s); err != nil {
+		t.Fatal(err)
+	}
+	st := s.(*State)
+
+	Convey("Given a state having a converter", t, func() {
+		st.classifier.Clear()
+
+		Convey("when writing tuples having raw values", func() {
+			for i := 0; i < 10; i++ {
+				for _, d := range []data.Map{
+					{"label": data.String("fruit"), "feature_vector": data.Map{"text": data.String("apple banana")}},
+					{"label": data.String("animal"), "feature_vector": data.Map{"text": data.String("cat dog")}},
+				} {
+					So(st.Write(ctx, &core.Tuple{Data: d}), ShouldBeNil)
+				}
+			}
+
+			Convey("the model should have converted features", func() {
+				fv := FeatureVector(data.Map{"text$apple@space#bin/bin": data.Float(1)})
+				scores, err := st.classifier.Classify(fv)
+				So(err, ShouldBeNil)
+				l, _ := scores.Max()
+				So(l, ShouldEqual, "fruit")
+			})
+
+			Convey("Classify should convert the value", func() {
+				scores, err := Classify(ctx, "text", data.Map{"text": data.String("dog")})
+				So(err, ShouldBeNil)
+				l, _ := LScores(scores).Max()
+				So(l, ShouldEqual, "animal")
+			})
+
+			Convey("saving and loading it should keep the converter", func() {
+				buf := bytes.NewBuffer(nil)
+				So(st.Save(ctx, buf, data.Map{}), ShouldBeNil)
+				s2, err := c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				st2 := s2.(*State)
+				So(st2.converter, ShouldNotBeNil)
+
+				fv, err := st2.featureVector(data.Map{"text": data.String("apple")}, false)
+				So(err, ShouldBeNil)
+				So(fv, ShouldResemble, FeatureVector(data.Map{"text$apple@space#bin/bin": data.Float(1)}))
+			})
+		})
+	})
+
+	Convey("Given an invalid converter parameter", t, func() {
+		_, err := c.CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(1),
+			"converter": data.Map{
+				"string_rules": data.Array{
+					data.Map{"key": data.String("*"), "type": data.String("unknown")},
+				},
+			},
+		})
+
+		Convey("creating a state should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,181 @@
+package fvconv
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// config is the parsed config of a converter. It's saved with the converter.
+type config struct {
+	_struct     struct{} `codec:",toarray"`
+	StringTypes map[string]map[string]string
+	StringRules []stringRuleConfig
+	NumRules    []numRuleConfig
+	BM25        bm25Config
+}
+
+// configV1 is the config saved in the format version 1, which doesn't have
+// parameters of BM25.
+type configV1 struct {
+	_struct     struct{} `codec:",toarray"`
+	StringTypes map[string]map[string]string
+	StringRules []stringRuleConfig
+	NumRules    []numRuleConfig
+}
+
+func (c *configV1) toConfig() *config {
+	return &config{
+		StringTypes: c.StringTypes,
+		StringRules: c.StringRules,
+		NumRules:    c.NumRules,
+		BM25:        defaultBM25Config(),
+	}
+}
+
+type stringRuleConfig struct {
+	_struct      struct{} `codec:",toarray"`
+	Key          string
+	Type         string
+	SampleWeight string
+	GlobalWeight string
+}
+
+type numRuleConfig struct {
+	_struct struct{} `codec:",toarray"`
+	Key     string
+	Type    string
+}
+
+func parseConfig(m data.Map) (*config, error) {
+	c := &config{
+		StringTypes: map[string]map[string]string{},
+		BM25:        defaultBM25Config(),
+	}
+	for k, v := range m {
+		var err error
+		switch k {
+		case "string_types":
+			err = parseStringTypes(v, c)
+		case "string_rules":
+			err = parseRules(k, v, func(r data.Map) error {
+				sr := stringRuleConfig{}
+				if err := extractStrings(r, map[string]*string{
+					"key":           &sr.Key,
+					"type":          &sr.Type,
+					"sample_weight": &sr.SampleWeight,
+					"global_weight": &sr.GlobalWeight,
+				}); err != nil {
+					return err
+				}
+				c.StringRules = append(c.StringRules, sr)
+				return nil
+			})
+		case "num_rules":
+			err = parseRules(k, v, func(r data.Map) error {
+				nr := numRuleConfig{}
+				if err := extractStrings(r, map[string]*string{
+					"key":  &nr.Key,
+					"type": &nr.Type,
+				}); err != nil {
+					return err
+				}
+				c.NumRules = append(c.NumRules, nr)
+				return nil
+			})
+		case "bm25":
+			err = parseBM25Config(v, &c.BM25)
+		default:
+			err = fmt.Errorf("unknown field of converter: %v", k)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func parseStringTypes(v data.Value, c *config) error {
+	m, err := data.AsMap(v)
+	if err != nil {
+		return fmt.Errorf("string_types must be a map: %v", err)
+	}
+	for name, t := range m {
+		tm, err := data.AsMap(t)
+		if err != nil {
+			return fmt.Errorf("string_types.%v must be a map: %v", name, err)
+		}
+		params := make(map[string]string, len(tm))
+		for k, p := range tm {
+			s, err := data.ToString(p)
+			if err != nil {
+				return fmt.Errorf("string_types.%v.%v is invalid: %v", name, k, err)
+			}
+			params[k] = s
+		}
+		c.StringTypes[name] = params
+	}
+	return nil
+}
+
+func parseBM25Config(v data.Value, c *bm25Config) error {
+	m, err := data.AsMap(v)
+	if err != nil {
+		return fmt.Errorf("bm25 must be a map: %v", err)
+	}
+	for k, p := range m {
+		x, err := data.ToFloat(p)
+		if err != nil {
+			return fmt.Errorf("bm25.%v must be a number: %v", k, err)
+		}
+		switch k {
+		case "k1":
+			if x < 0 {
+				return errors.New("bm25.k1 must be non-negative")
+			}
+			c.K1 = x
+		case "b":
+			if x < 0 || x > 1 {
+				return errors.New("bm25.b must be in [0, 1]")
+			}
+			c.B = x
+		default:
+			return fmt.Errorf("unknown field of bm25: %v", k)
+		}
+	}
+	return nil
+}
+
+func parseRules(name string, v data.Value, f func(r data.Map) error) error {
+	a, err := data.AsArray(v)
+	if err != nil {
+		return fmt.Errorf("%v must be an array: %v", name, err)
+	}
+	for i, r := range a {
+		m, err := data.AsMap(r)
+		if err != nil {
+			return fmt.Errorf("%v[%v] must be a map: %v", name, i, err)
+		}
+		if err := f(m); err != nil {
+			return fmt.Errorf("%v[%v]: %v", name, i, err)
+		}
+	}
+	return nil
+}
+
+// extractStrings extracts string fields of a rule. Fields not in the rule
+// are left as they are.
+func extractStrings(r data.Map, fields map[string]*string) error {
+	for k, v := range r {
+		p, ok := fields[k]
+		if !ok {
+			return fmt.Errorf("unknown field: %v", k)
+		}
+		s, err := data.AsString(v)
+		if err != nil {
+			return fmt.Errorf("%v must be a string: %v", k, err)
+		}
+		*p = s
+	}
+	return nil
+}
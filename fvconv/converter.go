@@ -0,0 +1,372 @@
+// Package fvconv converts raw data into feature vectors by rules, in the same
+// way as fv_converter of the original Jubatus does.
+//
+// A converter is configured by a map having the following fields, all of
+// which are optional:
+//
+//	string_types: a map from names to custom string types
+//	string_rules: an array of rules for string values
+//	num_rules: an array of rules for numeric values
+//	bm25: parameters of BM25
+//
+// A string rule has key, type, sample_weight and global_weight. key is a
+// pattern of keys the rule is applied to: "*" matches any key, "abc*" matches
+// keys starting with "abc", "*abc" matches keys ending with "abc", "/re/"
+// matches keys matching the regular expression re, and other patterns match
+// the key itself. type is a built-in string type or a name in string_types.
+// Built-in string types are:
+//
+//	str: the value itself
+//	space: words split by white spaces
+//
+// A custom string type is a map having method and its parameters. method is
+// ngram, which extracts character n-grams with char_num as n, or one of
+// methods of package tokenizer, i.e. char_ngram, word_ngram, regexp and
+// delimiter.
+//
+// sample_weight is the weight of a feature in a value:
+//
+//	bin: 1
+//	tf: the number of occurrences
+//	log_tf: log(1 + tf)
+//
+// global_weight is the weight of a feature among trained data:
+//
+//	bin: 1
+//	idf: log((N + 1) / (df + 1)) where N is the number of trained data and df
+//	     is the number of trained data having the feature
+//	bm25: idf' * tf * (k1 + 1) / (tf + k1 * (1 - b + b * dl / avgdl)) where
+//	      idf' is log(1 + (N - df + 0.5) / (df + 0.5)), tf is the sample
+//	      weight, dl is the sum of sample weights of features weighted by
+//	      BM25 in the value, and avgdl is the average of dl of trained data
+//
+// Parameters of BM25 are given by bm25 field of the config, which is a map
+// having k1 and b. They default to 1.2 and 0.75 respectively.
+//
+// A string feature is named "<key>$<token>@<type>#<sample_weight>/<global_weight>"
+// and its value is sample_weight * global_weight.
+//
+// A num rule has key and type. type is one of the following:
+//
+//	num: the value itself as a feature named "<key>@num"
+//	log: log(max(1, value)) as a feature named "<key>@log"
+//	str: a binary feature named "<key>@str$<value>"
+//
+// Keys of nested maps are joined with "/", and each element of an array is
+// converted with the key of the array. Values other than strings and numbers
+// are ignored.
+package fvconv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Converter converts raw data into feature vectors. It's safe for concurrent
+// use.
+type Converter struct {
+	config      *config
+	stringRules []*stringRule
+	numRules    []*numRule
+
+	// weights has statistics of trained data for global weights.
+	weights *globalWeights
+	m       sync.RWMutex
+}
+
+// New creates a converter from a config.
+func New(cfg data.Map) (*Converter, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newConverter(c)
+}
+
+func newConverter(c *config) (*Converter, error) {
+	conv := &Converter{
+		config:  c,
+		weights: newGlobalWeights(),
+	}
+	for i, r := range c.StringRules {
+		sr, err := newStringRule(r, c.StringTypes)
+		if err != nil {
+			return nil, fmt.Errorf("string_rules[%v]: %v", i, err)
+		}
+		conv.stringRules = append(conv.stringRules, sr)
+	}
+	for i, r := range c.NumRules {
+		nr, err := newNumRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("num_rules[%v]: %v", i, err)
+		}
+		conv.numRules = append(conv.numRules, nr)
+	}
+	return conv, nil
+}
+
+// Convert converts v into a feature vector. Statistics for global weights
+// aren't updated, so it's used for data to be classified or estimated.
+func (c *Converter) Convert(v data.Map) (data.Map, error) {
+	fs, err := c.extract(v)
+	if err != nil {
+		return nil, err
+	}
+
+	c.m.RLock()
+	defer c.m.RUnlock()
+	return fs.weigh(c.weights, &c.config.BM25), nil
+}
+
+// ConvertForTraining converts v into a feature vector after updating
+// statistics for global weights with v.
+func (c *Converter) ConvertForTraining(v data.Map) (data.Map, error) {
+	fs, err := c.extract(v)
+	if err != nil {
+		return nil, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.weights.add(fs)
+	return fs.weigh(c.weights, &c.config.BM25), nil
+}
+
+// features are features extracted from a value before global weights are
+// applied.
+type features []feature
+
+type feature struct {
+	name string
+
+	// value is the value multiplied by the sample weight.
+	value  float64
+	global string
+}
+
+func (fs features) weigh(w *globalWeights, p *bm25Config) data.Map {
+	dl := docLength(fs)
+	fv := make(data.Map, len(fs))
+	for i := range fs {
+		f := &fs[i]
+		x := w.weight(f, dl, p)
+		if v, ok := fv[f.name]; ok {
+			y, _ := data.AsFloat(v)
+			x += y
+		}
+		fv[f.name] = data.Float(x)
+	}
+	return fv
+}
+
+func (c *Converter) extract(v data.Map) (features, error) {
+	var fs features
+	if err := c.extractImpl("", v, &fs); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+func (c *Converter) extractImpl(key string, v data.Value, fs *features) error {
+	switch v.Type() {
+	case data.TypeMap:
+		m, _ := data.AsMap(v)
+		for k, e := range m {
+			if key != "" {
+				k = key + "/" + k
+			}
+			if err := c.extractImpl(k, e, fs); err != nil {
+				return err
+			}
+		}
+
+	case data.TypeArray:
+		a, _ := data.AsArray(v)
+		for _, e := range a {
+			if err := c.extractImpl(key, e, fs); err != nil {
+				return err
+			}
+		}
+
+	case data.TypeString:
+		s, _ := data.AsString(v)
+		for _, r := range c.stringRules {
+			if r.key.match(key) {
+				r.extract(key, s, fs)
+			}
+		}
+
+	case data.TypeInt, data.TypeFloat:
+		x, _ := data.ToFloat(v)
+		for _, r := range c.numRules {
+			if r.key.match(key) {
+				if err := r.extract(key, x, fs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// keyMatcher matches keys of values with a pattern.
+type keyMatcher struct {
+	pattern string
+	match   func(key string) bool
+}
+
+func newKeyMatcher(pattern string) (*keyMatcher, error) {
+	m := &keyMatcher{pattern: pattern}
+	switch {
+	case pattern == "":
+		return nil, errors.New("key must not be empty")
+	case pattern == "*":
+		m.match = func(string) bool { return true }
+	case len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/"):
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression of key: %v", err)
+		}
+		m.match = re.MatchString
+	case strings.HasSuffix(pattern, "*"):
+		prefix := pattern[:len(pattern)-1]
+		m.match = func(k string) bool { return strings.HasPrefix(k, prefix) }
+	case strings.HasPrefix(pattern, "*"):
+		suffix := pattern[1:]
+		m.match = func(k string) bool { return strings.HasSuffix(k, suffix) }
+	default:
+		m.match = func(k string) bool { return k == pattern }
+	}
+	return m, nil
+}
+
+const (
+	converterFormatVersion uint8 = 2
+)
+
+var (
+	msgpackHandle = &codec.MsgpackHandle{
+		RawToString: true,
+	}
+)
+
+type converterMsgpack struct {
+	_struct struct{} `codec:",toarray"`
+
+	// Enabled is false when the converter is nil.
+	Enabled bool
+	Config  *config
+	Weights *globalWeights
+}
+
+// Save saves c. c can be nil, which means that raw data are used as feature
+// vectors without conversion.
+func Save(w io.Writer, c *Converter) error {
+	if _, err := w.Write([]byte{converterFormatVersion}); err != nil {
+		return err
+	}
+
+	d := &converterMsgpack{}
+	if c != nil {
+		c.m.RLock()
+		defer c.m.RUnlock()
+		d.Enabled = true
+		d.Config = c.config
+		d.Weights = c.weights
+	}
+	return codec.NewEncoder(w, msgpackHandle).Encode(d)
+}
+
+// Load loads a converter saved by Save. It returns nil when nil was saved.
+func Load(r io.Reader) (*Converter, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadFormatV1(r)
+	case 2:
+		return loadFormatV2(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of converter container: %v", formatVersion[0])
+	}
+}
+
+type converterMsgpackV1 struct {
+	_struct struct{} `codec:",toarray"`
+	Enabled bool
+	Config  *configV1
+	Weights *globalWeightsV1
+}
+
+func loadFormatV1(r io.Reader) (*Converter, error) {
+	var d converterMsgpackV1
+	if err := codec.NewDecoder(r, msgpackHandle).Decode(&d); err != nil {
+		return nil, err
+	}
+	if !d.Enabled {
+		return nil, nil
+	}
+	if d.Config == nil {
+		return nil, errors.New("converter doesn't have a config")
+	}
+	// The format version 1 doesn't support BM25, so default parameters are
+	// used and lengths of trained data are unknown.
+	var w *globalWeights
+	if d.Weights != nil {
+		w = d.Weights.toGlobalWeights()
+	}
+	return restoreConverter(d.Config.toConfig(), w)
+}
+
+func loadFormatV2(r io.Reader) (*Converter, error) {
+	var d converterMsgpack
+	if err := codec.NewDecoder(r, msgpackHandle).Decode(&d); err != nil {
+		return nil, err
+	}
+	if !d.Enabled {
+		return nil, nil
+	}
+	if d.Config == nil {
+		return nil, errors.New("converter doesn't have a config")
+	}
+	return restoreConverter(d.Config, d.Weights)
+}
+
+func restoreConverter(cfg *config, w *globalWeights) (*Converter, error) {
+	c, err := newConverter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if w != nil {
+		c.weights = w
+		c.weights.init()
+	}
+	return c, nil
+}
+
+// NewFromParams creates a converter from converter parameter of a UDS. It
+// returns nil when the parameter isn't given.
+func NewFromParams(params data.Map) (*Converter, error) {
+	v, ok := params["converter"]
+	if !ok {
+		return nil, nil
+	}
+	m, err := data.AsMap(v)
+	if err != nil {
+		return nil, fmt.Errorf("converter parameter must be a map: %v", err)
+	}
+	c, err := New(m)
+	if err != nil {
+		return nil, fmt.Errorf("converter parameter is invalid: %v", err)
+	}
+	return c, nil
+}
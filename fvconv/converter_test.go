@@ -0,0 +1,321 @@
+package fvconv
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"math"
+	"testing"
+)
+
+func TestConverter(t *testing.T) {
+	Convey("Given a converter having string and num rules", t, func() {
+		c, err := New(data.Map{
+			"string_types": data.Map{
+				"bigram": data.Map{
+					"method":   data.String("ngram"),
+					"char_num": data.Int(2),
+				},
+				"digits": data.Map{
+					"method":  data.String("regexp"),
+					"pattern": data.String("id=([0-9]+)"),
+					"group":   data.String("1"),
+				},
+			},
+			"string_rules": data.Array{
+				data.Map{"key": data.String("title"), "type": data.String("str")},
+				data.Map{"key": data.String("body*"), "type": data.String("space"), "sample_weight": data.String("tf")},
+				data.Map{"key": data.String("*name"), "type": data.String("bigram")},
+				data.Map{"key": data.String("/^ref/"), "type": data.String("digits")},
+			},
+			"num_rules": data.Array{
+				data.Map{"key": data.String("age"), "type": data.String("num")},
+				data.Map{"key": data.String("count"), "type": data.String("log")},
+				data.Map{"key": data.String("user/*"), "type": data.String("str")},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("when converting a value", func() {
+			fv, err := c.Convert(data.Map{
+				"title":     data.String("a b"),
+				"body_text": data.String("x y x"),
+				"full_name": data.String("abc"),
+				"refs":      data.String("id=12, id=3"),
+				"age":       data.Int(20),
+				"count":     data.Float(math.E),
+				"user":      data.Map{"id": data.Int(7)},
+				"unknown":   data.String("ignored"),
+				"flag":      data.Bool(true),
+			})
+			So(err, ShouldBeNil)
+
+			Convey("it should have features extracted by the rules", func() {
+				So(fv, ShouldResemble, data.Map{
+					"title$a b@str#bin/bin":       data.Float(1),
+					"body_text$x@space#tf/bin":    data.Float(2),
+					"body_text$y@space#tf/bin":    data.Float(1),
+					"full_name$ab@bigram#bin/bin": data.Float(1),
+					"full_name$bc@bigram#bin/bin": data.Float(1),
+					"refs$12@digits#bin/bin":      data.Float(1),
+					"refs$3@digits#bin/bin":       data.Float(1),
+					"age@num":                     data.Float(20),
+					"count@log":                   data.Float(1),
+					"user/id@str$7":               data.Float(1),
+				})
+			})
+		})
+
+		Convey("when converting a value having an array", func() {
+			fv, err := c.Convert(data.Map{
+				"title": data.Array{data.String("a"), data.String("b")},
+			})
+			So(err, ShouldBeNil)
+
+			Convey("each element should be converted with the key of the array", func() {
+				So(fv, ShouldResemble, data.Map{
+					"title$a@str#bin/bin": data.Float(1),
+					"title$b@str#bin/bin": data.Float(1),
+				})
+			})
+		})
+
+		Convey("when converting a value having NaN", func() {
+			_, err := c.Convert(data.Map{
+				"age": data.Float(math.NaN()),
+			})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given a converter having a string type of the tokenizer", t, func() {
+		c, err := New(data.Map{
+			"string_types": data.Map{
+				"bigram": data.Map{
+					"method": data.String("word_ngram"),
+					"n":      data.Int(2),
+				},
+			},
+			"string_rules": data.Array{
+				data.Map{"key": data.String("*"), "type": data.String("bigram")},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("when converting a value", func() {
+			fv, err := c.Convert(data.Map{"text": data.String("a b c")})
+			So(err, ShouldBeNil)
+
+			Convey("it should have features of tokens", func() {
+				So(fv, ShouldResemble, data.Map{
+					"text$a b@bigram#bin/bin": data.Float(1),
+					"text$b c@bigram#bin/bin": data.Float(1),
+				})
+			})
+		})
+	})
+
+	Convey("Given a converter using idf", t, func() {
+		c, err := New(data.Map{
+			"string_rules": data.Array{
+				data.Map{"key": data.String("*"), "type": data.String("space"), "global_weight": data.String("idf")},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("when training it with values", func() {
+			for _, s := range []string{"a b", "a c", "a"} {
+				_, err := c.ConvertForTraining(data.Map{"text": data.String(s)})
+				So(err, ShouldBeNil)
+			}
+
+			Convey("features should be weighted by the document frequency", func() {
+				fv, err := c.Convert(data.Map{"text": data.String("a b")})
+				So(err, ShouldBeNil)
+				So(fv["text$a@space#bin/idf"], ShouldAlmostEqual, math.Log(4.0/4.0))
+				So(fv["text$b@space#bin/idf"], ShouldAlmostEqual, math.Log(4.0/2.0))
+			})
+
+			Convey("converting values shouldn't update statistics", func() {
+				_, err := c.Convert(data.Map{"text": data.String("b")})
+				So(err, ShouldBeNil)
+				So(c.weights.DocCount, ShouldEqual, 3)
+				So(c.weights.DocFreqs["text$b@space#bin/idf"], ShouldEqual, 1)
+			})
+
+			Convey("saving and loading it should keep statistics", func() {
+				buf := bytes.NewBuffer(nil)
+				So(Save(buf, c), ShouldBeNil)
+				c2, err := Load(buf)
+				So(err, ShouldBeNil)
+
+				v := data.Map{"text": data.String("a b c d")}
+				fv1, err := c.Convert(v)
+				So(err, ShouldBeNil)
+				fv2, err := c2.Convert(v)
+				So(err, ShouldBeNil)
+				So(fv2, ShouldResemble, fv1)
+			})
+		})
+	})
+
+	Convey("Given a converter using BM25", t, func() {
+		c, err := New(data.Map{
+			"string_rules": data.Array{
+				data.Map{"key": data.String("*"), "type": data.String("space"), "sample_weight": data.String("tf"), "global_weight": data.String("bm25")},
+			},
+			"bm25": data.Map{
+				"k1": data.Float(2),
+				"b":  data.Float(0.5),
+			},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("when training it with values", func() {
+			for _, s := range []string{"a b", "a c c", "a"} {
+				_, err := c.ConvertForTraining(data.Map{"text": data.String(s)})
+				So(err, ShouldBeNil)
+			}
+
+			Convey("it should have the sum of lengths of trained data", func() {
+				So(c.weights.DocLengthSum, ShouldEqual, 6)
+			})
+
+			Convey("features should be weighted by BM25", func() {
+				fv, err := c.Convert(data.Map{"text": data.String("a c c c")})
+				So(err, ShouldBeNil)
+
+				// N = 3, avgdl = 2, dl = 4
+				norm := 2 * (1 - 0.5 + 0.5*4/2.0)
+				So(fv["text$a@space#tf/bm25"], ShouldAlmostEqual, math.Log(1+0.5/3.5)*1*3/(1+norm))
+				So(fv["text$c@space#tf/bm25"], ShouldAlmostEqual, math.Log(1+2.5/1.5)*3*3/(3+norm))
+			})
+
+			Convey("frequent features should have smaller weights", func() {
+				fv, err := c.Convert(data.Map{"text": data.String("a b")})
+				So(err, ShouldBeNil)
+				a, _ := data.AsFloat(fv["text$a@space#tf/bm25"])
+				b, _ := data.AsFloat(fv["text$b@space#tf/bm25"])
+				So(a, ShouldBeLessThan, b)
+			})
+
+			Convey("saving and loading it should keep statistics and parameters", func() {
+				buf := bytes.NewBuffer(nil)
+				So(Save(buf, c), ShouldBeNil)
+				c2, err := Load(buf)
+				So(err, ShouldBeNil)
+				So(c2.config.BM25, ShouldResemble, c.config.BM25)
+				So(c2.weights, ShouldResemble, c.weights)
+			})
+		})
+	})
+
+	Convey("Given a converter saved in the format version 1", t, func() {
+		buf := bytes.NewBuffer([]byte{1})
+		So(codec.NewEncoder(buf, msgpackHandle).Encode(&converterMsgpackV1{
+			Enabled: true,
+			Config: &configV1{
+				StringRules: []stringRuleConfig{{Key: "*", Type: "str", GlobalWeight: "idf"}},
+			},
+			Weights: &globalWeightsV1{
+				DocCount: 1,
+				DocFreqs: map[string]uint64{"a$x@str#bin/idf": 1},
+			},
+		}), ShouldBeNil)
+
+		Convey("when loading it", func() {
+			c, err := Load(buf)
+			So(err, ShouldBeNil)
+
+			Convey("it should have default parameters of BM25", func() {
+				So(c.config.BM25, ShouldResemble, defaultBM25Config())
+				So(c.weights.DocCount, ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given a nil converter", t, func() {
+		var c *Converter
+
+		Convey("when saving and loading it", func() {
+			buf := bytes.NewBuffer(nil)
+			So(Save(buf, c), ShouldBeNil)
+			c2, err := Load(buf)
+
+			Convey("it should be loaded as nil", func() {
+				So(err, ShouldBeNil)
+				So(c2, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestNewWithInvalidConfig(t *testing.T) {
+	Convey("Given invalid configs", t, func() {
+		cases := map[string]data.Map{
+			"unknown field": {"unknown": data.Array{}},
+			"unknown string type": {"string_rules": data.Array{
+				data.Map{"key": data.String("*"), "type": data.String("unknown")},
+			}},
+			"empty key": {"string_rules": data.Array{
+				data.Map{"key": data.String(""), "type": data.String("str")},
+			}},
+			"invalid key regexp": {"string_rules": data.Array{
+				data.Map{"key": data.String("/(/"), "type": data.String("str")},
+			}},
+			"unknown sample weight": {"string_rules": data.Array{
+				data.Map{"key": data.String("*"), "type": data.String("str"), "sample_weight": data.String("unknown")},
+			}},
+			"unknown global weight": {"string_rules": data.Array{
+				data.Map{"key": data.String("*"), "type": data.String("str"), "global_weight": data.String("unknown")},
+			}},
+			"invalid char_num": {
+				"string_types": data.Map{"ngram": data.Map{"method": data.String("ngram"), "char_num": data.Int(0)}},
+				"string_rules": data.Array{data.Map{"key": data.String("*"), "type": data.String("ngram")}},
+			},
+			"invalid group": {
+				"string_types": data.Map{"re": data.Map{"method": data.String("regexp"), "pattern": data.String("a"), "group": data.Int(1)}},
+				"string_rules": data.Array{data.Map{"key": data.String("*"), "type": data.String("re")}},
+			},
+			"negative k1": {"bm25": data.Map{"k1": data.Float(-1)}},
+			"too large b": {"bm25": data.Map{"b": data.Float(1.5)}},
+			"unknown num type": {"num_rules": data.Array{
+				data.Map{"key": data.String("*"), "type": data.String("unknown")},
+			}},
+		}
+
+		for name, cfg := range cases {
+			name, cfg := name, cfg
+			Convey("when creating a converter with "+name, func() {
+				_, err := New(cfg)
+
+				Convey("it should fail", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+		}
+	})
+}
+
+func TestNewFromParams(t *testing.T) {
+	Convey("Given params without converter", t, func() {
+		c, err := NewFromParams(data.Map{})
+
+		Convey("it should return nil", func() {
+			So(err, ShouldBeNil)
+			So(c, ShouldBeNil)
+		})
+	})
+
+	Convey("Given params having a non-map converter", t, func() {
+		_, err := NewFromParams(data.Map{"converter": data.String("a")})
+
+		Convey("it should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
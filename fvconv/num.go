@@ -0,0 +1,52 @@
+package fvconv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type numRule struct {
+	key      *keyMatcher
+	typeName string
+}
+
+func newNumRule(c numRuleConfig) (*numRule, error) {
+	key, err := newKeyMatcher(c.Key)
+	if err != nil {
+		return nil, err
+	}
+	switch c.Type {
+	case "num", "log", "str":
+	case "":
+		return nil, errors.New("type must be given")
+	default:
+		return nil, fmt.Errorf("unknown num type: %v", c.Type)
+	}
+	return &numRule{
+		key:      key,
+		typeName: c.Type,
+	}, nil
+}
+
+func (r *numRule) extract(key string, x float64, fs *features) error {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return fmt.Errorf("%v has an invalid value: %v", key, x)
+	}
+
+	f := feature{global: "bin"}
+	switch r.typeName {
+	case "num":
+		f.name = key + "@num"
+		f.value = x
+	case "log":
+		f.name = key + "@log"
+		f.value = math.Log(math.Max(1, x))
+	case "str":
+		f.name = key + "@str$" + strconv.FormatFloat(x, 'g', -1, 64)
+		f.value = 1
+	}
+	*fs = append(*fs, f)
+	return nil
+}
@@ -0,0 +1,126 @@
+package fvconv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zeromberto/jubatus/tokenizer"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"math"
+	"strconv"
+)
+
+type stringRule struct {
+	key          *keyMatcher
+	typeName     string
+	tokenizer    tokenizer.Tokenizer
+	sampleWeight string
+	globalWeight string
+}
+
+func newStringRule(c stringRuleConfig, types map[string]map[string]string) (*stringRule, error) {
+	key, err := newKeyMatcher(c.Key)
+	if err != nil {
+		return nil, err
+	}
+	if c.Type == "" {
+		return nil, errors.New("type must be given")
+	}
+	t, err := newTokenizer(c.Type, types)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &stringRule{
+		key:          key,
+		typeName:     c.Type,
+		tokenizer:    t,
+		sampleWeight: c.SampleWeight,
+		globalWeight: c.GlobalWeight,
+	}
+	if r.sampleWeight == "" {
+		r.sampleWeight = "bin"
+	}
+	if r.globalWeight == "" {
+		r.globalWeight = "bin"
+	}
+	switch r.sampleWeight {
+	case "bin", "tf", "log_tf":
+	default:
+		return nil, fmt.Errorf("unknown sample_weight: %v", r.sampleWeight)
+	}
+	if !isGlobalWeight(r.globalWeight) {
+		return nil, fmt.Errorf("unknown global_weight: %v", r.globalWeight)
+	}
+	return r, nil
+}
+
+func (r *stringRule) extract(key, value string, fs *features) {
+	tokens := r.tokenizer.Tokenize(value)
+	counts := make(map[string]int, len(tokens))
+	var order []string
+	for _, t := range tokens {
+		if counts[t] == 0 {
+			order = append(order, t)
+		}
+		counts[t]++
+	}
+
+	suffix := fmt.Sprintf("@%s#%s/%s", r.typeName, r.sampleWeight, r.globalWeight)
+	for _, t := range order {
+		var x float64
+		switch r.sampleWeight {
+		case "bin":
+			x = 1
+		case "tf":
+			x = float64(counts[t])
+		case "log_tf":
+			x = math.Log(1 + float64(counts[t]))
+		}
+		*fs = append(*fs, feature{
+			name:   key + "$" + t + suffix,
+			value:  x,
+			global: r.globalWeight,
+		})
+	}
+}
+
+func newTokenizer(typeName string, types map[string]map[string]string) (tokenizer.Tokenizer, error) {
+	params, ok := types[typeName]
+	if !ok {
+		switch typeName {
+		case "str":
+			return strTokenizer{}, nil
+		case "space":
+			return tokenizer.NewDelimiter(""), nil
+		default:
+			return nil, fmt.Errorf("unknown string type: %v", typeName)
+		}
+	}
+
+	// ngram is the method of Jubatus, which is the same as char_ngram of the
+	// tokenizer except for the name of the parameter.
+	if params["method"] == "ngram" {
+		n, err := strconv.Atoi(params["char_num"])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("char_num of string type %v must be a positive integer: %v", typeName, params["char_num"])
+		}
+		return tokenizer.NewCharNGram(n)
+	}
+
+	cfg := make(data.Map, len(params))
+	for k, v := range params {
+		cfg[k] = data.String(v)
+	}
+	t, err := tokenizer.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("string type %v is invalid: %v", typeName, err)
+	}
+	return t, nil
+}
+
+// strTokenizer uses the value itself as a token.
+type strTokenizer struct{}
+
+func (strTokenizer) Tokenize(s string) []string {
+	return []string{s}
+}
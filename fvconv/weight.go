@@ -0,0 +1,125 @@
+package fvconv
+
+import (
+	"math"
+)
+
+// globalWeights has statistics of trained data to compute global weights.
+type globalWeights struct {
+	_struct struct{} `codec:",toarray"`
+
+	// DocCount is the number of trained data.
+	DocCount uint64
+
+	// DocFreqs has the number of trained data having each feature. Only
+	// features whose global weight requires it are counted.
+	DocFreqs map[string]uint64
+
+	// DocLengthSum is the sum of lengths of trained data, which BM25 uses.
+	// The length of a value is the sum of sample weights of its features
+	// weighted by BM25.
+	DocLengthSum float64
+}
+
+// globalWeightsV1 is globalWeights saved in the format version 1, which
+// doesn't have lengths of trained data.
+type globalWeightsV1 struct {
+	_struct  struct{} `codec:",toarray"`
+	DocCount uint64
+	DocFreqs map[string]uint64
+}
+
+func (w *globalWeightsV1) toGlobalWeights() *globalWeights {
+	return &globalWeights{
+		DocCount: w.DocCount,
+		DocFreqs: w.DocFreqs,
+	}
+}
+
+func newGlobalWeights() *globalWeights {
+	w := &globalWeights{}
+	w.init()
+	return w
+}
+
+// init initializes fields which can be nil after being loaded.
+func (w *globalWeights) init() {
+	if w.DocFreqs == nil {
+		w.DocFreqs = map[string]uint64{}
+	}
+}
+
+func isGlobalWeight(name string) bool {
+	switch name {
+	case "bin", "idf", "bm25":
+		return true
+	default:
+		return false
+	}
+}
+
+// bm25Config has parameters of BM25.
+type bm25Config struct {
+	_struct struct{} `codec:",toarray"`
+	K1      float64
+	B       float64
+}
+
+func defaultBM25Config() bm25Config {
+	return bm25Config{
+		K1: 1.2,
+		B:  0.75,
+	}
+}
+
+// docLength returns the length of a value used by BM25.
+func docLength(fs features) float64 {
+	l := 0.0
+	for _, f := range fs {
+		if f.global == "bm25" {
+			l += f.value
+		}
+	}
+	return l
+}
+
+// add updates statistics with features of a trained value.
+func (w *globalWeights) add(fs features) {
+	w.DocCount++
+	w.DocLengthSum += docLength(fs)
+	seen := make(map[string]struct{}, len(fs))
+	for _, f := range fs {
+		if f.global == "bin" {
+			continue
+		}
+		if _, ok := seen[f.name]; ok {
+			continue
+		}
+		seen[f.name] = struct{}{}
+		w.DocFreqs[f.name]++
+	}
+}
+
+// weight returns the value of a feature with its global weight applied. dl
+// is the length of the value having the feature.
+func (w *globalWeights) weight(f *feature, dl float64, p *bm25Config) float64 {
+	n := float64(w.DocCount)
+	df := float64(w.DocFreqs[f.name])
+	switch f.global {
+	case "idf":
+		return f.value * math.Log((n+1)/(df+1))
+
+	case "bm25":
+		// The idf of BM25 is smoothed so that it's always non-negative.
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		norm := 1.0
+		if w.DocCount > 0 && w.DocLengthSum > 0 {
+			norm = dl / (w.DocLengthSum / n)
+		}
+		tf := f.value
+		return idf * tf * (p.K1 + 1) / (tf + p.K1*(1-p.B+p.B*norm))
+
+	default:
+		return f.value
+	}
+}
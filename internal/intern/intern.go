@@ -1,20 +1,35 @@
 package intern
 
 import (
+	"errors"
 	"fmt"
 	"github.com/ugorji/go/codec"
+	"hash/fnv"
 	"io"
 	"reflect"
 )
 
 // Intern is a mapping from strings to ints. It isn't thread safe. Appropriate
 // handling of race conditions is the responsibility of users.
+//
+// When hashing is enabled, Intern maps strings to ints in [1, hashMaxSize]
+// by a hash function instead of storing them. Different strings may have the
+// same ID in that case.
 type Intern struct {
 	storage map[string]int
 	gen     int
+
+	hashMaxSize int
 }
 
 type internData struct {
+	_struct     struct{} `codec:",toarray"`
+	Storage     map[string]int
+	Gen         int
+	HashMaxSize int
+}
+
+type internDataV1 struct {
 	_struct struct{} `codec:",toarray"`
 	Storage map[string]int
 	Gen     int
@@ -28,12 +43,48 @@ func New() *Intern {
 	}
 }
 
+// EnableHashing makes Intern map strings to ints by a hash function so that
+// the number of IDs is bounded by maxSize. It must be called before any
+// string is registered.
+func (i *Intern) EnableHashing(maxSize int) error {
+	if maxSize <= 0 {
+		return errors.New("max size of hashing must be greater than zero")
+	}
+	if len(i.storage) != 0 {
+		return errors.New("hashing cannot be enabled after strings are registered")
+	}
+	i.hashMaxSize = maxSize
+	return nil
+}
+
+// HashMaxSize returns the max size of hashing. It returns zero when hashing
+// isn't enabled.
+func (i *Intern) HashMaxSize() int {
+	return i.hashMaxSize
+}
+
+// Clear removes all registered strings. The setting of hashing is kept.
+func (i *Intern) Clear() {
+	i.storage = make(map[string]int)
+	i.gen = 0
+}
+
 // GetOrZero returns an ID for a string if the string is already registered.
-// If the string is not registered this method returns zero,
+// If the string is not registered this method returns zero.
+// When hashing is enabled, this method always returns the hashed ID.
 func (i *Intern) GetOrZero(s string) int {
+	if i.hashMaxSize > 0 {
+		return i.hash(s)
+	}
 	return i.storage[s]
 }
 
+func (i *Intern) hash(s string) int {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return int(h.Sum64()%uint64(i.hashMaxSize)) + 1
+}
+
 // Get returns an ID for a string. If the string was not registered, this
 // method registers the string and returns an ID. This method is idempotent.
 func (i *Intern) Get(s string) int {
@@ -46,8 +97,33 @@ func (i *Intern) Get(s string) int {
 	return id
 }
 
+// Compact removes strings whose IDs aren't used and returns the number of
+// removed strings. IDs of the remaining strings don't change. It does nothing
+// when hashing is enabled.
+func (i *Intern) Compact(used func(id int) bool) int {
+	n := 0
+	for s, id := range i.storage {
+		if !used(id) {
+			delete(i.storage, s)
+			n++
+		}
+	}
+	return n
+}
+
+// Inverse returns a mapping from IDs to strings. It's intended to be used
+// for inspecting models, so the mapping is computed every time it's called.
+// When hashing is enabled, the mapping is empty because strings aren't stored.
+func (i *Intern) Inverse() map[int]string {
+	inv := make(map[int]string, len(i.storage))
+	for s, id := range i.storage {
+		inv[id] = s
+	}
+	return inv
+}
+
 const (
-	internFormatVersion uint8 = 1
+	internFormatVersion uint8 = 2
 )
 
 var internMsgpackHandle = &codec.MsgpackHandle{
@@ -66,8 +142,9 @@ func (i *Intern) Save(w io.Writer) error {
 
 	enc := codec.NewEncoder(w, internMsgpackHandle)
 	if err := enc.Encode(&internData{
-		Storage: i.storage,
-		Gen:     i.gen,
+		Storage:     i.storage,
+		Gen:         i.gen,
+		HashMaxSize: i.hashMaxSize,
 	}); err != nil {
 		return err
 	}
@@ -84,13 +161,15 @@ func Load(r io.Reader) (*Intern, error) {
 	switch formatVersion[0] {
 	case 1:
 		return loadFormatV1(r)
+	case 2:
+		return loadFormatV2(r)
 	default:
 		return nil, fmt.Errorf("unsupported format version of Intern container: %v", formatVersion[0])
 	}
 }
 
 func loadFormatV1(r io.Reader) (*Intern, error) {
-	var d internData
+	var d internDataV1
 	dec := codec.NewDecoder(r, internMsgpackHandle)
 	if err := dec.Decode(&d); err != nil {
 		return nil, err
@@ -100,3 +179,16 @@ func loadFormatV1(r io.Reader) (*Intern, error) {
 		gen:     d.Gen,
 	}, nil
 }
+
+func loadFormatV2(r io.Reader) (*Intern, error) {
+	var d internData
+	dec := codec.NewDecoder(r, internMsgpackHandle)
+	if err := dec.Decode(&d); err != nil {
+		return nil, err
+	}
+	return &Intern{
+		storage:     d.Storage,
+		gen:         d.Gen,
+		hashMaxSize: d.HashMaxSize,
+	}, nil
+}
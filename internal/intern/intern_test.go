@@ -31,6 +31,16 @@ func TestIntern(t *testing.T) {
 			})
 		})
 
+		Convey("when getting the inverse mapping", func() {
+			a := i.Get("a")
+			b := i.Get("b")
+			inv := i.Inverse()
+
+			Convey("it should map IDs to keys", func() {
+				So(inv, ShouldResemble, map[int]string{a: "a", b: "b"})
+			})
+		})
+
 		Convey("when getting an ID of a nonexistent key with GetOrZero", func() {
 			id := i.GetOrZero("a")
 
@@ -66,3 +76,89 @@ func TestInternSaveLoad(t *testing.T) {
 		})
 	})
 }
+
+func TestInternHashing(t *testing.T) {
+	Convey("Given an Intern with hashing", t, func() {
+		i := New()
+		So(i.EnableHashing(4), ShouldBeNil)
+
+		Convey("when getting IDs of many keys", func() {
+			ids := map[int]struct{}{}
+			for c := 'a'; c <= 'z'; c++ {
+				ids[i.Get(string(c))] = struct{}{}
+			}
+
+			Convey("IDs should be bounded by the max size", func() {
+				So(len(ids), ShouldBeLessThanOrEqualTo, 4)
+				for id := range ids {
+					So(id, ShouldBeBetweenOrEqual, 1, 4)
+				}
+			})
+
+			Convey("keys shouldn't be stored", func() {
+				So(i.Inverse(), ShouldBeEmpty)
+			})
+
+			Convey("GetOrZero should return the same ID as Get", func() {
+				So(i.GetOrZero("a"), ShouldEqual, i.Get("a"))
+			})
+		})
+
+		Convey("when saving it", func() {
+			buf := bytes.NewBuffer(nil)
+			So(i.Save(buf), ShouldBeNil)
+
+			Convey("the loaded Intern should hash keys in the same way", func() {
+				i2, err := Load(buf)
+				So(err, ShouldBeNil)
+				So(i2.HashMaxSize(), ShouldEqual, 4)
+				So(i2.Get("a"), ShouldEqual, i.Get("a"))
+			})
+		})
+
+		Convey("when clearing it", func() {
+			i.Clear()
+
+			Convey("it should keep hashing", func() {
+				So(i.HashMaxSize(), ShouldEqual, 4)
+			})
+		})
+	})
+
+	Convey("Given an Intern with keys", t, func() {
+		i := New()
+		i.Get("a")
+
+		Convey("when enabling hashing", func() {
+			err := i.EnableHashing(4)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestInternCompact(t *testing.T) {
+	Convey("Given an Intern with keys", t, func() {
+		i := New()
+		a := i.Get("a")
+		b := i.Get("b")
+
+		Convey("when compacting it", func() {
+			n := i.Compact(func(id int) bool {
+				return id == a
+			})
+
+			Convey("unused keys should be removed", func() {
+				So(n, ShouldEqual, 1)
+				So(i.GetOrZero("a"), ShouldEqual, a)
+				So(i.GetOrZero("b"), ShouldEqual, 0)
+			})
+
+			Convey("a new key shouldn't reuse the removed ID", func() {
+				So(i.Get("c"), ShouldNotEqual, b)
+			})
+		})
+	})
+}
@@ -0,0 +1,209 @@
+// Package jubatus reads model files saved by servers of the original Jubatus.
+//
+// A model file has a 48-byte header, system data and user data. The header
+// has the magic number "jubatus\0", the format version, the version of
+// Jubatus, the CRC32 checksum of the file, and sizes of system data and user
+// data in big endian. System data is a msgpack array of the version,
+// timestamp, type, id and config of the server. User data is a msgpack array
+// of the version of the data and the data packed by the driver of the server.
+package jubatus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	headerSize    = 48
+	formatVersion = 1
+)
+
+var (
+	magic = []byte("jubatus\x00")
+
+	msgpackHandle = &codec.MsgpackHandle{}
+)
+
+func init() {
+	msgpackHandle.RawToString = true
+}
+
+// File is a model file saved by Jubatus.
+type File struct {
+	// Version is the major, minor and maintenance version of Jubatus which
+	// saved the file.
+	Version [3]uint32
+
+	Timestamp int64
+
+	// Type is the type of the server, e.g. "classifier".
+	Type string
+	ID   string
+
+	// Config is the configuration of the server in JSON.
+	Config string
+
+	UserDataVersion uint64
+
+	// Data is the data packed by the driver of the server. It's decoded as
+	// a generic msgpack value: arrays are []interface{}, maps are
+	// map[interface{}]interface{}, and strings are string.
+	Data interface{}
+}
+
+type systemData struct {
+	_struct   struct{} `codec:",toarray"`
+	Version   uint64
+	Timestamp int64
+	Type      string
+	ID        string
+	Config    string
+}
+
+// Read reads a model file saved by Jubatus.
+func Read(r io.Reader) (*File, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("cannot read the header: %v", err)
+	}
+	if !bytes.Equal(header[:8], magic) {
+		return nil, errors.New("the file isn't a model file of Jubatus")
+	}
+	if v := binary.BigEndian.Uint64(header[8:]); v != formatVersion {
+		return nil, fmt.Errorf("unsupported format version of Jubatus model file: %v", v)
+	}
+
+	f := &File{}
+	for i := range f.Version {
+		f.Version[i] = binary.BigEndian.Uint32(header[16+4*i:])
+	}
+	sum := binary.BigEndian.Uint32(header[28:])
+	systemSize := binary.BigEndian.Uint64(header[32:])
+	userSize := binary.BigEndian.Uint64(header[40:])
+
+	system, err := readN(r, systemSize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read system data: %v", err)
+	}
+	user, err := readN(r, userSize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read user data: %v", err)
+	}
+	if c := checksum(header, system, user); c != sum {
+		return nil, fmt.Errorf("checksum mismatch: %08x != %08x", c, sum)
+	}
+
+	var s systemData
+	if err := codec.NewDecoderBytes(system, msgpackHandle).Decode(&s); err != nil {
+		return nil, fmt.Errorf("cannot decode system data: %v", err)
+	}
+	f.Timestamp = s.Timestamp
+	f.Type = s.Type
+	f.ID = s.ID
+	f.Config = s.Config
+
+	var u []interface{}
+	if err := codec.NewDecoderBytes(user, msgpackHandle).Decode(&u); err != nil {
+		return nil, fmt.Errorf("cannot decode user data: %v", err)
+	}
+	if len(u) != 2 {
+		return nil, fmt.Errorf("user data must have 2 elements but has %v", len(u))
+	}
+	if f.UserDataVersion, err = toUint(u[0]); err != nil {
+		return nil, fmt.Errorf("invalid version of user data: %v", err)
+	}
+	f.Data = u[1]
+	return f, nil
+}
+
+// Write writes a model file in the same format as Jubatus does.
+func Write(w io.Writer, f *File) error {
+	var system []byte
+	if err := codec.NewEncoderBytes(&system, msgpackHandle).Encode(&systemData{
+		Version:   1,
+		Timestamp: f.Timestamp,
+		Type:      f.Type,
+		ID:        f.ID,
+		Config:    f.Config,
+	}); err != nil {
+		return err
+	}
+	var user []byte
+	if err := codec.NewEncoderBytes(&user, msgpackHandle).Encode([]interface{}{f.UserDataVersion, f.Data}); err != nil {
+		return err
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint64(header[8:], formatVersion)
+	for i, v := range f.Version {
+		binary.BigEndian.PutUint32(header[16+4*i:], v)
+	}
+	binary.BigEndian.PutUint64(header[32:], uint64(len(system)))
+	binary.BigEndian.PutUint64(header[40:], uint64(len(user)))
+	binary.BigEndian.PutUint32(header[28:], checksum(header, system, user))
+
+	for _, b := range [][]byte{header, system, user} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checksum computes CRC32 of a file except the field of the checksum in the
+// header.
+func checksum(header, system, user []byte) uint32 {
+	c := crc32.Update(0, crc32.IEEETable, header[:28])
+	c = crc32.Update(c, crc32.IEEETable, header[32:])
+	c = crc32.Update(c, crc32.IEEETable, system)
+	return crc32.Update(c, crc32.IEEETable, user)
+}
+
+func readN(r io.Reader, n uint64) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+// Method returns the method and parameters in the config of the file.
+func (f *File) Method() (string, map[string]interface{}, error) {
+	var c struct {
+		Method    string                 `json:"method"`
+		Parameter map[string]interface{} `json:"parameter"`
+	}
+	if err := json.Unmarshal([]byte(f.Config), &c); err != nil {
+		return "", nil, fmt.Errorf("invalid config: %v", err)
+	}
+	if c.Method == "" {
+		return "", nil, errors.New("config doesn't have method")
+	}
+	if c.Parameter == nil {
+		c.Parameter = map[string]interface{}{}
+	}
+	return c.Method, c.Parameter, nil
+}
+
+// ReadFile reads a model file having the path.
+func ReadFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(bufio.NewReader(f))
+}
@@ -0,0 +1,161 @@
+package jubatus
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestReadWrite(t *testing.T) {
+	Convey("Given a model file", t, func() {
+		f := &File{
+			Version:         [3]uint32{1, 0, 9},
+			Timestamp:       1234567890,
+			Type:            "classifier",
+			ID:              "model",
+			Config:          `{"method":"AROW","parameter":{"regularization_weight":1.0}}`,
+			UserDataVersion: 1,
+			Data:            []interface{}{"a", []interface{}{"b"}},
+		}
+		buf := bytes.NewBuffer(nil)
+		So(Write(buf, f), ShouldBeNil)
+
+		Convey("when reading it", func() {
+			g, err := Read(bytes.NewReader(buf.Bytes()))
+
+			Convey("it should have the same content", func() {
+				So(err, ShouldBeNil)
+				So(g, ShouldResemble, f)
+			})
+
+			Convey("its config should be parsed", func() {
+				m, ps, err := g.Method()
+				So(err, ShouldBeNil)
+				So(m, ShouldEqual, "AROW")
+				So(ps["regularization_weight"], ShouldEqual, 1.0)
+			})
+		})
+
+		Convey("when reading it with a broken byte", func() {
+			b := buf.Bytes()
+			b[len(b)-1] ^= 1
+			_, err := Read(bytes.NewReader(b))
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when reading it with a wrong magic number", func() {
+			b := buf.Bytes()
+			b[0] = 'J'
+			_, err := Read(bytes.NewReader(b))
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when reading a truncated file", func() {
+			_, err := Read(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestLinearClassifier(t *testing.T) {
+	Convey("Given a classifier model file having a storage with a diff", t, func() {
+		s := PackStorage(&Storage{
+			Weights: map[string]map[string]Val3{
+				"x": {"a": {1, 0.5, 0}, "b": {-1, 0.5, 0}},
+			},
+			Labels: []string{"a", "b", "c"},
+		}).([]interface{})
+		// labels "a" and "b" have IDs 0 and 1.
+		s[2] = map[interface{}]interface{}{
+			"x": map[interface{}]interface{}{uint64(0): []interface{}{0.5, -0.25, 0.0}},
+		}
+		labels := []interface{}{
+			map[interface{}]interface{}{"a": uint64(2), "b": uint64(1)},
+			map[interface{}]interface{}{"a": uint64(1)},
+			[]interface{}{uint64(0)},
+		}
+
+		buf := bytes.NewBuffer(nil)
+		So(Write(buf, &File{
+			Type:   "classifier",
+			Config: `{"method":"AROW"}`,
+			Data:   []interface{}{[]interface{}{s, labels}, []interface{}{}},
+		}), ShouldBeNil)
+		f, err := Read(buf)
+		So(err, ShouldBeNil)
+
+		Convey("when reading its storage", func() {
+			st, counts, err := f.LinearClassifier()
+			So(err, ShouldBeNil)
+
+			Convey("weights should be the sum of the table and the diff", func() {
+				So(st.Weights, ShouldResemble, map[string]map[string]Val3{
+					"x": {"a": {1.5, 0.25, 0}, "b": {-1, 0.5, 0}},
+				})
+			})
+
+			Convey("it should have all labels", func() {
+				So(st.Labels, ShouldHaveLength, 3)
+				So(counts, ShouldResemble, map[string]uint64{"a": 3, "b": 1})
+			})
+		})
+
+		Convey("when reading it as a regression", func() {
+			_, err := f.LinearRegression()
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestNearestNeighborTable(t *testing.T) {
+	Convey("Given an anomaly model file having a table", t, func() {
+		bits := []byte{}
+		for _, w := range []byte{1, 2} {
+			bits = append(bits, w, 0, 0, 0, 0, 0, 0, 0)
+		}
+		tbl := PackTable(&Table{
+			Keys: []string{"1", "2"},
+			Columns: []Column{
+				{Type: FloatColumn, Floats: []float32{1, 2}},
+			},
+		}).([]interface{})
+		cols := tbl[3].([]interface{})
+		tbl[3] = append([]interface{}{
+			[]interface{}{[]interface{}{uint64(BitVectorColumn), uint64(8)}, bits},
+		}, cols...)
+
+		buf := bytes.NewBuffer(nil)
+		So(Write(buf, &File{
+			Type:   "anomaly",
+			Config: `{"method":"light_lof"}`,
+			Data:   []interface{}{[]interface{}{[]interface{}{tbl}, []interface{}{}}, []interface{}{}},
+		}), ShouldBeNil)
+		f, err := Read(buf)
+		So(err, ShouldBeNil)
+
+		Convey("when reading its table", func() {
+			t, err := f.NearestNeighborTable()
+
+			Convey("it should have rows", func() {
+				So(err, ShouldBeNil)
+				So(t.Keys, ShouldResemble, []string{"1", "2"})
+				So(t.Columns, ShouldHaveLength, 2)
+				So(t.Columns[0].BitNum, ShouldEqual, 8)
+				So(t.Columns[0].Bits, ShouldResemble, [][]uint64{{1}, {2}})
+				So(t.Columns[1].Floats, ShouldResemble, []float32{1, 2})
+			})
+		})
+	})
+}
@@ -0,0 +1,271 @@
+package jubatus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Val3 is a value of a feature for a label in a storage. Linear models use
+// V1 as the weight and AROW, CW and NHERD use V2 as the covariance.
+type Val3 struct {
+	V1, V2, V3 float64
+}
+
+// Storage is local_storage_mixture of Jubatus, which has weights of linear
+// models. It's packed as an array of the table, the key manager of labels,
+// the diff table and the model version. The table maps features to maps from
+// label IDs to Val3, which is an array of three numbers. The key manager is
+// an array of a map from labels to IDs and an array of labels.
+type Storage struct {
+	// Weights maps features to maps from labels to values. Values are the
+	// sum of the table and the diff table, which is not mixed yet.
+	Weights map[string]map[string]Val3
+
+	// Labels has labels registered to the storage.
+	Labels []string
+}
+
+// ParseStorage parses a packed local_storage_mixture.
+func ParseStorage(v interface{}) (*Storage, error) {
+	a, err := toArray(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(a) < 3 {
+		return nil, fmt.Errorf("storage must have at least 3 elements but has %v", len(a))
+	}
+
+	km, err := toArray(a[1])
+	if err != nil || len(km) == 0 {
+		return nil, errors.New("storage doesn't have a valid key manager")
+	}
+	key2id, err := toMap(km[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid key manager: %v", err)
+	}
+	s := &Storage{
+		Weights: map[string]map[string]Val3{},
+	}
+	labels := map[uint64]string{}
+	for k, id := range key2id {
+		l, err := toString(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label: %v", err)
+		}
+		i, err := toUint(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label ID: %v", err)
+		}
+		labels[i] = l
+		s.Labels = append(s.Labels, l)
+	}
+
+	for _, t := range []interface{}{a[0], a[2]} {
+		tbl, err := toMap(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid table: %v", err)
+		}
+		for f, vs := range tbl {
+			feature, err := toString(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid feature: %v", err)
+			}
+			m, err := toMap(vs)
+			if err != nil {
+				return nil, fmt.Errorf("invalid values of feature %v: %v", feature, err)
+			}
+			for id, val := range m {
+				i, err := toUint(id)
+				if err != nil {
+					return nil, fmt.Errorf("invalid label ID: %v", err)
+				}
+				l, ok := labels[i]
+				if !ok {
+					return nil, fmt.Errorf("unknown label ID: %v", i)
+				}
+				x, err := toVal3(val)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value of feature %v: %v", feature, err)
+				}
+
+				ws, ok := s.Weights[feature]
+				if !ok {
+					ws = map[string]Val3{}
+					s.Weights[feature] = ws
+				}
+				y := ws[l]
+				ws[l] = Val3{y.V1 + x.V1, y.V2 + x.V2, y.V3 + x.V3}
+			}
+		}
+	}
+	return s, nil
+}
+
+// PackStorage packs s as local_storage_mixture. All values are packed in the
+// table and the diff table is empty.
+func PackStorage(s *Storage) interface{} {
+	key2id := map[interface{}]interface{}{}
+	id2key := []interface{}{}
+	ids := map[string]uint64{}
+	for _, l := range s.Labels {
+		if _, ok := ids[l]; ok {
+			continue
+		}
+		ids[l] = uint64(len(id2key))
+		key2id[l] = uint64(len(id2key))
+		id2key = append(id2key, l)
+	}
+
+	tbl := map[interface{}]interface{}{}
+	for f, ws := range s.Weights {
+		m := map[interface{}]interface{}{}
+		for l, v := range ws {
+			id, ok := ids[l]
+			if !ok {
+				id = uint64(len(id2key))
+				ids[l] = id
+				key2id[l] = id
+				id2key = append(id2key, l)
+			}
+			m[id] = []interface{}{v.V1, v.V2, v.V3}
+		}
+		tbl[f] = m
+	}
+	return []interface{}{tbl, []interface{}{key2id, id2key}, map[interface{}]interface{}{}, []interface{}{uint64(0)}}
+}
+
+func toVal3(v interface{}) (Val3, error) {
+	a, err := toArray(v)
+	if err != nil {
+		return Val3{}, err
+	}
+	if len(a) != 3 {
+		return Val3{}, fmt.Errorf("val3 must have 3 elements but has %v", len(a))
+	}
+	var x [3]float64
+	for i := range x {
+		if x[i], err = toFloat(a[i]); err != nil {
+			return Val3{}, err
+		}
+	}
+	return Val3{x[0], x[1], x[2]}, nil
+}
+
+// isStorage returns true when v looks like a packed local_storage_mixture,
+// i.e. an array whose first element is a map and whose second element is an
+// array having a map.
+func isStorage(v interface{}) bool {
+	a, err := toArray(v)
+	if err != nil || len(a) < 3 {
+		return false
+	}
+	if _, err := toMap(a[0]); err != nil {
+		return false
+	}
+	km, err := toArray(a[1])
+	if err != nil || len(km) == 0 {
+		return false
+	}
+	_, err = toMap(km[0])
+	return err == nil
+}
+
+// LinearClassifier returns the storage and label counts of the model of
+// jubaclassifier using a linear method such as AROW. The driver packs an
+// array of the classifier and the weight manager, and the classifier packs
+// an array of the storage and the labels, which has a map from labels to the
+// number of trained data and the diff of it. Files saved by versions not
+// having the labels have only the storage, and their label counts are nil.
+func (f *File) LinearClassifier() (*Storage, map[string]uint64, error) {
+	if f.Type != "classifier" {
+		return nil, nil, fmt.Errorf("the model isn't a classifier but %v", f.Type)
+	}
+	d, err := toArray(f.Data)
+	if err != nil || len(d) == 0 {
+		return nil, nil, errors.New("invalid data of classifier")
+	}
+
+	if isStorage(d[0]) {
+		s, err := ParseStorage(d[0])
+		return s, nil, err
+	}
+	c, err := toArray(d[0])
+	if err != nil || len(c) < 2 || !isStorage(c[0]) {
+		return nil, nil, errors.New("classifier doesn't have a storage of a linear model")
+	}
+	s, err := ParseStorage(c[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	counts, err := parseLabels(c[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, counts, nil
+}
+
+func parseLabels(v interface{}) (map[string]uint64, error) {
+	a, err := toArray(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid labels: %v", err)
+	}
+	counts := map[string]uint64{}
+	for i := 0; i < len(a) && i < 2; i++ {
+		m, err := toMap(a[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid labels: %v", err)
+		}
+		for k, n := range m {
+			l, err := toString(k)
+			if err != nil {
+				return nil, fmt.Errorf("invalid label: %v", err)
+			}
+			c, err := toUint(n)
+			if err != nil {
+				return nil, fmt.Errorf("invalid count of label %v: %v", l, err)
+			}
+			counts[l] += c
+		}
+	}
+	return counts, nil
+}
+
+// LinearRegression returns the storage of the model of jubaregression using
+// a linear method such as PA. The driver packs an array of the regression
+// and the weight manager, and the regression packs the storage, which may be
+// wrapped in an array with other values. The storage has only one label, or
+// "+" when there're more than one labels, whose V1 is the weight.
+func (f *File) LinearRegression() (map[string]float64, error) {
+	if f.Type != "regression" {
+		return nil, fmt.Errorf("the model isn't a regression but %v", f.Type)
+	}
+	d, err := toArray(f.Data)
+	if err != nil || len(d) == 0 {
+		return nil, errors.New("invalid data of regression")
+	}
+
+	st := d[0]
+	if !isStorage(st) {
+		a, err := toArray(st)
+		if err != nil || len(a) == 0 || !isStorage(a[0]) {
+			return nil, errors.New("regression doesn't have a storage of a linear model")
+		}
+		st = a[0]
+	}
+	s, err := ParseStorage(st)
+	if err != nil {
+		return nil, err
+	}
+
+	label := "+"
+	if len(s.Labels) == 1 {
+		label = s.Labels[0]
+	}
+	ws := make(map[string]float64, len(s.Weights))
+	for f, vs := range s.Weights {
+		if v, ok := vs[label]; ok {
+			ws[f] = v.V1
+		}
+	}
+	return ws, nil
+}
@@ -0,0 +1,243 @@
+package jubatus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Column types of column_table.
+const (
+	FloatColumn     = 9
+	BitVectorColumn = 11
+)
+
+// Table is column_table of Jubatus, which nearest neighbor algorithms use to
+// store hash values of rows. It's packed as an array of keys, the number of
+// tuples, versions, columns, the clock and the index. Each column is an array
+// of its type, which is an array of the type ID and the length of bit
+// vectors, and its data, which is an array of values or a binary of little
+// endian values. A bit vector is stored as consecutive 64-bit words.
+type Table struct {
+	// Keys has IDs of rows.
+	Keys    []string
+	Columns []Column
+}
+
+// Column is a column of a Table.
+type Column struct {
+	Type int
+
+	// BitNum is the length of bit vectors of a BitVectorColumn.
+	BitNum int
+
+	// Bits has bit vectors of a BitVectorColumn. Each bit vector has bits
+	// from the least significant bit of the first word.
+	Bits [][]uint64
+
+	// Floats has values of a FloatColumn.
+	Floats []float32
+}
+
+// ParseTable parses a packed column_table.
+func ParseTable(v interface{}) (*Table, error) {
+	a, err := toArray(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(a) < 4 {
+		return nil, fmt.Errorf("column table must have at least 4 elements but has %v", len(a))
+	}
+
+	ks, err := toArray(a[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid keys of column table: %v", err)
+	}
+	t := &Table{
+		Keys: make([]string, len(ks)),
+	}
+	for i, k := range ks {
+		if t.Keys[i], err = toString(k); err != nil {
+			return nil, fmt.Errorf("invalid key of column table: %v", err)
+		}
+	}
+
+	cs, err := toArray(a[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid columns of column table: %v", err)
+	}
+	for i, c := range cs {
+		col, err := parseColumn(c, len(t.Keys))
+		if err != nil {
+			return nil, fmt.Errorf("invalid column %v: %v", i, err)
+		}
+		t.Columns = append(t.Columns, *col)
+	}
+	return t, nil
+}
+
+func parseColumn(v interface{}, rows int) (*Column, error) {
+	a, err := toArray(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(a) != 2 {
+		return nil, fmt.Errorf("column must have 2 elements but has %v", len(a))
+	}
+	typ, err := toArray(a[0])
+	if err != nil || len(typ) == 0 {
+		return nil, errors.New("column doesn't have a valid type")
+	}
+	id, err := toUint(typ[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid column type: %v", err)
+	}
+	c := &Column{Type: int(id)}
+
+	switch c.Type {
+	case BitVectorColumn:
+		if len(typ) < 2 {
+			return nil, errors.New("bit vector column doesn't have the length")
+		}
+		n, err := toUint(typ[1])
+		if err != nil || n == 0 {
+			return nil, errors.New("invalid length of bit vectors")
+		}
+		c.BitNum = int(n)
+		words, err := toWords(a[1])
+		if err != nil {
+			return nil, err
+		}
+		nw := (c.BitNum + 63) / 64
+		if len(words) != nw*rows {
+			return nil, fmt.Errorf("bit vector column must have %v words but has %v", nw*rows, len(words))
+		}
+		c.Bits = make([][]uint64, rows)
+		for i := range c.Bits {
+			c.Bits[i] = words[i*nw : (i+1)*nw]
+		}
+
+	case FloatColumn:
+		fs, err := toFloats(a[1])
+		if err != nil {
+			return nil, err
+		}
+		if len(fs) != rows {
+			return nil, fmt.Errorf("float column must have %v values but has %v", rows, len(fs))
+		}
+		c.Floats = fs
+
+	default:
+		return nil, fmt.Errorf("unsupported column type: %v", c.Type)
+	}
+	return c, nil
+}
+
+func toWords(v interface{}) ([]uint64, error) {
+	if b, ok := toBinary(v); ok {
+		if len(b)%8 != 0 {
+			return nil, errors.New("size of binary bit vectors must be a multiple of 8")
+		}
+		ws := make([]uint64, len(b)/8)
+		for i := range ws {
+			ws[i] = binary.LittleEndian.Uint64(b[i*8:])
+		}
+		return ws, nil
+	}
+
+	a, err := toArray(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bit vectors: %v", err)
+	}
+	ws := make([]uint64, len(a))
+	for i, x := range a {
+		if ws[i], err = toUint(x); err != nil {
+			return nil, fmt.Errorf("invalid bit vectors: %v", err)
+		}
+	}
+	return ws, nil
+}
+
+func toFloats(v interface{}) ([]float32, error) {
+	if b, ok := toBinary(v); ok {
+		if len(b)%4 != 0 {
+			return nil, errors.New("size of binary floats must be a multiple of 4")
+		}
+		fs := make([]float32, len(b)/4)
+		for i := range fs {
+			fs[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+		}
+		return fs, nil
+	}
+
+	a, err := toArray(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid floats: %v", err)
+	}
+	fs := make([]float32, len(a))
+	for i, x := range a {
+		f, err := toFloat(x)
+		if err != nil {
+			return nil, fmt.Errorf("invalid floats: %v", err)
+		}
+		fs[i] = float32(f)
+	}
+	return fs, nil
+}
+
+// PackTable packs t as column_table. Data of columns are packed as arrays.
+func PackTable(t *Table) interface{} {
+	keys := make([]interface{}, len(t.Keys))
+	index := map[interface{}]interface{}{}
+	versions := make([]interface{}, len(t.Keys))
+	for i, k := range t.Keys {
+		keys[i] = k
+		index[k] = uint64(i)
+		versions[i] = []interface{}{"", uint64(0)}
+	}
+
+	cols := make([]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		d := []interface{}{}
+		switch c.Type {
+		case BitVectorColumn:
+			for _, ws := range c.Bits {
+				for _, w := range ws {
+					d = append(d, w)
+				}
+			}
+		case FloatColumn:
+			for _, f := range c.Floats {
+				d = append(d, f)
+			}
+		}
+		cols[i] = []interface{}{[]interface{}{uint64(c.Type), uint64(c.BitNum)}, d}
+	}
+	return []interface{}{keys, uint64(len(t.Keys)), versions, cols, uint64(0), index}
+}
+
+// NearestNeighborTable returns the table of the nearest neighbor engine of
+// the model of jubaanomaly using LOF or light_lof. The driver packs an array
+// of the anomaly detector and the weight manager, the detector packs an
+// array of the nearest neighbor engine and the scores, and the engine packs
+// the table, which may be wrapped in an array with other values.
+func (f *File) NearestNeighborTable() (*Table, error) {
+	if f.Type != "anomaly" {
+		return nil, fmt.Errorf("the model isn't an anomaly detector but %v", f.Type)
+	}
+	d, err := toArray(f.Data)
+	if err != nil || len(d) == 0 {
+		return nil, errors.New("invalid data of anomaly detector")
+	}
+	a, err := toArray(d[0])
+	if err != nil || len(a) == 0 {
+		return nil, errors.New("invalid data of anomaly detector")
+	}
+
+	nn := a[0]
+	if e, err := toArray(nn); err == nil && len(e) > 0 && len(e) < 4 {
+		nn = e[0]
+	}
+	return ParseTable(nn)
+}
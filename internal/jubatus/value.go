@@ -0,0 +1,73 @@
+package jubatus
+
+import (
+	"fmt"
+)
+
+func toArray(v interface{}) ([]interface{}, error) {
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%T isn't an array", v)
+	}
+	return a, nil
+}
+
+func toMap(v interface{}) (map[interface{}]interface{}, error) {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%T isn't a map", v)
+	}
+	return m, nil
+}
+
+func toString(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case []byte:
+		return string(s), nil
+	default:
+		return "", fmt.Errorf("%T isn't a string", v)
+	}
+}
+
+func toUint(v interface{}) (uint64, error) {
+	switch x := v.(type) {
+	case uint64:
+		return x, nil
+	case int64:
+		if x < 0 {
+			return 0, fmt.Errorf("%v is negative", x)
+		}
+		return uint64(x), nil
+	default:
+		return 0, fmt.Errorf("%T isn't an integer", v)
+	}
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case uint64:
+		return float64(x), nil
+	default:
+		return 0, fmt.Errorf("%T isn't a number", v)
+	}
+}
+
+// toBinary returns v as bytes when it's a string or a binary.
+func toBinary(v interface{}) ([]byte, bool) {
+	switch b := v.(type) {
+	case string:
+		return []byte(b), true
+	case []byte:
+		return b, true
+	default:
+		return nil, false
+	}
+}
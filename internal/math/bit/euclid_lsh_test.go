@@ -0,0 +1,22 @@
+package bit
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestPartialInsertionSort(t *testing.T) {
+	Convey("Given distances", t, func() {
+		dists := []IDist{{1, 3}, {2, 1}, {3, 5}, {4, 2}}
+
+		Convey("sorting them for one element should give the smallest one", func() {
+			partialInsertionSort(dists, 1)
+			So(dists[0], ShouldResemble, IDist{2, 1})
+		})
+
+		Convey("sorting them for two elements should give the smallest ones", func() {
+			partialInsertionSort(dists, 2)
+			So(dists[:2], ShouldResemble, []IDist{{2, 1}, {4, 2}})
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package nearest
+
+import (
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestPartialSortByDist(t *testing.T) {
+	Convey("Given distances", t, func() {
+		r := rand.New(rand.NewSource(0))
+		dists := make([]IDist, 200)
+		for i := range dists {
+			dists[i] = IDist{ID: ID(i + 1), Dist: float32(r.Intn(50))}
+		}
+		sorted := make([]IDist, len(dists))
+		copy(sorted, dists)
+		sort.Sort(sortByDist(sorted))
+
+		for _, n := range []int{1, 2, 64, 65, 70, 100} {
+			n := n
+			Convey(fmt.Sprintf("partially sorting them for %v elements should give the smallest ones", n), func() {
+				ds := make([]IDist, len(dists))
+				copy(ds, dists)
+				partialSortByDist(ds, n)
+				So(ds[:n], ShouldResemble, sorted[:n])
+			})
+		}
+
+		Convey("partial insertion sort for one element should give the smallest one", func() {
+			ds := make([]IDist, len(dists))
+			copy(ds, dists)
+			partialInsertionSort(ds, 1)
+			So(ds[0], ShouldResemble, sorted[0])
+		})
+	})
+}
+
+func TestLSHNeighborRow(t *testing.T) {
+	Convey("Given LSH having rows", t, func() {
+		l := NewLSH(64)
+		r := rand.New(rand.NewSource(0))
+		for i := 1; i <= 200; i++ {
+			l.SetRow(ID(i), FeatureVector{
+				{Dim: "x", Value: float32(r.NormFloat64())},
+				{Dim: "y", Value: float32(r.NormFloat64())},
+			})
+		}
+
+		for _, n := range []int{1, 65, 100} {
+			n := n
+			Convey(fmt.Sprintf("searching %v neighbors should return the nearest rows", n), func() {
+				for id := ID(1); id <= 200; id++ {
+					all := l.NeighborRowFromID(id, 200)
+					So(l.NeighborRowFromID(id, n), ShouldResemble, all[:n])
+				}
+			})
+		}
+	})
+}
@@ -91,6 +91,37 @@ func (e *EuclidLSH) SetRow(id ID, v FeatureVector) {
 	e.norms[id-1] = l2Norm(v)
 }
 
+func (e *EuclidLSH) empty() Neighbor {
+	return NewEuclidLSH(e.lshs.BitNum())
+}
+
+func (e *EuclidLSH) copyRow(id ID, src Neighbor, srcID ID) error {
+	s, ok := src.(*EuclidLSH)
+	if !ok {
+		return fmt.Errorf("cannot copy a row of %v to euclid_lsh", src.name())
+	}
+	if s.lshs.BitNum() != e.lshs.BitNum() {
+		return fmt.Errorf("number of hash bits doesn't match: %v != %v", s.lshs.BitNum(), e.lshs.BitNum())
+	}
+	v, err := s.lshs.Get(int(srcID - 1))
+	if err != nil {
+		return err
+	}
+	if len(e.norms) < int(id) {
+		e.extend(int(id))
+	}
+	if err := e.lshs.Set(int(id-1), v); err != nil {
+		return err
+	}
+	e.norms[id-1] = s.norms[srcID-1]
+	return nil
+}
+
+func (e *EuclidLSH) Clear() {
+	e.lshs = bit.NewArray(e.lshs.BitNum())
+	e.norms = nil
+}
+
 func (e *EuclidLSH) NeighborRowFromID(id ID, size int) []IDist {
 	lsh, _ := e.lshs.Get(int(id - 1))
 	return e.neighborRowFromHash(lsh, e.norms[id-1], size)
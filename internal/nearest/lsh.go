@@ -60,6 +60,32 @@ func (l *LSH) SetRow(id ID, v FeatureVector) {
 	l.data.Set(int(id-1), l.hash(v))
 }
 
+func (l *LSH) empty() Neighbor {
+	return NewLSH(l.data.BitNum())
+}
+
+func (l *LSH) copyRow(id ID, src Neighbor, srcID ID) error {
+	s, ok := src.(*LSH)
+	if !ok {
+		return fmt.Errorf("cannot copy a row of %v to lsh", src.name())
+	}
+	if s.data.BitNum() != l.data.BitNum() {
+		return fmt.Errorf("number of hash bits doesn't match: %v != %v", s.data.BitNum(), l.data.BitNum())
+	}
+	v, err := s.data.Get(int(srcID - 1))
+	if err != nil {
+		return err
+	}
+	if int(id) > l.data.Len() {
+		l.data.Resize(int(id))
+	}
+	return l.data.Set(int(id-1), v)
+}
+
+func (l *LSH) Clear() {
+	l.data = bit.NewArray(l.data.BitNum())
+}
+
 func (l *LSH) NeighborRowFromID(id ID, size int) []IDist {
 	hash, _ := l.data.Get(int(id - 1))
 	return l.neighborRowFromFV(hash, size)
@@ -61,6 +61,32 @@ func (m *Minhash) SetRow(id ID, v FeatureVector) {
 	m.data.Set(int(id-1), m.hash(v))
 }
 
+func (m *Minhash) empty() Neighbor {
+	return NewMinhash(m.data.BitNum())
+}
+
+func (m *Minhash) copyRow(id ID, src Neighbor, srcID ID) error {
+	s, ok := src.(*Minhash)
+	if !ok {
+		return fmt.Errorf("cannot copy a row of %v to minhash", src.name())
+	}
+	if s.data.BitNum() != m.data.BitNum() {
+		return fmt.Errorf("number of hash bits doesn't match: %v != %v", s.data.BitNum(), m.data.BitNum())
+	}
+	v, err := s.data.Get(int(srcID - 1))
+	if err != nil {
+		return err
+	}
+	if int(id) > m.data.Len() {
+		m.data.Resize(int(id))
+	}
+	return m.data.Set(int(id-1), v)
+}
+
+func (m *Minhash) Clear() {
+	m.data = bit.NewArray(m.data.BitNum())
+}
+
 func (m *Minhash) NeighborRowFromID(id ID, size int) []IDist {
 	hash, _ := m.data.Get(int(id - 1))
 	return m.neighborRowFromHash(hash, size)
@@ -11,9 +11,24 @@ type Neighbor interface {
 	SetRow(id ID, v FeatureVector)
 	NeighborRowFromID(id ID, size int) []IDist
 	NeighborRowFromFV(v FeatureVector, size int) []IDist
+	Clear()
 
 	name() string
 	save(w io.Writer) error
+	empty() Neighbor
+	copyRow(id ID, src Neighbor, srcID ID) error
+}
+
+// NewEmpty creates a Neighbor which has the same algorithm and the same
+// number of hash bits as n but doesn't have any row.
+func NewEmpty(n Neighbor) Neighbor {
+	return n.empty()
+}
+
+// CopyRow copies the row of src having srcID to the row of dst having id.
+// dst and src must have the same algorithm and the same number of hash bits.
+func CopyRow(dst Neighbor, id ID, src Neighbor, srcID ID) error {
+	return dst.copyRow(id, src, srcID)
 }
 
 type FeatureElement struct {
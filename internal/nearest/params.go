@@ -0,0 +1,155 @@
+package nearest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zeromberto/jubatus/internal/nested"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"strings"
+)
+
+// Algorithm is an enum type which represents nearest neighbor algorithms.
+type Algorithm int
+
+const (
+	// InvalidAlgorithm represents an invalid nearest neighbor algorithm.
+	InvalidAlgorithm Algorithm = iota
+	// LSHAlgorithm represents locality sensitive hashing.
+	LSHAlgorithm
+	// MinhashAlgorithm represents minhash.
+	MinhashAlgorithm
+	// EuclidLSHAlgorithm represents locality sensitive hashing with
+	// euclidean distance.
+	EuclidLSHAlgorithm
+)
+
+// ParseAlgorithm returns the algorithm having the name, which is one of lsh,
+// minhash, and euclid_lsh. The name is case-insensitive. It returns
+// InvalidAlgorithm when the name is unknown.
+func ParseAlgorithm(name string) Algorithm {
+	switch strings.ToLower(name) {
+	case "lsh":
+		return LSHAlgorithm
+	case "minhash":
+		return MinhashAlgorithm
+	case "euclid_lsh":
+		return EuclidLSHAlgorithm
+	default:
+		return InvalidAlgorithm
+	}
+}
+
+// New creates a Neighbor of the algorithm using hashNum hash bits.
+func New(algo Algorithm, hashNum int) (Neighbor, error) {
+	switch algo {
+	case LSHAlgorithm:
+		return NewLSH(hashNum), nil
+	case MinhashAlgorithm:
+		return NewMinhash(hashNum), nil
+	case EuclidLSHAlgorithm:
+		return NewEuclidLSH(hashNum), nil
+	default:
+		return nil, errors.New("invalid nearest neighbor algorithm")
+	}
+}
+
+// Params has parameters of models using nearest neighbor search.
+type Params struct {
+	Algorithm Algorithm
+	HashNum   int
+	NNNum     int
+
+	// MaxSize is the max number of rows kept by the random unlearner. It's
+	// zero when the unlearner isn't used.
+	MaxSize int
+	Seed    int64
+}
+
+// ExtractParams extracts Params from parameters of a state. The following
+// parameters are used:
+//
+//	nearest_neighbor_algorithm: lsh, minhash, or euclid_lsh
+//	hash_num: the number of hash bits
+//	nearest_neighbor_num: the number of nearest neighbors
+//	unlearner: no (default) or random
+//	max_size: the max number of rows, required when unlearner is random
+//	seed: the seed of the random unlearner (default 0)
+func ExtractParams(params data.Map) (*Params, error) {
+	nnAlgoName, err := pluginutil.ExtractParamAsString(params, "nearest_neighbor_algorithm")
+	if err != nil {
+		return nil, err
+	}
+	nnAlgo := ParseAlgorithm(nnAlgoName)
+	if nnAlgo == InvalidAlgorithm {
+		return nil, fmt.Errorf("invalid nearest_neighbor_algorithm: %s", nnAlgoName)
+	}
+
+	hashNum, err := pluginutil.ExtractParamAsInt(params, "hash_num")
+	if err != nil {
+		return nil, err
+	}
+	nnNum, err := pluginutil.ExtractParamAsInt(params, "nearest_neighbor_num")
+	if err != nil {
+		return nil, err
+	}
+
+	unlearn, err := pluginutil.ExtractParamAsStringWithDefault(params, "unlearner", "no")
+	if err != nil {
+		return nil, err
+	}
+	var maxSize int
+	var seed int64
+	switch unlearn {
+	case "no":
+		maxSize = 0
+	case "random":
+		m, err := pluginutil.ExtractParamAsInt(params, "max_size")
+		if err != nil {
+			return nil, err
+		}
+		maxSize = int(m)
+
+		seed, err = pluginutil.ExtractParamAsIntWithDefault(params, "seed", 0)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid unlearner: %v", unlearn)
+	}
+
+	return &Params{
+		Algorithm: nnAlgo,
+		HashNum:   int(hashNum),
+		NNNum:     int(nnNum),
+		MaxSize:   maxSize,
+		Seed:      seed,
+	}, nil
+}
+
+// ExtractLocalSensitivity extracts local_sensitivity parameter, which
+// controls how much distances to neighbors affect their weights. It's 1 when
+// the parameter isn't given.
+func ExtractLocalSensitivity(params data.Map) (float32, error) {
+	if _, ok := params["local_sensitivity"]; !ok {
+		return 1, nil
+	}
+	ls, err := pluginutil.ExtractParamAndConvertToFloat(params, "local_sensitivity")
+	if err != nil {
+		return 0, err
+	}
+	return float32(ls), nil
+}
+
+// FeatureVectorFromMap converts a nested map into a feature vector. Keys of
+// nested values are flattened.
+func FeatureVectorFromMap(v data.Map) (FeatureVector, error) {
+	ret := make(FeatureVector, 0, len(v))
+	err := nested.Flatten(v, func(key string, value float32) {
+		ret = append(ret, FeatureElement{Dim: key, Value: value})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,83 @@
+package nearest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestExtractParams(t *testing.T) {
+	Convey("Given parameters with the random unlearner", t, func() {
+		params := data.Map{
+			"nearest_neighbor_algorithm": data.String("Euclid_LSH"),
+			"hash_num":                   data.Int(64),
+			"nearest_neighbor_num":       data.Int(3),
+			"unlearner":                  data.String("random"),
+			"max_size":                   data.Int(100),
+			"seed":                       data.Int(7),
+		}
+
+		Convey("when extracting them", func() {
+			p, err := ExtractParams(params)
+			So(err, ShouldBeNil)
+
+			Convey("they should be set", func() {
+				So(p, ShouldResemble, &Params{
+					Algorithm: EuclidLSHAlgorithm,
+					HashNum:   64,
+					NNNum:     3,
+					MaxSize:   100,
+					Seed:      7,
+				})
+			})
+		})
+
+		Convey("when max_size is missing", func() {
+			delete(params, "max_size")
+
+			Convey("extracting them should fail", func() {
+				_, err := ExtractParams(params)
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when the algorithm is unknown", func() {
+			params["nearest_neighbor_algorithm"] = data.String("kd_tree")
+
+			Convey("extracting them should fail", func() {
+				_, err := ExtractParams(params)
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when the unlearner is unknown", func() {
+			params["unlearner"] = data.String("lru")
+
+			Convey("extracting them should fail", func() {
+				_, err := ExtractParams(params)
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given parameters without local_sensitivity", t, func() {
+		Convey("the local sensitivity should be 1", func() {
+			ls, err := ExtractLocalSensitivity(data.Map{})
+			So(err, ShouldBeNil)
+			So(ls, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestFeatureVectorFromMap(t *testing.T) {
+	Convey("Given a nested map", t, func() {
+		v := data.Map{"a": data.Map{"b": data.Float(2)}}
+
+		Convey("it should be converted into a flattened feature vector", func() {
+			fv, err := FeatureVectorFromMap(v)
+			So(err, ShouldBeNil)
+			So(len(fv), ShouldEqual, 1)
+			So(fv[0].Value, ShouldEqual, 2)
+		})
+	})
+}
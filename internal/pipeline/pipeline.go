@@ -0,0 +1,39 @@
+// Package pipeline converts raw values into feature vectors in the same way
+// among states of all kinds of models.
+package pipeline
+
+import (
+	"github.com/zeromberto/jubatus/fvconv"
+	"github.com/zeromberto/jubatus/normalizer"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// FeatureVector converts a value into a feature vector with conv and
+// normalizes it with norm. conv and norm can be nil, in which case the step
+// is skipped. training is true when the vector is used to train the model,
+// which updates statistics of conv and norm.
+func FeatureVector(conv *fvconv.Converter, norm *normalizer.Normalizer, v data.Map, training bool) (data.Map, error) {
+	fv := v
+	var err error
+	if conv != nil {
+		if training {
+			fv, err = conv.ConvertForTraining(fv)
+		} else {
+			fv, err = conv.Convert(fv)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if norm != nil {
+		if training {
+			fv, err = norm.NormalizeForTraining(fv)
+		} else {
+			fv, err = norm.Normalize(fv)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fv, nil
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/zeromberto/jubatus/normalizer"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestFeatureVector(t *testing.T) {
+	Convey("Given no converter and no normalizer", t, func() {
+		Convey("the value should be returned as it is", func() {
+			v := data.Map{"x": data.Float(1)}
+			fv, err := FeatureVector(nil, nil, v, true)
+			So(err, ShouldBeNil)
+			So(fv, ShouldResemble, v)
+		})
+	})
+
+	Convey("Given a normalizer", t, func() {
+		n, err := normalizer.New("min_max")
+		So(err, ShouldBeNil)
+
+		Convey("when converting values for training", func() {
+			for _, x := range []float64{0, 4} {
+				_, err := FeatureVector(nil, n, data.Map{"x": data.Float(x)}, true)
+				So(err, ShouldBeNil)
+			}
+
+			Convey("a value should be normalized with trained statistics", func() {
+				fv, err := FeatureVector(nil, n, data.Map{"x": data.Float(1)}, false)
+				So(err, ShouldBeNil)
+				So(fv["x"], ShouldEqual, data.Float(0.25))
+			})
+
+			Convey("converting a value not for training shouldn't update statistics", func() {
+				_, err := FeatureVector(nil, n, data.Map{"x": data.Float(8)}, false)
+				So(err, ShouldBeNil)
+				fv, err := FeatureVector(nil, n, data.Map{"x": data.Float(2)}, false)
+				So(err, ShouldBeNil)
+				So(fv["x"], ShouldEqual, data.Float(0.5))
+			})
+		})
+	})
+}
@@ -30,6 +30,18 @@ func ExtractParamAsString(params data.Map, key string) (string, error) {
 	return s, nil
 }
 
+func ExtractParamAsBoolWithDefault(params data.Map, key string, def bool) (bool, error) {
+	v, ok := params[key]
+	if !ok {
+		return def, nil
+	}
+	b, err := data.AsBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s parameter is not a bool: %v", key, err)
+	}
+	return b, nil
+}
+
 func ExtractParamAsIntWithDefault(params data.Map, key string, def int64) (int64, error) {
 	v, ok := params[key]
 	if !ok {
@@ -0,0 +1,47 @@
+// Package mix provides model mixing, which combines models of states trained
+// in parallel into one model, as Jubatus does to scale out.
+package mix
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+)
+
+// Mixer is implemented by states which can mix models of other states.
+type Mixer interface {
+	// Mix replaces the model of the state with the mixture of models of
+	// sources. The state itself can be in sources. Sources must be states of
+	// the same algorithm having compatible parameters.
+	Mix(sources []core.SharedState) error
+}
+
+// Mix replaces the model of the state having target with the mixture of
+// models of states having sources. It returns the number of mixed states.
+func Mix(ctx *core.Context, target string, sources []string) (int, error) {
+	if len(sources) == 0 {
+		return 0, errors.New("at least one source state is required")
+	}
+
+	st, err := ctx.SharedStates.Get(target)
+	if err != nil {
+		return 0, err
+	}
+	m, ok := st.(Mixer)
+	if !ok {
+		return 0, fmt.Errorf("state '%v' doesn't support mixing", target)
+	}
+
+	srcs := make([]core.SharedState, len(sources))
+	for i, name := range sources {
+		s, err := ctx.SharedStates.Get(name)
+		if err != nil {
+			return 0, err
+		}
+		srcs[i] = s
+	}
+	if err := m.Mix(srcs); err != nil {
+		return 0, err
+	}
+	return len(srcs), nil
+}
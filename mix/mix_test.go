@@ -0,0 +1,93 @@
+package mix
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"testing"
+)
+
+type mixerState struct {
+	mixed []core.SharedState
+	err   error
+}
+
+func (s *mixerState) Terminate(ctx *core.Context) error {
+	return nil
+}
+
+func (s *mixerState) Mix(sources []core.SharedState) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.mixed = sources
+	return nil
+}
+
+type nonMixerState struct {
+}
+
+func (s *nonMixerState) Terminate(ctx *core.Context) error {
+	return nil
+}
+
+func TestMix(t *testing.T) {
+	ctx := core.NewContext(nil)
+	target := &mixerState{}
+	a := &mixerState{}
+	failing := &mixerState{err: errors.New("failure")}
+	for name, s := range map[string]core.SharedState{
+		"target":  target,
+		"a":       a,
+		"failing": failing,
+		"other":   &nonMixerState{},
+	} {
+		if err := ctx.SharedStates.Add(name, "test", s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	Convey("Given states registered to the context", t, func() {
+		Convey("when mixing them", func() {
+			n, err := Mix(ctx, "target", []string{"target", "a"})
+
+			Convey("the target state should receive the sources", func() {
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 2)
+				So(target.mixed, ShouldResemble, []core.SharedState{target, a})
+			})
+		})
+
+		Convey("when mixing them without sources", func() {
+			_, err := Mix(ctx, "target", nil)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when mixing into a state not supporting mixing", func() {
+			_, err := Mix(ctx, "other", []string{"a"})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when mixing with a nonexistent state", func() {
+			_, err := Mix(ctx, "target", []string{"a", "nonexistent"})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when the target state fails to mix", func() {
+			_, err := Mix(ctx, "failing", []string{"a"})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,14 @@
+package plugin
+
+import (
+	"github.com/zeromberto/jubatus/mix"
+	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
+)
+
+func init() {
+	udf.MustRegisterGlobalUDSCreator("juba_mix_server", &mix.ServerStateCreator{})
+	udf.MustRegisterGlobalUDSCreator("juba_mixer", &mix.MixerStateCreator{})
+
+	udf.MustRegisterGlobalUDF("juba_mix", udf.MustConvertGeneric(mix.Mix))
+	udf.MustRegisterGlobalUDF("juba_mix_peers", udf.MustConvertGeneric(mix.MixWithPeers))
+}
@@ -0,0 +1,194 @@
+package mix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RemoteMixer is implemented by states which can be mixed with models of
+// states in other processes. Models are transferred in the format which Save
+// writes.
+type RemoteMixer interface {
+	Mixer
+	core.SavableSharedState
+
+	// LoadMixSource loads a model saved by Save of a state of the same type.
+	// The returned state is only used as a source of Mix.
+	LoadMixSource(ctx *core.Context, r io.Reader) (core.SharedState, error)
+}
+
+const statesPath = "/states/"
+
+// Handler returns an http.Handler which serves models of states in ctx to
+// peers. GET /states/<name> returns the saved model of the state, and PUT
+// /states/<name> mixes the model in the request body with the current model
+// of the state. Because the state can be trained after a peer pulled its
+// model, the pushed model doesn't simply replace the current one, which would
+// lose what the state has learned in the meantime. Because the handler
+// doesn't authenticate peers, it must only be exposed to trusted networks.
+func Handler(ctx *core.Context) http.Handler {
+	return &handler{ctx: ctx}
+}
+
+type handler struct {
+	ctx *core.Context
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, statesPath) {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, statesPath)
+	st, err := lookupRemoteMixer(h.ctx, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		buf := bytes.NewBuffer(nil)
+		if err := st.Save(h.ctx, buf, data.Map{}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(buf.Bytes())
+
+	case "PUT":
+		src, err := st.LoadMixSource(h.ctx, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := st.Mix([]core.SharedState{st, src}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func lookupRemoteMixer(ctx *core.Context, stateName string) (RemoteMixer, error) {
+	st, err := ctx.SharedStates.Get(stateName)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := st.(RemoteMixer); ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("state '%v' doesn't support remote mixing", stateName)
+}
+
+var defaultClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// MixWithPeers pulls models of states having stateName from peers, mixes
+// them with the local state having the same name, and pushes the mixed model
+// back to the peers, which mix it with their current models. Each peer is an
+// address like "host:port" or a URL of a server created by Handler. Peers
+// which cannot be reached are skipped and reported as an error after mixing
+// with the other peers. It returns the number of peers mixed.
+func MixWithPeers(ctx *core.Context, stateName string, peers []string) (int, error) {
+	return mixWithPeers(ctx, defaultClient, stateName, peers)
+}
+
+func mixWithPeers(ctx *core.Context, client *http.Client, stateName string, peers []string) (int, error) {
+	if len(peers) == 0 {
+		return 0, errors.New("at least one peer is required")
+	}
+	st, err := lookupRemoteMixer(ctx, stateName)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []string
+	srcs := []core.SharedState{st}
+	var mixed []string
+	for _, p := range peers {
+		src, err := pull(ctx, client, st, p, stateName)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", p, err))
+			continue
+		}
+		srcs = append(srcs, src)
+		mixed = append(mixed, p)
+	}
+
+	if len(mixed) > 0 {
+		if err := st.Mix(srcs); err != nil {
+			return 0, err
+		}
+
+		buf := bytes.NewBuffer(nil)
+		if err := st.Save(ctx, buf, data.Map{}); err != nil {
+			return 0, err
+		}
+		for _, p := range mixed {
+			if err := push(client, p, stateName, buf.Bytes()); err != nil {
+				errs = append(errs, fmt.Sprintf("%v: %v", p, err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return len(mixed), fmt.Errorf("failed to mix with some peers: %v", strings.Join(errs, ", "))
+	}
+	return len(mixed), nil
+}
+
+func pull(ctx *core.Context, client *http.Client, st RemoteMixer, peer, stateName string) (core.SharedState, error) {
+	res, err := client.Get(stateURL(peer, stateName))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError(res)
+	}
+	return st.LoadMixSource(ctx, res.Body)
+}
+
+func push(client *http.Client, peer, stateName string, model []byte) error {
+	req, err := http.NewRequest("PUT", stateURL(peer, stateName), bytes.NewReader(model))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		return responseError(res)
+	}
+	return nil
+}
+
+func stateURL(peer, stateName string) string {
+	if !strings.Contains(peer, "://") {
+		peer = "http://" + peer
+	}
+	return strings.TrimSuffix(peer, "/") + statesPath + url.PathEscape(stateName)
+}
+
+func responseError(res *http.Response) error {
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Errorf("unexpected status %v: %v", res.Status, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,285 @@
+package mix
+
+import (
+	"bufio"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// averageState is a RemoteMixer whose model is a single value. Mixing
+// averages values of sources.
+type averageState struct {
+	value float64
+}
+
+func (s *averageState) Terminate(ctx *core.Context) error {
+	return nil
+}
+
+func (s *averageState) Mix(sources []core.SharedState) error {
+	sum := 0.0
+	for _, src := range sources {
+		a, ok := src.(*averageState)
+		if !ok {
+			return fmt.Errorf("unsupported source: %T", src)
+		}
+		sum += a.value
+	}
+	s.value = sum / float64(len(sources))
+	return nil
+}
+
+func (s *averageState) Save(ctx *core.Context, w io.Writer, params data.Map) error {
+	_, err := fmt.Fprint(w, s.value)
+	return err
+}
+
+func (s *averageState) LoadMixSource(ctx *core.Context, r io.Reader) (core.SharedState, error) {
+	src := &averageState{}
+	if _, err := fmt.Fscan(r, &src.value); err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+func newPeer(t *testing.T, value float64) (*core.Context, *averageState, *httptest.Server) {
+	ctx := core.NewContext(nil)
+	s := &averageState{value: value}
+	if err := ctx.SharedStates.Add("model", "test", s); err != nil {
+		t.Fatal(err)
+	}
+	return ctx, s, httptest.NewServer(Handler(ctx))
+}
+
+func TestMixWithPeers(t *testing.T) {
+	Convey("Given a local state and two peers", t, func() {
+		ctx, local, srv := newPeer(t, 0)
+		srv.Close()
+		_, a, srvA := newPeer(t, 3)
+		defer srvA.Close()
+		_, b, srvB := newPeer(t, 6)
+		defer srvB.Close()
+
+		Convey("when mixing with the peers", func() {
+			n, err := MixWithPeers(ctx, "model", []string{srvA.URL, srvB.URL})
+
+			Convey("it should succeed", func() {
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 2)
+			})
+
+			Convey("the local state should have the mixed model", func() {
+				So(local.value, ShouldEqual, 3)
+			})
+
+			Convey("the peers should mix the pushed model with their models", func() {
+				So(a.value, ShouldEqual, 3)
+				So(b.value, ShouldEqual, 4.5)
+			})
+		})
+
+		Convey("when one of the peers is unreachable", func() {
+			dead := httptest.NewServer(nil)
+			dead.Close()
+			n, err := MixWithPeers(ctx, "model", []string{srvA.URL, dead.URL})
+
+			Convey("it should report the error", func() {
+				So(err, ShouldNotBeNil)
+				So(n, ShouldEqual, 1)
+			})
+
+			Convey("the reachable peer should be mixed", func() {
+				So(local.value, ShouldEqual, 1.5)
+				So(a.value, ShouldEqual, 2.25)
+				So(b.value, ShouldEqual, 6)
+			})
+		})
+
+		Convey("when a peer doesn't have the state", func() {
+			n, err := MixWithPeers(ctx, "nonexistent", []string{srvA.URL})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(n, ShouldEqual, 0)
+			})
+		})
+
+		Convey("when mixing without peers", func() {
+			_, err := MixWithPeers(ctx, "model", nil)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+// setState is a RemoteMixer whose model is a set of strings. Mixing takes
+// the union of sets of sources.
+type setState struct {
+	values map[string]bool
+}
+
+func newSetState() *setState {
+	return &setState{values: map[string]bool{}}
+}
+
+func (s *setState) Terminate(ctx *core.Context) error {
+	return nil
+}
+
+func (s *setState) Mix(sources []core.SharedState) error {
+	values := map[string]bool{}
+	for _, src := range sources {
+		ss, ok := src.(*setState)
+		if !ok {
+			return fmt.Errorf("unsupported source: %T", src)
+		}
+		for v := range ss.values {
+			values[v] = true
+		}
+	}
+	s.values = values
+	return nil
+}
+
+func (s *setState) Save(ctx *core.Context, w io.Writer, params data.Map) error {
+	for v := range s.values {
+		if _, err := fmt.Fprintln(w, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *setState) LoadMixSource(ctx *core.Context, r io.Reader) (core.SharedState, error) {
+	src := newSetState()
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		src.values[sc.Text()] = true
+	}
+	return src, sc.Err()
+}
+
+func TestMixWithTrainingPeers(t *testing.T) {
+	Convey("Given a local state and a peer trained while being mixed", t, func() {
+		ctx := core.NewContext(nil)
+		local := newSetState()
+		So(ctx.SharedStates.Add("model", "test", local), ShouldBeNil)
+
+		peerCtx := core.NewContext(nil)
+		peer := newSetState()
+		So(peerCtx.SharedStates.Add("model", "test", peer), ShouldBeNil)
+		h := Handler(peerCtx)
+		round := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			if r.Method == "GET" {
+				// The peer learns a value after its model is pulled and
+				// before the mixed model is pushed.
+				peer.values[fmt.Sprint("peer", round)] = true
+			}
+		}))
+		defer srv.Close()
+
+		Convey("when mixing them several times while both are trained", func() {
+			for round = 0; round < 3; round++ {
+				local.values[fmt.Sprint("local", round)] = true
+				_, err := MixWithPeers(ctx, "model", []string{srv.URL})
+				So(err, ShouldBeNil)
+			}
+
+			Convey("the peer should keep values learned during mixing", func() {
+				So(peer.values, ShouldResemble, map[string]bool{
+					"local0": true, "local1": true, "local2": true,
+					"peer0": true, "peer1": true, "peer2": true,
+				})
+			})
+
+			Convey("the local state should have values pulled from the peer", func() {
+				So(local.values, ShouldResemble, map[string]bool{
+					"local0": true, "local1": true, "local2": true,
+					"peer0": true, "peer1": true,
+				})
+			})
+		})
+	})
+}
+
+func TestHandler(t *testing.T) {
+	Convey("Given a server having a state not supporting remote mixing", t, func() {
+		ctx := core.NewContext(nil)
+		So(ctx.SharedStates.Add("other", "test", &nonMixerState{}), ShouldBeNil)
+		srv := httptest.NewServer(Handler(ctx))
+		defer srv.Close()
+
+		Convey("when pulling the state", func() {
+			_, err := pull(ctx, defaultClient, &averageState{}, srv.URL, "other")
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestStateURL(t *testing.T) {
+	Convey("Given peer addresses", t, func() {
+		Convey("an address without a scheme should be an http URL", func() {
+			So(stateURL("localhost:8080", "a b"), ShouldEqual, "http://localhost:8080/states/a%20b")
+		})
+
+		Convey("a URL should be used as is", func() {
+			So(stateURL("https://example.com/", "model"), ShouldEqual, "https://example.com/states/model")
+		})
+	})
+}
+
+func TestMixerStateCreator(t *testing.T) {
+	Convey("Given a server state and a mixer state", t, func() {
+		ctx := core.NewContext(nil)
+		srv, err := (&ServerStateCreator{}).CreateState(ctx, data.Map{"listen": data.String("localhost:0")})
+		So(err, ShouldBeNil)
+		defer srv.Terminate(ctx)
+
+		server := &averageState{value: 4}
+		So(ctx.SharedStates.Add("model", "test", server), ShouldBeNil)
+		peerCtx := core.NewContext(nil)
+		peer := &averageState{}
+		So(peerCtx.SharedStates.Add("model", "test", peer), ShouldBeNil)
+
+		Convey("when the mixer runs", func() {
+			m, err := (&MixerStateCreator{}).CreateState(peerCtx, data.Map{
+				"state":    data.String("model"),
+				"peers":    data.Array{data.String(srv.(*serverState).addr())},
+				"interval": data.Float(0.01),
+			})
+			So(err, ShouldBeNil)
+
+			Convey("the states should be mixed periodically", func() {
+				time.Sleep(100 * time.Millisecond)
+				So(m.Terminate(peerCtx), ShouldBeNil)
+				So(peer.value, ShouldBeBetween, 2, 4)
+				So(peer.value, ShouldAlmostEqual, server.value, 0.01)
+			})
+		})
+
+		Convey("when creating a mixer without peers", func() {
+			_, err := (&MixerStateCreator{}).CreateState(ctx, data.Map{
+				"state": data.String("model"),
+				"peers": data.Array{},
+			})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
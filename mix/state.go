@@ -0,0 +1,152 @@
+package mix
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ServerStateCreator is used by BQL to create a state running an HTTP server
+// which serves models of states to peers. See Handler for the protocol.
+type ServerStateCreator struct {
+}
+
+var _ udf.UDSCreator = &ServerStateCreator{}
+
+// CreateState starts a server listening on the address given by listen
+// parameter.
+func (c *ServerStateCreator) CreateState(ctx *core.Context, params data.Map) (core.SharedState, error) {
+	addr, err := pluginutil.ExtractParamAsString(params, "listen")
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %v: %v", addr, err)
+	}
+
+	s := &serverState{
+		listener: l,
+		server:   &http.Server{Handler: Handler(ctx)},
+	}
+	go s.server.Serve(l)
+	return s, nil
+}
+
+type serverState struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+// Terminate stops the server.
+func (s *serverState) Terminate(ctx *core.Context) error {
+	return s.server.Close()
+}
+
+// addr returns the address which the server is listening on.
+func (s *serverState) addr() string {
+	return s.listener.Addr().String()
+}
+
+// MixerStateCreator is used by BQL to create a state which periodically
+// mixes a state with states having the same name in peers.
+type MixerStateCreator struct {
+}
+
+var _ udf.UDSCreator = &MixerStateCreator{}
+
+// CreateState creates a mixer state. It accepts following parameters:
+//
+//	state: the name of the state to be mixed
+//	peers: an array of addresses of servers created by ServerStateCreator
+//	interval: the interval of mixing in seconds, default 10
+func (c *MixerStateCreator) CreateState(ctx *core.Context, params data.Map) (core.SharedState, error) {
+	name, err := pluginutil.ExtractParamAsString(params, "state")
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := params["peers"]
+	if !ok {
+		return nil, errors.New("peers parameter is missing")
+	}
+	a, err := data.AsArray(v)
+	if err != nil {
+		return nil, fmt.Errorf("peers parameter is not an array: %v", err)
+	}
+	if len(a) == 0 {
+		return nil, errors.New("peers parameter must not be empty")
+	}
+	peers := make([]string, len(a))
+	for i, p := range a {
+		s, err := data.AsString(p)
+		if err != nil {
+			return nil, fmt.Errorf("peers parameter has an element which isn't a string: %v", err)
+		}
+		peers[i] = s
+	}
+
+	interval := 10.0
+	if _, ok := params["interval"]; ok {
+		interval, err = pluginutil.ExtractParamAndConvertToFloat(params, "interval")
+		if err != nil {
+			return nil, err
+		}
+		if interval <= 0 {
+			return nil, errors.New("interval parameter must be greater than zero")
+		}
+	}
+
+	m := &peerMixerState{
+		stateName: name,
+		peers:     peers,
+		interval:  time.Duration(interval * float64(time.Second)),
+		client:    defaultClient,
+		stop:      make(chan struct{}),
+	}
+	m.wg.Add(1)
+	go m.run(ctx)
+	return m, nil
+}
+
+type peerMixerState struct {
+	stateName string
+	peers     []string
+	interval  time.Duration
+	client    *http.Client
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func (m *peerMixerState) run(ctx *core.Context) {
+	defer m.wg.Done()
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-t.C:
+		}
+
+		if _, err := mixWithPeers(ctx, m.client, m.stateName, m.peers); err != nil {
+			ctx.ErrLog(err).WithField("state", m.stateName).Error("Cannot mix the state with peers")
+		}
+	}
+}
+
+// Terminate stops mixing.
+func (m *peerMixerState) Terminate(ctx *core.Context) error {
+	close(m.stop)
+	m.wg.Wait()
+	return nil
+}
@@ -0,0 +1,217 @@
+// Package normalizer normalizes scales of features online. Statistics of
+// each feature are updated with trained feature vectors, and features are
+// normalized with the statistics.
+//
+// The following methods are supported:
+//
+//	z_score: (x - mean) / stddev
+//	min_max: (x - min) / (max - min)
+//
+// A feature having zero stddev or zero range is normalized to 0. Features
+// which have never been trained are left as they are. Statistics of a feature
+// are only updated with feature vectors having the feature, so absent features
+// of sparse feature vectors don't affect them.
+package normalizer
+
+import (
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/internal/nested"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"math"
+	"sync"
+)
+
+// Normalizer normalizes feature vectors. It's safe for concurrent use.
+type Normalizer struct {
+	method string
+	stats  map[string]*stat
+	m      sync.RWMutex
+}
+
+// stat has statistics of a feature.
+type stat struct {
+	_struct struct{} `codec:",toarray"`
+	Count   uint64
+	Mean    float64
+
+	// M2 is the sum of squared differences from the mean, which is updated
+	// by Welford's algorithm.
+	M2  float64
+	Min float64
+	Max float64
+}
+
+func (s *stat) add(x float64) {
+	s.Count++
+	if s.Count == 1 {
+		s.Min = x
+		s.Max = x
+	} else {
+		s.Min = math.Min(s.Min, x)
+		s.Max = math.Max(s.Max, x)
+	}
+	d := x - s.Mean
+	s.Mean += d / float64(s.Count)
+	s.M2 += d * (x - s.Mean)
+}
+
+// New creates a normalizer using method.
+func New(method string) (*Normalizer, error) {
+	switch method {
+	case "z_score", "min_max":
+	default:
+		return nil, fmt.Errorf("unknown normalization method: %v", method)
+	}
+	return &Normalizer{
+		method: method,
+		stats:  map[string]*stat{},
+	}, nil
+}
+
+// NewFromParams creates a normalizer from normalizer parameter of a UDS,
+// which is the name of the method. It returns nil when the parameter isn't
+// given.
+func NewFromParams(params data.Map) (*Normalizer, error) {
+	if _, ok := params["normalizer"]; !ok {
+		return nil, nil
+	}
+	method, err := pluginutil.ExtractParamAsString(params, "normalizer")
+	if err != nil {
+		return nil, err
+	}
+	return New(method)
+}
+
+// Normalize normalizes fv. Statistics aren't updated, so it's used for data
+// to be classified, estimated or scored. Nested maps and arrays are flattened
+// in the same way as feature vectors are flattened by algorithms.
+func (n *Normalizer) Normalize(fv data.Map) (data.Map, error) {
+	n.m.RLock()
+	defer n.m.RUnlock()
+	return n.normalize(fv, false)
+}
+
+// NormalizeForTraining normalizes fv after updating statistics with fv.
+func (n *Normalizer) NormalizeForTraining(fv data.Map) (data.Map, error) {
+	n.m.Lock()
+	defer n.m.Unlock()
+	return n.normalize(fv, true)
+}
+
+func (n *Normalizer) normalize(fv data.Map, update bool) (data.Map, error) {
+	flat := make(map[string]float64, len(fv))
+	if err := nested.Flatten(fv, func(k string, x float32) {
+		flat[k] = float64(x)
+	}); err != nil {
+		return nil, err
+	}
+
+	res := make(data.Map, len(flat))
+	for k, x := range flat {
+		s, ok := n.stats[k]
+		if update {
+			if !ok {
+				s = &stat{}
+				n.stats[k] = s
+			}
+			s.add(x)
+		} else if !ok {
+			res[k] = data.Float(x)
+			continue
+		}
+		res[k] = data.Float(n.apply(s, x))
+	}
+	return res, nil
+}
+
+func (n *Normalizer) apply(s *stat, x float64) float64 {
+	switch n.method {
+	case "z_score":
+		sd := math.Sqrt(s.M2 / float64(s.Count))
+		if sd == 0 {
+			return 0
+		}
+		return (x - s.Mean) / sd
+	default:
+		r := s.Max - s.Min
+		if r == 0 {
+			return 0
+		}
+		return (x - s.Min) / r
+	}
+}
+
+const (
+	normalizerFormatVersion uint8 = 1
+)
+
+var (
+	msgpackHandle = &codec.MsgpackHandle{
+		RawToString: true,
+	}
+)
+
+type normalizerMsgpack struct {
+	_struct struct{} `codec:",toarray"`
+
+	// Enabled is false when the normalizer is nil.
+	Enabled bool
+	Method  string
+	Stats   map[string]*stat
+}
+
+// Save saves n. n can be nil, which means that feature vectors aren't
+// normalized.
+func Save(w io.Writer, n *Normalizer) error {
+	if _, err := w.Write([]byte{normalizerFormatVersion}); err != nil {
+		return err
+	}
+
+	d := &normalizerMsgpack{}
+	if n != nil {
+		n.m.RLock()
+		defer n.m.RUnlock()
+		d.Enabled = true
+		d.Method = n.method
+		d.Stats = n.stats
+	}
+	return codec.NewEncoder(w, msgpackHandle).Encode(d)
+}
+
+// Load loads a normalizer saved by Save. It returns nil when nil was saved.
+func Load(r io.Reader) (*Normalizer, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadFormatV1(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of normalizer container: %v", formatVersion[0])
+	}
+}
+
+func loadFormatV1(r io.Reader) (*Normalizer, error) {
+	var d normalizerMsgpack
+	if err := codec.NewDecoder(r, msgpackHandle).Decode(&d); err != nil {
+		return nil, err
+	}
+	if !d.Enabled {
+		return nil, nil
+	}
+	n, err := New(d.Method)
+	if err != nil {
+		return nil, err
+	}
+	for k, s := range d.Stats {
+		if s != nil {
+			n.stats[k] = s
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,113 @@
+package normalizer
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"math"
+	"testing"
+)
+
+func TestNormalizer(t *testing.T) {
+	Convey("Given a z-score normalizer", t, func() {
+		n, err := New("z_score")
+		So(err, ShouldBeNil)
+
+		Convey("when training it with feature vectors", func() {
+			for _, x := range []float64{1, 2, 3, 4} {
+				_, err := n.NormalizeForTraining(data.Map{"x": data.Float(x), "c": data.Float(5)})
+				So(err, ShouldBeNil)
+			}
+
+			Convey("features should be standardized", func() {
+				fv, err := n.Normalize(data.Map{"x": data.Float(4), "c": data.Float(5), "new": data.Float(7)})
+				So(err, ShouldBeNil)
+				x, _ := data.AsFloat(fv["x"])
+				So(x, ShouldAlmostEqual, 1.5/math.Sqrt(1.25))
+				So(fv["c"], ShouldEqual, data.Float(0))
+				So(fv["new"], ShouldEqual, data.Float(7))
+			})
+
+			Convey("normalizing shouldn't update statistics", func() {
+				_, err := n.Normalize(data.Map{"x": data.Float(100)})
+				So(err, ShouldBeNil)
+				So(n.stats["x"].Count, ShouldEqual, 4)
+				So(n.stats["x"].Mean, ShouldAlmostEqual, 2.5)
+			})
+
+			Convey("saving and loading it should keep statistics", func() {
+				buf := bytes.NewBuffer(nil)
+				So(Save(buf, n), ShouldBeNil)
+				n2, err := Load(buf)
+				So(err, ShouldBeNil)
+				So(n2.method, ShouldEqual, n.method)
+				So(n2.stats, ShouldResemble, n.stats)
+			})
+		})
+	})
+
+	Convey("Given a min-max normalizer", t, func() {
+		n, err := New("min_max")
+		So(err, ShouldBeNil)
+
+		Convey("when training it with nested feature vectors", func() {
+			for _, x := range []float64{-10, 30, 10} {
+				_, err := n.NormalizeForTraining(data.Map{"a": data.Map{"b": data.Float(x)}})
+				So(err, ShouldBeNil)
+			}
+
+			Convey("features should be scaled by the range", func() {
+				fv, err := n.Normalize(data.Map{"a": data.Map{"b": data.Float(0)}})
+				So(err, ShouldBeNil)
+				So(fv, ShouldResemble, data.Map{"a\x00b": data.Float(0.25)})
+			})
+		})
+	})
+
+	Convey("Given a feature vector having a non-numeric value", t, func() {
+		n, err := New("min_max")
+		So(err, ShouldBeNil)
+
+		Convey("when normalizing it", func() {
+			_, err := n.NormalizeForTraining(data.Map{"a": data.String("b")})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given a nil normalizer", t, func() {
+		var n *Normalizer
+
+		Convey("when saving and loading it", func() {
+			buf := bytes.NewBuffer(nil)
+			So(Save(buf, n), ShouldBeNil)
+			n2, err := Load(buf)
+
+			Convey("it should be loaded as nil", func() {
+				So(err, ShouldBeNil)
+				So(n2, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestNewFromParams(t *testing.T) {
+	Convey("Given params without normalizer", t, func() {
+		n, err := NewFromParams(data.Map{})
+
+		Convey("it should return nil", func() {
+			So(err, ShouldBeNil)
+			So(n, ShouldBeNil)
+		})
+	})
+
+	Convey("Given params having an unknown method", t, func() {
+		_, err := NewFromParams(data.Map{"normalizer": data.String("unknown")})
+
+		Convey("it should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
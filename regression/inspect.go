@@ -0,0 +1,161 @@
+package regression
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"sort"
+)
+
+// PassiveAggressiveWeights returns the top n positive and negative weights
+// of the model the state having stateName has. The result is a map having
+// the following fields:
+//
+//	positive: an array of {feature, weight} in descending order of weights
+//	negative: an array of {feature, weight} in ascending order of weights
+//	active_dimensions: the number of features having non-zero weights
+//
+// When the state has targets, the name of the target whose model is inspected
+// must be given as target.
+func PassiveAggressiveWeights(ctx *core.Context, stateName string, n int, target ...string) (data.Map, error) {
+	if n <= 0 {
+		return nil, errors.New("the number of weights must be greater than zero")
+	}
+	s, err := lookupPassiveAggressiveState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+	pa, err := s.optionalTargetModel(target)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := pa.Weights()
+	fws := make(featureWeights, 0, len(ws))
+	for f, w := range ws {
+		fws = append(fws, featureWeight{f, w})
+	}
+	sort.Sort(fws)
+
+	pos := data.Array{}
+	for i := 0; i < len(fws) && len(pos) < n && fws[i].weight > 0; i++ {
+		pos = append(pos, fws[i].toMap())
+	}
+	neg := data.Array{}
+	for i := len(fws) - 1; i >= 0 && len(neg) < n && fws[i].weight < 0; i-- {
+		neg = append(neg, fws[i].toMap())
+	}
+
+	return data.Map{
+		"positive":          pos,
+		"negative":          neg,
+		"active_dimensions": data.Int(pa.ActiveDims()),
+	}, nil
+}
+
+// PassiveAggressiveExplain estimates a value with the model the state having
+// stateName has and explains the result. It returns a map having the
+// following fields:
+//
+//	estimate: the estimated value
+//	contributions: an array of {feature, value, weight, contribution} in
+//	               descending order of the absolute value of contributions
+//
+// contribution is value * weight, and the sum of contributions is the
+// estimate. When the state has targets, the name of the target whose value is
+// estimated must be given as target.
+func PassiveAggressiveExplain(ctx *core.Context, stateName string, featureVector data.Map, target ...string) (data.Map, error) {
+	s, err := lookupPassiveAggressiveState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+	pa, err := s.optionalTargetModel(target)
+	if err != nil {
+		return nil, err
+	}
+
+	fv, err := s.featureVector(featureVector, false)
+	if err != nil {
+		return nil, err
+	}
+	est, cs, err := pa.Explain(fv)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(contributionsByMagnitude(cs))
+
+	arr := make(data.Array, len(cs))
+	for i, c := range cs {
+		arr[i] = data.Map{
+			"feature":      data.String(c.Feature),
+			"value":        data.Float(c.Value),
+			"weight":       data.Float(c.Weight),
+			"contribution": data.Float(c.Value * c.Weight),
+		}
+	}
+	return data.Map{
+		"estimate":      data.Float(est),
+		"contributions": arr,
+	}, nil
+}
+
+// optionalTargetModel returns the model of the target given as an optional
+// argument of a UDF.
+func (pa *PassiveAggressiveState) optionalTargetModel(target []string) (*PassiveAggressive, error) {
+	switch len(target) {
+	case 0:
+		return pa.targetModel("")
+	case 1:
+		return pa.targetModel(target[0])
+	default:
+		return nil, fmt.Errorf("at most one target can be given: %v", target)
+	}
+}
+
+type featureWeight struct {
+	feature string
+	weight  float32
+}
+
+func (fw *featureWeight) toMap() data.Map {
+	return data.Map{
+		"feature": data.String(fw.feature),
+		"weight":  data.Float(fw.weight),
+	}
+}
+
+// featureWeights sorts weights in descending order. Features having the same
+// weight are sorted in lexicographical order.
+type featureWeights []featureWeight
+
+func (s featureWeights) Len() int {
+	return len(s)
+}
+
+func (s featureWeights) Less(i, j int) bool {
+	return s[i].weight > s[j].weight || (s[i].weight == s[j].weight && s[i].feature < s[j].feature)
+}
+
+func (s featureWeights) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// contributionsByMagnitude sorts contributions in descending order of their
+// absolute values. Features having the same magnitude are sorted in
+// lexicographical order.
+type contributionsByMagnitude []Contribution
+
+func (s contributionsByMagnitude) Len() int {
+	return len(s)
+}
+
+func (s contributionsByMagnitude) Less(i, j int) bool {
+	ci := abs(s[i].Value * s[i].Weight)
+	cj := abs(s[j].Value * s[j].Weight)
+	return ci > cj || (ci == cj && s[i].Feature < s[j].Feature)
+}
+
+func (s contributionsByMagnitude) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
@@ -0,0 +1,162 @@
+package regression
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestPassiveAggressiveWeights(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := PassiveAggressiveStateCreator{}
+	pas, err := c.CreateState(ctx, data.Map{
+		"regularization_weight": data.Float(1),
+		"sensitivity":           data.Float(0.1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SharedStates.Add("pa", "jubaregression_pa", pas); err != nil {
+		t.Fatal(err)
+	}
+	pa := pas.(*PassiveAggressiveState).pa
+	pa.model = model{"a": 3, "b": 2, "c": 1, "d": 0, "e": -1, "f": -2}
+
+	Convey("Given a PassiveAggressiveState having weights", t, func() {
+		Convey("when getting top 2 weights", func() {
+			ws, err := PassiveAggressiveWeights(ctx, "pa", 2)
+			So(err, ShouldBeNil)
+
+			Convey("it should return the largest positive and negative weights", func() {
+				So(ws, ShouldResemble, data.Map{
+					"positive": data.Array{
+						data.Map{"feature": data.String("a"), "weight": data.Float(3)},
+						data.Map{"feature": data.String("b"), "weight": data.Float(2)},
+					},
+					"negative": data.Array{
+						data.Map{"feature": data.String("f"), "weight": data.Float(-2)},
+						data.Map{"feature": data.String("e"), "weight": data.Float(-1)},
+					},
+					"active_dimensions": data.Int(5),
+				})
+			})
+		})
+
+		Convey("when getting weights with an invalid number", func() {
+			_, err := PassiveAggressiveWeights(ctx, "pa", 0)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when getting weights of a target", func() {
+			_, err := PassiveAggressiveWeights(ctx, "pa", 2, "a")
+
+			Convey("it should fail because the state doesn't have targets", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestPassiveAggressiveExplain(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := PassiveAggressiveStateCreator{}
+	pas, err := c.CreateState(ctx, data.Map{
+		"regularization_weight": data.Float(1),
+		"sensitivity":           data.Float(0.1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SharedStates.Add("pa", "jubaregression_pa", pas); err != nil {
+		t.Fatal(err)
+	}
+	pa := pas.(*PassiveAggressiveState).pa
+	pa.model = model{"a": 1, "b": -3, "c": 0.5}
+
+	Convey("Given a PassiveAggressiveState having weights", t, func() {
+		Convey("when explaining an estimate", func() {
+			e, err := PassiveAggressiveExplain(ctx, "pa", data.Map{
+				"a": data.Float(2),
+				"b": data.Float(1),
+				"d": data.Float(5),
+			})
+			So(err, ShouldBeNil)
+
+			Convey("it should return the estimate and sorted contributions", func() {
+				So(e, ShouldResemble, data.Map{
+					"estimate": data.Float(-1),
+					"contributions": data.Array{
+						data.Map{"feature": data.String("b"), "value": data.Float(1), "weight": data.Float(-3), "contribution": data.Float(-3)},
+						data.Map{"feature": data.String("a"), "value": data.Float(2), "weight": data.Float(1), "contribution": data.Float(2)},
+						data.Map{"feature": data.String("d"), "value": data.Float(5), "weight": data.Float(0), "contribution": data.Float(0)},
+					},
+				})
+			})
+		})
+	})
+}
+
+func TestPassiveAggressiveInspectWithTargets(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := PassiveAggressiveStateCreator{}
+	pas, err := c.CreateState(ctx, data.Map{
+		"regularization_weight": data.Float(1),
+		"sensitivity":           data.Float(0.1),
+		"targets":               data.Array{data.String("a"), data.String("b")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SharedStates.Add("pa", "jubaregression_pa", pas); err != nil {
+		t.Fatal(err)
+	}
+	s := pas.(*PassiveAggressiveState)
+	s.targetModels[0].model = model{"x": 1}
+	s.targetModels[1].model = model{"x": -2}
+
+	Convey("Given a PassiveAggressiveState having targets", t, func() {
+		Convey("when getting weights of a target", func() {
+			ws, err := PassiveAggressiveWeights(ctx, "pa", 1, "b")
+			So(err, ShouldBeNil)
+
+			Convey("it should return weights of the model of the target", func() {
+				So(ws, ShouldResemble, data.Map{
+					"positive": data.Array{},
+					"negative": data.Array{
+						data.Map{"feature": data.String("x"), "weight": data.Float(-2)},
+					},
+					"active_dimensions": data.Int(1),
+				})
+			})
+		})
+
+		Convey("when explaining an estimate of a target", func() {
+			e, err := PassiveAggressiveExplain(ctx, "pa", data.Map{"x": data.Float(3)}, "a")
+			So(err, ShouldBeNil)
+
+			Convey("it should use the model of the target", func() {
+				So(e["estimate"], ShouldEqual, data.Float(3))
+			})
+		})
+
+		Convey("when getting weights without a target", func() {
+			_, err := PassiveAggressiveWeights(ctx, "pa", 1)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when explaining an estimate of an unknown target", func() {
+			_, err := PassiveAggressiveExplain(ctx, "pa", data.Map{"x": data.Float(3)}, "c")
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
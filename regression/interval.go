@@ -0,0 +1,269 @@
+package regression
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"math"
+	"sync"
+)
+
+// intervalEstimator computes prediction intervals of estimates from
+// statistics of residuals, which are differences between values and
+// estimates made just before the model is trained with the values.
+//
+// Statistics are kept for the whole model. When bucketWidth is greater than
+// zero, they're also kept for each bucket of estimates, [k*bucketWidth,
+// (k+1)*bucketWidth), so that intervals can vary with estimates. A bucket
+// having less than minBucketCount residuals isn't used and the statistics of
+// the whole model are used instead.
+type intervalEstimator struct {
+	confidence     float64
+	bucketWidth    float64
+	minBucketCount uint64
+
+	// z is the quantile of the standard normal distribution for confidence.
+	z float64
+
+	all     residualStat
+	buckets map[int64]*residualStat
+
+	m sync.RWMutex
+}
+
+// residualStat has the mean and the variance of residuals.
+type residualStat struct {
+	_struct struct{} `codec:",toarray"`
+	Count   uint64
+	Mean    float64
+
+	// M2 is the sum of squared differences from the mean, which is updated
+	// by Welford's algorithm.
+	M2 float64
+}
+
+func (s *residualStat) add(x float64) {
+	s.Count++
+	d := x - s.Mean
+	s.Mean += d / float64(s.Count)
+	s.M2 += d * (x - s.Mean)
+}
+
+func (s *residualStat) stdDev() float64 {
+	return math.Sqrt(s.M2 / float64(s.Count-1))
+}
+
+func newIntervalEstimator(confidence, bucketWidth float64, minBucketCount uint64) (*intervalEstimator, error) {
+	if confidence <= 0 || confidence >= 1 {
+		return nil, errors.New("confidence must be in (0, 1)")
+	}
+	if bucketWidth < 0 {
+		return nil, errors.New("bucket_width must not be less than zero")
+	}
+	return &intervalEstimator{
+		confidence:     confidence,
+		bucketWidth:    bucketWidth,
+		minBucketCount: minBucketCount,
+		z:              math.Sqrt2 * math.Erfinv(confidence),
+		buckets:        map[int64]*residualStat{},
+	}, nil
+}
+
+// newIntervalEstimatorFromParams creates an intervalEstimator from
+// prediction_interval parameter of a UDS. It returns nil when the parameter
+// isn't given. The parameter is a map having the following optional fields:
+//
+//	confidence: the probability that a value is in the interval (default: 0.95)
+//	bucket_width: the width of buckets of estimates. Statistics aren't kept
+//	              for each bucket when it's 0 (default: 0)
+//	min_bucket_count: the number of residuals required to use statistics of a
+//	                  bucket (default: 10)
+func newIntervalEstimatorFromParams(params data.Map) (*intervalEstimator, error) {
+	v, ok := params["prediction_interval"]
+	if !ok {
+		return nil, nil
+	}
+	m, err := data.AsMap(v)
+	if err != nil {
+		return nil, fmt.Errorf("prediction_interval parameter must be a map: %v", err)
+	}
+
+	confidence := 0.95
+	bucketWidth := 0.0
+	var minBucketCount int64 = 10
+	for k, p := range m {
+		switch k {
+		case "confidence":
+			confidence, err = data.ToFloat(p)
+		case "bucket_width":
+			bucketWidth, err = data.ToFloat(p)
+		case "min_bucket_count":
+			minBucketCount, err = data.ToInt(p)
+			if err == nil && minBucketCount < 2 {
+				err = errors.New("must be greater than one")
+			}
+		default:
+			return nil, fmt.Errorf("unknown field of prediction_interval: %v", k)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("prediction_interval.%v is invalid: %v", k, err)
+		}
+	}
+	return newIntervalEstimator(confidence, bucketWidth, uint64(minBucketCount))
+}
+
+func (e *intervalEstimator) bucket(estimate float64) int64 {
+	return int64(math.Floor(estimate / e.bucketWidth))
+}
+
+// add updates statistics with a value and the estimate of it.
+func (e *intervalEstimator) add(estimate, value float32) {
+	r := float64(value) - float64(estimate)
+
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.all.add(r)
+	if e.bucketWidth > 0 {
+		b := e.bucket(float64(estimate))
+		s, ok := e.buckets[b]
+		if !ok {
+			s = &residualStat{}
+			e.buckets[b] = s
+		}
+		s.add(r)
+	}
+}
+
+// interval returns the prediction interval of an estimate. The interval is
+// centered on the estimate corrected by the mean of residuals. ok is false
+// when there're too few residuals to compute the interval.
+func (e *intervalEstimator) interval(estimate float32) (lower, upper float64, ok bool) {
+	e.m.RLock()
+	defer e.m.RUnlock()
+
+	s := &e.all
+	if e.bucketWidth > 0 {
+		if b, ok := e.buckets[e.bucket(float64(estimate))]; ok && b.Count >= e.minBucketCount {
+			s = b
+		}
+	}
+	if s.Count < 2 {
+		return 0, 0, false
+	}
+	c := float64(estimate) + s.Mean
+	d := e.z * s.stdDev()
+	return c - d, c + d, true
+}
+
+const (
+	intervalEstimatorFormatVersion uint8 = 1
+)
+
+type intervalEstimatorMsgpack struct {
+	_struct struct{} `codec:",toarray"`
+
+	// Enabled is false when the intervalEstimator is nil.
+	Enabled        bool
+	Confidence     float64
+	BucketWidth    float64
+	MinBucketCount uint64
+	All            residualStat
+	Buckets        map[int64]*residualStat
+}
+
+// saveIntervalEstimator saves e. e can be nil, which means that prediction
+// intervals aren't computed.
+func saveIntervalEstimator(w io.Writer, e *intervalEstimator) error {
+	if _, err := w.Write([]byte{intervalEstimatorFormatVersion}); err != nil {
+		return err
+	}
+
+	d := &intervalEstimatorMsgpack{}
+	if e != nil {
+		e.m.RLock()
+		defer e.m.RUnlock()
+		d.Enabled = true
+		d.Confidence = e.confidence
+		d.BucketWidth = e.bucketWidth
+		d.MinBucketCount = e.minBucketCount
+		d.All = e.all
+		d.Buckets = e.buckets
+	}
+	return codec.NewEncoder(w, regressionMsgpackHandle).Encode(d)
+}
+
+func loadIntervalEstimator(r io.Reader) (*intervalEstimator, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadIntervalEstimatorFormatV1(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of intervalEstimator container: %v", formatVersion[0])
+	}
+}
+
+func loadIntervalEstimatorFormatV1(r io.Reader) (*intervalEstimator, error) {
+	var d intervalEstimatorMsgpack
+	if err := codec.NewDecoder(r, regressionMsgpackHandle).Decode(&d); err != nil {
+		return nil, err
+	}
+	if !d.Enabled {
+		return nil, nil
+	}
+	e, err := newIntervalEstimator(d.Confidence, d.BucketWidth, d.MinBucketCount)
+	if err != nil {
+		return nil, err
+	}
+	e.all = d.All
+	for b, s := range d.Buckets {
+		if s != nil {
+			e.buckets[b] = s
+		}
+	}
+	return e, nil
+}
+
+// EstimateWithInterval estimates a value with the model of the state having
+// stateName and returns the prediction interval of it. The state must be
+// created with prediction_interval parameter. It returns a map having the
+// following fields:
+//
+//	estimate: the estimated value
+//	lower: the lower bound of the interval
+//	upper: the upper bound of the interval
+//
+// lower and upper are null until the state is trained with at least two
+// tuples.
+func EstimateWithInterval(ctx *core.Context, stateName string, featureVector data.Map) (data.Map, error) {
+	s, err := lookupEstimator(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+	b := s.base()
+	if b.interval == nil {
+		return nil, fmt.Errorf("state '%v' doesn't have prediction_interval parameter", stateName)
+	}
+
+	est, err := b.estimate(s.regressors()[0], featureVector)
+	if err != nil {
+		return nil, err
+	}
+	ret := data.Map{
+		"estimate": data.Float(est),
+		"lower":    data.Null{},
+		"upper":    data.Null{},
+	}
+	if l, u, ok := b.interval.interval(est); ok {
+		ret["lower"] = data.Float(l)
+		ret["upper"] = data.Float(u)
+	}
+	return ret, nil
+}
@@ -0,0 +1,174 @@
+package regression
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"math"
+	"testing"
+)
+
+func TestIntervalEstimator(t *testing.T) {
+	Convey("Given an interval estimator", t, func() {
+		e, err := newIntervalEstimator(0.95, 0, 10)
+		So(err, ShouldBeNil)
+
+		Convey("when it has too few residuals", func() {
+			e.add(0, 1)
+
+			Convey("it shouldn't return an interval", func() {
+				_, _, ok := e.interval(0)
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("when adding residuals", func() {
+			for _, r := range []float32{-1, 1, -1, 1, 5} {
+				e.add(10, 10+r)
+			}
+
+			Convey("the interval should be centered on the corrected estimate", func() {
+				l, u, ok := e.interval(20)
+				So(ok, ShouldBeTrue)
+				sd := math.Sqrt((4 + 0 + 4 + 0 + 16) / 4.0)
+				So(l, ShouldAlmostEqual, 21-1.959964*sd, 1e-4)
+				So(u, ShouldAlmostEqual, 21+1.959964*sd, 1e-4)
+			})
+		})
+	})
+
+	Convey("Given an interval estimator having buckets", t, func() {
+		e, err := newIntervalEstimator(0.95, 10, 3)
+		So(err, ShouldBeNil)
+		for i := 0; i < 5; i++ {
+			e.add(1, 1)
+			e.add(1, 1+float32(i%2))
+			e.add(15, 15+float32(10*(i%2)))
+		}
+
+		Convey("intervals should depend on buckets of estimates", func() {
+			l1, u1, ok := e.interval(5)
+			So(ok, ShouldBeTrue)
+			l2, u2, ok := e.interval(12)
+			So(ok, ShouldBeTrue)
+			So(u1-l1, ShouldBeLessThan, u2-l2)
+		})
+
+		Convey("a bucket having few residuals should use statistics of the whole model", func() {
+			l, u, ok := e.interval(-5)
+			So(ok, ShouldBeTrue)
+			c := -5 + e.all.Mean
+			So((l+u)/2, ShouldAlmostEqual, c, 1e-9)
+		})
+
+		Convey("saving and loading it should keep statistics", func() {
+			buf := bytes.NewBuffer(nil)
+			So(saveIntervalEstimator(buf, e), ShouldBeNil)
+			e2, err := loadIntervalEstimator(buf)
+			So(err, ShouldBeNil)
+			So(e2.confidence, ShouldEqual, e.confidence)
+			So(e2.bucketWidth, ShouldEqual, e.bucketWidth)
+			So(e2.minBucketCount, ShouldEqual, e.minBucketCount)
+			So(e2.all, ShouldResemble, e.all)
+			So(e2.buckets, ShouldResemble, e.buckets)
+		})
+	})
+
+	Convey("Given invalid prediction_interval parameters", t, func() {
+		cases := map[string]data.Value{
+			"not a map":           data.String("a"),
+			"zero confidence":     data.Map{"confidence": data.Float(0)},
+			"one confidence":      data.Map{"confidence": data.Float(1)},
+			"negative width":      data.Map{"bucket_width": data.Float(-1)},
+			"small bucket count":  data.Map{"min_bucket_count": data.Int(1)},
+			"unknown field":       data.Map{"unknown": data.Int(1)},
+			"non-numeric setting": data.Map{"confidence": data.String("high")},
+		}
+
+		for name, p := range cases {
+			p := p
+			Convey("creating an interval estimator with "+name+" should fail", func() {
+				_, err := newIntervalEstimatorFromParams(data.Map{"prediction_interval": p})
+				So(err, ShouldNotBeNil)
+			})
+		}
+	})
+}
+
+func TestEstimateWithInterval(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := PassiveAggressiveStateCreator{}
+	s, err := c.CreateState(ctx, data.Map{
+		"regularization_weight": data.Float(1),
+		"sensitivity":           data.Float(0),
+		"prediction_interval":   data.Map{"confidence": data.Float(0.9)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SharedStates.Add("pa", "jubaregression_pa", s); err != nil {
+		t.Fatal(err)
+	}
+	pa := s.(*PassiveAggressiveState)
+
+	Convey("Given a PassiveAggressiveState having prediction_interval", t, func() {
+		Convey("when it isn't trained", func() {
+			res, err := EstimateWithInterval(ctx, "pa", data.Map{"x": data.Float(1)})
+
+			Convey("it should return null bounds", func() {
+				So(err, ShouldBeNil)
+				So(res["lower"].Type(), ShouldEqual, data.TypeNull)
+				So(res["upper"].Type(), ShouldEqual, data.TypeNull)
+			})
+		})
+
+		Convey("when it's trained", func() {
+			for i := 0; i < 100; i++ {
+				So(pa.Write(ctx, &core.Tuple{
+					Data: data.Map{
+						"value":          data.Float(i%3 - 1),
+						"feature_vector": data.Map{"x": data.Float(1)},
+					},
+				}), ShouldBeNil)
+			}
+
+			Convey("the interval should contain the estimate", func() {
+				res, err := EstimateWithInterval(ctx, "pa", data.Map{"x": data.Float(1)})
+				So(err, ShouldBeNil)
+				est, _ := data.AsFloat(res["estimate"])
+				l, err := data.AsFloat(res["lower"])
+				So(err, ShouldBeNil)
+				u, err := data.AsFloat(res["upper"])
+				So(err, ShouldBeNil)
+				So(l, ShouldBeLessThan, est)
+				So(u, ShouldBeGreaterThan, est)
+			})
+
+			Convey("saving and loading it should keep the interval estimator", func() {
+				buf := bytes.NewBuffer(nil)
+				So(pa.Save(ctx, buf, data.Map{}), ShouldBeNil)
+				s2, err := c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				So(s2.(*PassiveAggressiveState).interval.all, ShouldResemble, pa.interval.all)
+			})
+		})
+	})
+
+	Convey("Given a state without prediction_interval", t, func() {
+		s, err := c.CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(1),
+			"sensitivity":           data.Float(0),
+		})
+		So(err, ShouldBeNil)
+		So(ctx.SharedStates.Add("pa2", "jubaregression_pa", s), ShouldBeNil)
+		Reset(func() {
+			ctx.SharedStates.Remove("pa2")
+		})
+
+		Convey("estimating an interval should fail", func() {
+			_, err := EstimateWithInterval(ctx, "pa2", data.Map{"x": data.Float(1)})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,74 @@
+package regression
+
+import (
+	"fmt"
+	"github.com/zeromberto/jubatus/internal/jubatus"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+)
+
+// LoadJubatusPassiveAggressive loads a PassiveAggressive model from a model
+// file saved by jubaregression of the original Jubatus using PA method.
+// Weights of features are imported as they are. Because Jubatus doesn't save
+// statistics of trained values, they start from zero.
+func LoadJubatusPassiveAggressive(r io.Reader) (*PassiveAggressive, error) {
+	f, err := jubatus.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	return loadJubatusPassiveAggressive(f)
+}
+
+func loadJubatusPassiveAggressive(f *jubatus.File) (*PassiveAggressive, error) {
+	method, params, err := f.Method()
+	if err != nil {
+		return nil, err
+	}
+	if method != "PA" {
+		return nil, fmt.Errorf("the model isn't PA but %v", method)
+	}
+	rw, ok := params["regularization_weight"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("regularization_weight of the model is invalid: %v", params["regularization_weight"])
+	}
+	sen, ok := params["sensitivity"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("sensitivity of the model is invalid: %v", params["sensitivity"])
+	}
+	pa, err := NewPassiveAggressive(float32(rw), float32(sen))
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := f.LinearRegression()
+	if err != nil {
+		return nil, err
+	}
+	for feature, w := range ws {
+		pa.model[dim(feature)] = float32(w)
+	}
+	return pa, nil
+}
+
+// createPassiveAggressiveStateFromJubatus creates a state having a
+// PassiveAggressive model imported from the file given by jubatus_model
+// parameter.
+func createPassiveAggressiveStateFromJubatus(params data.Map, base stateBase) (*PassiveAggressiveState, error) {
+	path, err := pluginutil.ExtractParamAsString(params, "jubatus_model")
+	if err != nil {
+		return nil, err
+	}
+	f, err := jubatus.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the Jubatus model: %v", err)
+	}
+	pa, err := loadJubatusPassiveAggressive(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot import the Jubatus model: %v", err)
+	}
+	return &PassiveAggressiveState{
+		stateBase: base,
+		pa:        pa,
+	}, nil
+}
@@ -0,0 +1,65 @@
+package regression
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/zeromberto/jubatus/internal/jubatus"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestLoadJubatusPassiveAggressive(t *testing.T) {
+	Convey("Given a PA model file of Jubatus", t, func() {
+		s := jubatus.PackStorage(&jubatus.Storage{
+			Weights: map[string]map[string]jubatus.Val3{
+				"x@num": {"+": {V1: 2}},
+				"y@num": {"+": {V1: -1}},
+			},
+			Labels: []string{"+"},
+		})
+		buf := bytes.NewBuffer(nil)
+		So(jubatus.Write(buf, &jubatus.File{
+			Type:   "regression",
+			Config: `{"method":"PA","parameter":{"sensitivity":0.1,"regularization_weight":3.0}}`,
+			Data:   []interface{}{s, []interface{}{}},
+		}), ShouldBeNil)
+		b := buf.Bytes()
+
+		Convey("when loading it", func() {
+			pa, err := LoadJubatusPassiveAggressive(bytes.NewReader(b))
+			So(err, ShouldBeNil)
+
+			Convey("it should have the parameters", func() {
+				So(pa.RegWeight(), ShouldEqual, 3)
+				So(pa.Sensitivity(), ShouldAlmostEqual, 0.1, 1e-6)
+			})
+
+			Convey("it should have the weights", func() {
+				So(pa.Weights(), ShouldResemble, map[string]float32{"x@num": 2, "y@num": -1})
+			})
+
+			Convey("it should estimate values", func() {
+				v, err := pa.Estimate(FeatureVector{"x@num": data.Float(1), "y@num": data.Float(1)})
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given a classifier model file of Jubatus", t, func() {
+		buf := bytes.NewBuffer(nil)
+		So(jubatus.Write(buf, &jubatus.File{
+			Type:   "classifier",
+			Config: `{"method":"PA","parameter":{"sensitivity":0.1,"regularization_weight":3.0}}`,
+			Data:   []interface{}{},
+		}), ShouldBeNil)
+
+		Convey("when loading it as a regression model", func() {
+			_, err := LoadJubatusPassiveAggressive(buf)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,94 @@
+package regression
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestPassiveAggressiveMix(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := PassiveAggressiveStateCreator{}
+	newPAState := func() *PassiveAggressiveState {
+		s, err := c.CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(1),
+			"sensitivity":           data.Float(0.1),
+		})
+		So(err, ShouldBeNil)
+		return s.(*PassiveAggressiveState)
+	}
+
+	Convey("Given PassiveAggressiveStates having weights", t, func() {
+		target := newPAState()
+		a := newPAState()
+		b := newPAState()
+		a.pa.model = model{"x": 2, "y": 1}
+		a.pa.stats.Sum, a.pa.stats.SqSum, a.pa.stats.Weight = 10, 30, 4
+		b.pa.model = model{"x": 4}
+		b.pa.stats.Sum, b.pa.stats.SqSum, b.pa.stats.Weight = 20, 50, 6
+
+		Convey("when mixing them", func() {
+			So(target.Mix([]core.SharedState{a, b}), ShouldBeNil)
+
+			Convey("weights should be averaged", func() {
+				So(target.pa.model, ShouldResemble, model{"x": 3, "y": 0.5})
+			})
+
+			Convey("statistics should be averaged", func() {
+				So(target.pa.stats.Sum, ShouldEqual, 15)
+				So(target.pa.stats.SqSum, ShouldEqual, 40)
+				So(target.pa.stats.Weight, ShouldEqual, 5)
+			})
+		})
+	})
+}
+
+func TestPassiveAggressiveMixWithTargets(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := PassiveAggressiveStateCreator{}
+	newPAState := func(targets ...string) *PassiveAggressiveState {
+		ts := data.Array{}
+		for _, t := range targets {
+			ts = append(ts, data.String(t))
+		}
+		s, err := c.CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(1),
+			"sensitivity":           data.Float(0.1),
+			"targets":               ts,
+		})
+		So(err, ShouldBeNil)
+		return s.(*PassiveAggressiveState)
+	}
+
+	Convey("Given PassiveAggressiveStates having targets", t, func() {
+		target := newPAState("a", "b")
+		a := newPAState("a", "b")
+		b := newPAState("a", "b")
+		a.targetModels[0].model = model{"x": 2}
+		a.targetModels[1].model = model{"x": 4}
+		b.targetModels[0].model = model{"x": 4}
+
+		Convey("when mixing them", func() {
+			So(target.Mix([]core.SharedState{a, b}), ShouldBeNil)
+
+			Convey("models should be mixed for each target", func() {
+				So(target.targetModels[0].model, ShouldResemble, model{"x": 3})
+				So(target.targetModels[1].model, ShouldResemble, model{"x": 2})
+			})
+		})
+
+		Convey("mixing a state having different targets should fail", func() {
+			So(target.Mix([]core.SharedState{a, newPAState("a")}), ShouldNotBeNil)
+		})
+
+		Convey("inspecting weights should fail", func() {
+			So(ctx.SharedStates.Add("multi", "jubaregression_pa", target), ShouldBeNil)
+			Reset(func() {
+				ctx.SharedStates.Remove("multi")
+			})
+			_, err := PassiveAggressiveWeights(ctx, "multi", 10)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,233 @@
+package regression
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/internal/nearest"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// NearestNeighbor holds a model for regression based on nearest neighbor
+// search. It stores rows having values and estimates a value of a feature
+// vector by the weighted average of values of its nearest rows.
+type NearestNeighbor struct {
+	nn     nearest.Neighbor
+	values []float32
+	nnNum  int
+
+	// localSensitivity is a coefficient of distances used to weight values.
+	localSensitivity float32
+
+	// for random unlearner
+	maxSize int
+	seed    int64
+	rg      *rand.Rand
+
+	m sync.RWMutex
+}
+
+// NNAlgorithm is an enum type which represents nearest neighbor algorithms.
+type NNAlgorithm = nearest.Algorithm
+
+const (
+	// InvalidNNAlgorithm represents an invalid nearest neighbor algorithm.
+	InvalidNNAlgorithm = nearest.InvalidAlgorithm
+	// LSH represents locality sensitive hashing.
+	LSH = nearest.LSHAlgorithm
+	// Minhash represents minhash.
+	Minhash = nearest.MinhashAlgorithm
+	// EuclidLSH represents locality sensitive hashing with euclidean distance.
+	EuclidLSH = nearest.EuclidLSHAlgorithm
+)
+
+// NewNearestNeighbor creates a NearestNeighbor model. nnNum is the number of
+// nearest rows whose values are averaged. localSensitivity controls how much
+// distances affect weights of values. When it's zero, all nnNum rows have the
+// same weight. maxSize is the max number of rows. When the model has maxSize
+// rows, a row is randomly chosen and replaced with a new one. maxSize == 0
+// means the model never unlearns rows.
+func NewNearestNeighbor(nnAlgo NNAlgorithm, hashNum, nnNum int, localSensitivity float32, maxSize int, seed int64) (*NearestNeighbor, error) {
+	const maxSizeLimit = 0x7fffffff
+
+	if hashNum <= 0 {
+		return nil, errors.New("number of hash bits must be greater than zero")
+	}
+	if nnNum <= 0 {
+		return nil, errors.New("number of nearest neighbor must be greater than zero")
+	}
+	if localSensitivity < 0 {
+		return nil, errors.New("local sensitivity must not be less than zero")
+	}
+	if maxSize < 0 {
+		return nil, errors.New("max size must be greater than or equal to zero")
+	}
+	if maxSize > maxSizeLimit {
+		return nil, fmt.Errorf("max size must be less than or equal to %v", maxSizeLimit)
+	}
+
+	nn, err := nearest.New(nnAlgo, hashNum)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxSize == 0 {
+		maxSize = maxSizeLimit
+	}
+
+	return &NearestNeighbor{
+		nn:               nn,
+		nnNum:            nnNum,
+		localSensitivity: localSensitivity,
+		maxSize:          maxSize,
+		seed:             seed,
+		rg:               rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// Train adds a feature vector with a value to a model.
+func (n *NearestNeighbor) Train(v FeatureVector, value float32) error {
+	nnfv, err := nearest.FeatureVectorFromMap(data.Map(v))
+	if err != nil {
+		return err
+	}
+
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	var id nearest.ID
+	if len(n.values) < n.maxSize {
+		n.values = append(n.values, value)
+		id = nearest.ID(len(n.values))
+	} else {
+		// unlearn
+		id = nearest.ID(n.rg.Intn(n.maxSize)) + 1
+		n.values[id-1] = value
+	}
+	n.nn.SetRow(id, nnfv)
+	return nil
+}
+
+// Estimate estimates a value of a feature vector. It returns 0 when the model
+// has no rows.
+func (n *NearestNeighbor) Estimate(v FeatureVector) (float32, error) {
+	nnfv, err := nearest.FeatureVectorFromMap(data.Map(v))
+	if err != nil {
+		return 0, err
+	}
+
+	n.m.RLock()
+	defer n.m.RUnlock()
+
+	var sum, weightSum float64
+	for _, d := range n.nn.NeighborRowFromFV(nnfv, n.nnNum) {
+		w := math.Exp(float64(-n.localSensitivity * d.Dist))
+		sum += w * float64(n.values[d.ID-1])
+		weightSum += w
+	}
+	if weightSum == 0 {
+		return 0, nil
+	}
+	return float32(sum / weightSum), nil
+}
+
+// Clear clears a model.
+func (n *NearestNeighbor) Clear() {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	n.nn.Clear()
+	n.values = nil
+}
+
+const (
+	nearestNeighborFormatVersion uint8 = 2
+)
+
+type nearestNeighborMsgpack struct {
+	_struct struct{} `codec:",toarray"`
+
+	Values           []float32
+	NNNum            int
+	LocalSensitivity float32
+
+	MaxSize int
+	Seed    int64
+}
+
+// Save saves the current state of NearestNeighbor.
+func (n *NearestNeighbor) Save(w io.Writer) error {
+	n.m.RLock()
+	defer n.m.RUnlock()
+
+	if _, err := w.Write([]byte{nearestNeighborFormatVersion}); err != nil {
+		return err
+	}
+
+	enc := codec.NewEncoder(w, regressionMsgpackHandle)
+	if err := enc.Encode(&nearestNeighborMsgpack{
+		Values:           n.values,
+		NNNum:            n.nnNum,
+		LocalSensitivity: n.localSensitivity,
+
+		MaxSize: n.maxSize,
+		Seed:    n.seed,
+	}); err != nil {
+		return err
+	}
+	return nearest.Save(n.nn, w)
+}
+
+// LoadNearestNeighbor loads NearestNeighbor from the saved data.
+func LoadNearestNeighbor(r io.Reader) (*NearestNeighbor, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadNearestNeighborFormatV1(r)
+	case 2:
+		return loadNearestNeighborFormatV2(r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of NearestNeighbor container: %v", formatVersion[0])
+	}
+}
+
+func loadNearestNeighborFormatV1(r io.Reader) (*NearestNeighbor, error) {
+	// The format version 1 doesn't have the seed of the unlearner. The seed
+	// is zero in that case.
+	return loadNearestNeighbor(r)
+}
+
+func loadNearestNeighborFormatV2(r io.Reader) (*NearestNeighbor, error) {
+	return loadNearestNeighbor(r)
+}
+
+func loadNearestNeighbor(r io.Reader) (*NearestNeighbor, error) {
+	m := nearestNeighborMsgpack{}
+	dec := codec.NewDecoder(r, regressionMsgpackHandle)
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	nn, err := nearest.Load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NearestNeighbor{
+		nn:               nn,
+		values:           m.Values,
+		nnNum:            m.NNNum,
+		localSensitivity: m.LocalSensitivity,
+
+		maxSize: m.MaxSize,
+		seed:    m.Seed,
+		rg:      rand.New(rand.NewSource(m.Seed)),
+	}, nil
+}
@@ -0,0 +1,232 @@
+package regression
+
+import (
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/fvconv"
+	"github.com/zeromberto/jubatus/internal/nearest"
+	"github.com/zeromberto/jubatus/normalizer"
+	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+)
+
+// NearestNeighborState is a state having NearestNeighbor.
+type NearestNeighborState struct {
+	stateBase
+	nn *NearestNeighbor
+
+	// targetModels has a model for each target when the state has targets.
+	// nn is nil in that case.
+	targetModels []*NearestNeighbor
+}
+
+var (
+	_ core.SavableSharedState = &NearestNeighborState{}
+)
+
+// NearestNeighborStateCreator is used by BQL to create or load
+// NearestNeighborState as a UDS.
+type NearestNeighborStateCreator struct {
+}
+
+var _ udf.UDSLoader = &NearestNeighborStateCreator{}
+
+// CreateState creates a new state for NearestNeighbor regression.
+func (c *NearestNeighborStateCreator) CreateState(ctx *core.Context, params data.Map) (core.SharedState, error) {
+	base, err := newStateBase(params)
+	if err != nil {
+		return nil, err
+	}
+
+	nnParams, err := nearest.ExtractParams(params)
+	if err != nil {
+		return nil, err
+	}
+	localSensitivity, err := nearest.ExtractLocalSensitivity(params)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &NearestNeighborState{
+		stateBase: base,
+	}
+	for i := 0; i < base.numModels(); i++ {
+		nn, err := NewNearestNeighbor(nnParams.Algorithm, nnParams.HashNum, nnParams.NNNum, localSensitivity, nnParams.MaxSize, nnParams.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize NearestNeighbor: %v", err)
+		}
+		if base.targets == nil {
+			s.nn = nn
+		} else {
+			s.targetModels = append(s.targetModels, nn)
+		}
+	}
+	return s, nil
+}
+
+const (
+	nearestNeighborStateFormatVersion uint8 = 3
+	nearestNeighborAlgorithm                = "nearest_neighbor"
+)
+
+// LoadState loads a new state for NearestNeighbor regression.
+func (c *NearestNeighborStateCreator) LoadState(ctx *core.Context, r io.Reader, params data.Map) (core.SharedState, error) {
+	formatVersion := make([]byte, 1)
+	if _, err := r.Read(formatVersion); err != nil {
+		return nil, err
+	}
+
+	switch formatVersion[0] {
+	case 1:
+		return loadNearestNeighborStateFormatV1(ctx, r)
+	case 2:
+		return loadNearestNeighborStateFormatV2(ctx, r)
+	case 3:
+		return loadNearestNeighborStateFormatV3(ctx, r)
+	default:
+		return nil, fmt.Errorf("unsupported format version of NearestNeighborState container: %v", formatVersion[0])
+	}
+}
+
+func loadNearestNeighborStateFormatV1(ctx *core.Context, r io.Reader) (core.SharedState, error) {
+	// The format version 1 doesn't have an interval estimator and targets.
+	return loadNearestNeighborState(r, 1)
+}
+
+func loadNearestNeighborStateFormatV2(ctx *core.Context, r io.Reader) (core.SharedState, error) {
+	// The format version 2 doesn't have targets.
+	return loadNearestNeighborState(r, 2)
+}
+
+func loadNearestNeighborStateFormatV3(ctx *core.Context, r io.Reader) (core.SharedState, error) {
+	return loadNearestNeighborState(r, 3)
+}
+
+func loadNearestNeighborState(r io.Reader, formatVersion uint8) (core.SharedState, error) {
+	var header regressionMsgpack
+	dec := codec.NewDecoder(r, regressionMsgpackHandle)
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Algorithm != nearestNeighborAlgorithm {
+		return nil, fmt.Errorf("unsupported regression algorithm: %v (%v is expected)", header.Algorithm, nearestNeighborAlgorithm)
+	}
+
+	var d stateMsgpack
+	if err := dec.Decode(&d); err != nil {
+		return nil, err
+	}
+	s := &NearestNeighborState{
+		stateBase: newStateBaseFromMsgpack(&d),
+	}
+
+	conv, err := fvconv.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.converter = conv
+
+	norm, err := normalizer.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.normalizer = norm
+
+	if formatVersion >= 2 {
+		interval, err := loadIntervalEstimator(r)
+		if err != nil {
+			return nil, err
+		}
+		s.interval = interval
+	}
+
+	if formatVersion >= 3 {
+		var t targetsMsgpack
+		if err := codec.NewDecoder(r, regressionMsgpackHandle).Decode(&t); err != nil {
+			return nil, err
+		}
+		if len(t.Targets) > 0 {
+			s.targets = t.Targets
+		}
+	}
+
+	for i := 0; i < s.numModels(); i++ {
+		nn, err := LoadNearestNeighbor(r)
+		if err != nil {
+			return nil, err
+		}
+		if s.targets == nil {
+			s.nn = nn
+		} else {
+			s.targetModels = append(s.targetModels, nn)
+		}
+	}
+	return s, nil
+}
+
+// Terminate terminates the state.
+func (*NearestNeighborState) Terminate(ctx *core.Context) error {
+	return nil
+}
+
+// Write trains the model with a tuple.
+func (s *NearestNeighborState) Write(ctx *core.Context, t *core.Tuple) error {
+	return s.train(s.regressors(), t)
+}
+
+// Save is provided as a part of core.SavableSharedState.
+func (s *NearestNeighborState) Save(ctx *core.Context, w io.Writer, params data.Map) error {
+	if _, err := w.Write([]byte{nearestNeighborStateFormatVersion}); err != nil {
+		return err
+	}
+
+	enc := codec.NewEncoder(w, regressionMsgpackHandle)
+	if err := enc.Encode(&regressionMsgpack{
+		Algorithm: nearestNeighborAlgorithm,
+	}); err != nil {
+		return err
+	}
+
+	if err := enc.Encode(s.toMsgpack()); err != nil {
+		return err
+	}
+	if err := fvconv.Save(w, s.converter); err != nil {
+		return err
+	}
+	if err := normalizer.Save(w, s.normalizer); err != nil {
+		return err
+	}
+	if err := saveIntervalEstimator(w, s.interval); err != nil {
+		return err
+	}
+	if err := codec.NewEncoder(w, regressionMsgpackHandle).Encode(&targetsMsgpack{
+		Targets: s.targets,
+	}); err != nil {
+		return err
+	}
+	for _, m := range s.models() {
+		if err := m.Save(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// models returns all models the state has.
+func (s *NearestNeighborState) models() []*NearestNeighbor {
+	if s.nn != nil {
+		return []*NearestNeighbor{s.nn}
+	}
+	return s.targetModels
+}
+
+func (s *NearestNeighborState) regressors() []regressor {
+	ms := s.models()
+	rs := make([]regressor, len(ms))
+	for i, m := range ms {
+		rs[i] = m
+	}
+	return rs
+}
@@ -0,0 +1,98 @@
+package regression
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestNearestNeighborState(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := NearestNeighborStateCreator{}
+
+	Convey("Given a NearestNeighborState", t, func() {
+		s, err := c.CreateState(ctx, data.Map{
+			"nearest_neighbor_algorithm": data.String("euclid_lsh"),
+			"hash_num":                   data.Int(64),
+			"nearest_neighbor_num":       data.Int(3),
+			"unlearner":                  data.String("random"),
+			"max_size":                   data.Int(100),
+		})
+		So(err, ShouldBeNil)
+		So(ctx.SharedStates.Add("nn", "jubaregression_nn", s), ShouldBeNil)
+		Reset(func() {
+			ctx.SharedStates.Remove("nn")
+		})
+		nn := s.(*NearestNeighborState)
+
+		for i := 0; i < 10; i++ {
+			So(nn.Write(ctx, &core.Tuple{
+				Data: data.Map{
+					"value": data.Float(i % 2),
+					"feature_vector": data.Map{
+						"x": data.Float(i % 2),
+						"y": data.Float(1 - i%2),
+					},
+				},
+			}), ShouldBeNil)
+		}
+
+		Convey("when estimating a value with jubaregression_estimate", func() {
+			v, err := PassiveAggressiveEstimate(ctx, "nn", data.Map{"x": data.Float(1), "y": data.Float(0)})
+
+			Convey("it should return the value of nearest rows", func() {
+				So(err, ShouldBeNil)
+				So(v, ShouldAlmostEqual, 1, 1e-4)
+			})
+		})
+
+		Convey("when saving it", func() {
+			buf := bytes.NewBuffer(nil)
+			So(nn.Save(ctx, buf, data.Map{}), ShouldBeNil)
+
+			Convey("the loaded state should estimate the same value", func() {
+				s2, err := c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				nn2 := s2.(*NearestNeighborState)
+				So(nn2.valueField, ShouldEqual, nn.valueField)
+
+				fv := data.Map{"x": data.Float(0.4), "y": data.Float(0.6)}
+				v, err := nn.base().estimate(nn.nn, fv)
+				So(err, ShouldBeNil)
+				v2, err := nn2.base().estimate(nn2.nn, fv)
+				So(err, ShouldBeNil)
+				So(v2, ShouldEqual, v)
+			})
+
+			Convey("loading it as a PassiveAggressiveState should fail", func() {
+				_, err := (&PassiveAggressiveStateCreator{}).LoadState(ctx, buf, data.Map{})
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when writing a tuple without a value", func() {
+			err := nn.Write(ctx, &core.Tuple{
+				Data: data.Map{"feature_vector": data.Map{"x": data.Float(1)}},
+			})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given an invalid unlearner", t, func() {
+		_, err := c.CreateState(ctx, data.Map{
+			"nearest_neighbor_algorithm": data.String("lsh"),
+			"hash_num":                   data.Int(64),
+			"nearest_neighbor_num":       data.Int(3),
+			"unlearner":                  data.String("lru"),
+		})
+
+		Convey("creating a state should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,136 @@
+package regression
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"math"
+	"testing"
+)
+
+func TestNearestNeighbor(t *testing.T) {
+	for _, algo := range []NNAlgorithm{LSH, Minhash, EuclidLSH} {
+		Convey(fmt.Sprintf("Given a NearestNeighbor regression with algorithm %v", algo), t, func() {
+			n, err := NewNearestNeighbor(algo, 64, 3, 1, 0, 0)
+			So(err, ShouldBeNil)
+
+			for i := 0; i < 10; i++ {
+				So(n.Train(FeatureVector{"x": data.Float(1), "y": data.Float(0.1 * float64(i))}, 10), ShouldBeNil)
+				So(n.Train(FeatureVector{"x": data.Float(0.1 * float64(i)), "y": data.Float(1)}, -10), ShouldBeNil)
+			}
+
+			Convey("when estimating a feature vector close to rows having the same value", func() {
+				v, err := n.Estimate(FeatureVector{"x": data.Float(1), "y": data.Float(0.05)})
+				So(err, ShouldBeNil)
+
+				Convey("it should return the value", func() {
+					So(v, ShouldAlmostEqual, 10, 1e-4)
+				})
+			})
+
+			Convey("saving and loading it should keep the model", func() {
+				buf := bytes.NewBuffer(nil)
+				So(n.Save(buf), ShouldBeNil)
+				n2, err := LoadNearestNeighbor(buf)
+				So(err, ShouldBeNil)
+				So(n2.values, ShouldResemble, n.values)
+
+				fv := FeatureVector{"x": data.Float(0.3), "y": data.Float(1)}
+				v, err := n.Estimate(fv)
+				So(err, ShouldBeNil)
+				v2, err := n2.Estimate(fv)
+				So(err, ShouldBeNil)
+				So(v2, ShouldEqual, v)
+			})
+
+			Convey("when clearing it", func() {
+				n.Clear()
+
+				Convey("estimation should return zero", func() {
+					v, err := n.Estimate(FeatureVector{"x": data.Float(1)})
+					So(err, ShouldBeNil)
+					So(v, ShouldEqual, 0)
+				})
+			})
+		})
+	}
+
+	Convey("Given a NearestNeighbor regression without local sensitivity", t, func() {
+		n, err := NewNearestNeighbor(EuclidLSH, 64, 2, 0, 0, 0)
+		So(err, ShouldBeNil)
+		So(n.Train(FeatureVector{"x": data.Float(1)}, 1), ShouldBeNil)
+		So(n.Train(FeatureVector{"x": data.Float(2)}, 3), ShouldBeNil)
+
+		Convey("it should return the mean of values of neighbors", func() {
+			v, err := n.Estimate(FeatureVector{"x": data.Float(1)})
+			So(err, ShouldBeNil)
+			So(math.Abs(float64(v)-2), ShouldBeLessThan, 1e-6)
+		})
+	})
+
+	Convey("Given a NearestNeighbor regression using only the nearest row", t, func() {
+		n, err := NewNearestNeighbor(EuclidLSH, 64, 1, 1, 0, 0)
+		So(err, ShouldBeNil)
+		for i := 0; i < 100; i++ {
+			So(n.Train(FeatureVector{"a": data.Float(1)}, 1), ShouldBeNil)
+			So(n.Train(FeatureVector{"b": data.Float(1)}, 2), ShouldBeNil)
+		}
+
+		Convey("it should return the value of the nearest row", func() {
+			v, err := n.Estimate(FeatureVector{"b": data.Float(1)})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 2)
+		})
+	})
+
+	Convey("Given a NearestNeighbor regression with random unlearner", t, func() {
+		n, err := NewNearestNeighbor(LSH, 64, 3, 1, 5, 42)
+		So(err, ShouldBeNil)
+
+		Convey("when adding more rows than max size", func() {
+			for i := 0; i < 20; i++ {
+				So(n.Train(FeatureVector{"x": data.Float(i)}, float32(i)), ShouldBeNil)
+			}
+
+			Convey("it shouldn't have more rows than max size", func() {
+				So(len(n.values), ShouldEqual, 5)
+			})
+		})
+
+		Convey("when saving and loading it", func() {
+			buf := bytes.NewBuffer(nil)
+			So(n.Save(buf), ShouldBeNil)
+			n2, err := LoadNearestNeighbor(buf)
+			So(err, ShouldBeNil)
+
+			Convey("it should unlearn the same rows as the original one", func() {
+				So(n2.seed, ShouldEqual, 42)
+				for i := 0; i < 20; i++ {
+					fv := FeatureVector{"x": data.Float(i)}
+					So(n.Train(fv, float32(i)), ShouldBeNil)
+					So(n2.Train(fv, float32(i)), ShouldBeNil)
+				}
+				So(n2.values, ShouldResemble, n.values)
+			})
+		})
+	})
+
+	Convey("Given invalid parameters", t, func() {
+		cases := map[string]func() (*NearestNeighbor, error){
+			"invalid algorithm":          func() (*NearestNeighbor, error) { return NewNearestNeighbor(InvalidNNAlgorithm, 64, 3, 1, 0, 0) },
+			"zero hash_num":              func() (*NearestNeighbor, error) { return NewNearestNeighbor(LSH, 0, 3, 1, 0, 0) },
+			"zero nearest_neighbor_num":  func() (*NearestNeighbor, error) { return NewNearestNeighbor(LSH, 64, 0, 1, 0, 0) },
+			"negative local_sensitivity": func() (*NearestNeighbor, error) { return NewNearestNeighbor(LSH, 64, 3, -1, 0, 0) },
+			"negative max_size":          func() (*NearestNeighbor, error) { return NewNearestNeighbor(LSH, 64, 3, 1, -1, 0) },
+		}
+
+		for name, f := range cases {
+			f := f
+			Convey("creating a model with "+name+" should fail", func() {
+				_, err := f()
+				So(err, ShouldNotBeNil)
+			})
+		}
+	})
+}
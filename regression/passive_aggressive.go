@@ -11,31 +11,143 @@ import (
 	"sync"
 )
 
-// PassiveAggressive holds a model for regression.
+// PassiveAggressive holds a linear model for regression. It supports PA,
+// PA1, PA2, AROW and CW methods, all of which share the representation of the
+// model and the loss insensitive to errors within the sensitivity band.
 type PassiveAggressive struct {
 	model model
-	sum   float32
-	sqSum float32
-	count uint64
+
+	// cov has covariances of weights, which only AROW and CW use. A weight
+	// which cov doesn't have has the covariance of 1.
+	cov model
+
+	// scale is the coefficient of all weights in model, which is decreased by
+	// weight decay. A weight of a feature is model[feature] * scale.
+	scale float64
+
+	stats targetStats
 	m     sync.RWMutex
 
+	method      Method
 	regWeight   float32
 	sensitivity float32
+	weightDecay float64
+}
+
+// Options has optional parameters of PassiveAggressive.
+type Options struct {
+	// StatsDecay is the rate at which weights of past target values decay in
+	// statistics used for the sensitivity band. It must be in [0, 1). When
+	// it's 0, all target values have the same weight.
+	StatsDecay float64
+
+	// StatsWindow is the number of last target values used for statistics.
+	// When it's 0, all target values are used. StatsDecay and StatsWindow
+	// cannot be set at the same time.
+	StatsWindow int
+
+	// WeightDecay is the rate at which weights of the model decay every
+	// time the model is trained. It must be in [0, 1).
+	WeightDecay float64
+}
+
+// minScale is the minimum scale of weights. Weights are rescaled when their
+// scale becomes smaller than it to avoid underflow.
+const minScale = 1e-6
+
+const (
+	// PA represents the passive aggressive algorithm of Jubatus, which
+	// bounds the loss by regularization weight.
+	PA Method = iota
+	// PA1 represents passive aggressive with the step size bounded by
+	// regularization weight.
+	PA1
+	// PA2 represents passive aggressive with the step size smoothed by
+	// regularization weight.
+	PA2
+	// AROW represents adaptive regularization of weight vectors.
+	// Regularization weight is the inverse of the regularization parameter.
+	AROW
+	// CW represents confidence weighted learning. Regularization weight is
+	// the confidence parameter.
+	CW
+)
+
+// Method is an enum type which represents learning methods of
+// PassiveAggressive.
+type Method int
+
+// String returns the name of the method. The name is also used as an
+// algorithm name of a saved state.
+func (m Method) String() string {
+	switch m {
+	case PA:
+		return "passive_aggressive"
+	case PA1:
+		return "passive_aggressive_1"
+	case PA2:
+		return "passive_aggressive_2"
+	case AROW:
+		return "arow"
+	case CW:
+		return "cw"
+	default:
+		return "invalid"
+	}
+}
+
+func (m Method) hasCovariance() bool {
+	return m == AROW || m == CW
 }
 
-// NewPassiveAggressive creates a PassiveAggressive model. regWeight must be greater than zero.
-// sensitivity must not be less than zero.
+// NewPassiveAggressive creates a PassiveAggressive model using PA. regWeight
+// must be greater than zero. sensitivity must not be less than zero.
 func NewPassiveAggressive(regWeight float32, sensitivity float32) (*PassiveAggressive, error) {
+	return New(PA, regWeight, sensitivity)
+}
+
+// New creates a PassiveAggressive model using method. regWeight must be
+// greater than zero. sensitivity must not be less than zero.
+func New(method Method, regWeight float32, sensitivity float32) (*PassiveAggressive, error) {
+	return NewWithOptions(method, regWeight, sensitivity, &Options{})
+}
+
+// NewWithOptions creates a PassiveAggressive model using method with optional
+// parameters.
+func NewWithOptions(method Method, regWeight float32, sensitivity float32, opts *Options) (*PassiveAggressive, error) {
+	if method < PA || method > CW {
+		return nil, fmt.Errorf("invalid method: %v", method)
+	}
 	if regWeight <= 0 {
 		return nil, errors.New("regularization weight must be larger than zero")
 	}
 	if sensitivity < 0 {
 		return nil, errors.New("sensitivity must not be less than zero")
 	}
+	if opts.StatsDecay < 0 || opts.StatsDecay >= 1 {
+		return nil, errors.New("decay of statistics must be in [0, 1)")
+	}
+	if opts.StatsWindow < 0 {
+		return nil, errors.New("window size of statistics must not be less than zero")
+	}
+	if opts.StatsDecay > 0 && opts.StatsWindow > 0 {
+		return nil, errors.New("decay and window size of statistics cannot be set at the same time")
+	}
+	if opts.WeightDecay < 0 || opts.WeightDecay >= 1 {
+		return nil, errors.New("weight decay must be in [0, 1)")
+	}
 	return &PassiveAggressive{
-		model:       make(model),
+		model: make(model),
+		cov:   make(model),
+		scale: 1,
+		stats: targetStats{
+			Decay:      opts.StatsDecay,
+			WindowSize: opts.StatsWindow,
+		},
+		method:      method,
 		regWeight:   regWeight,
 		sensitivity: sensitivity,
+		weightDecay: opts.WeightDecay,
 	}, nil
 }
 
@@ -49,14 +161,13 @@ func (pa *PassiveAggressive) Train(v FeatureVector, value float32) error {
 	pa.m.Lock()
 	defer pa.m.Unlock()
 
-	pa.sum += value
-	pa.sqSum += value * value
-	pa.count++
+	pa.stats.add(float64(value))
+	stdDev := float32(pa.stats.stdDev())
 
-	avg := pa.sum / float32(pa.count)
-	stdDev := sqrt(pa.sqSum/float32(pa.count) - avg*avg)
+	// Weights decay before the update so that the update isn't weakened.
+	pa.decayWeights()
 
-	predict := pa.estimate(fv)
+	predict := pa.estimate(fv, nil)
 	error := value - predict
 	loss := abs(error) - pa.sensitivity*stdDev
 
@@ -65,16 +176,118 @@ func (pa *PassiveAggressive) Train(v FeatureVector, value float32) error {
 	}
 
 	// zero vector generates inf or nan.
-	if fv.squaredNorm() < 1e-12 {
+	norm := fv.squaredNorm()
+	if norm < 1e-12 {
 		return nil
 	}
 
 	C := pa.regWeight
-	coeff := sign(error) * min(C, loss) / fv.squaredNorm()
-	pa.update(fv, coeff)
+	switch pa.method {
+	case PA1:
+		pa.update(fv, sign(error)*min(C, loss/norm))
+	case PA2:
+		pa.update(fv, sign(error)*loss/(norm+1/(2*C)))
+	case AROW:
+		pa.updateAROW(fv, sign(error), loss)
+	case CW:
+		pa.updateCW(fv, sign(error), loss)
+	default:
+		pa.update(fv, sign(error)*min(C, loss)/norm)
+	}
 	return nil
 }
 
+// variance returns the variance of the estimate of a feature vector.
+func (pa *PassiveAggressive) variance(v fVector) float32 {
+	var variance float32
+	for _, e := range v {
+		variance += pa.covariance(e.dim) * e.value * e.value
+	}
+	return variance
+}
+
+// updateAROW updates weights and their covariances by AROW.
+func (pa *PassiveAggressive) updateAROW(v fVector, sign, loss float32) {
+	variance := pa.variance(v)
+	if variance < 1e-12 {
+		return
+	}
+
+	beta := 1 / (variance + 1/pa.regWeight)
+	alpha := loss * beta
+	for _, e := range v {
+		cov := pa.covariance(e.dim)
+		x := e.value
+		pa.model[e.dim] += float32(float64(sign*alpha*cov*x) / pa.scale)
+		pa.cov[e.dim] = cov - beta*cov*cov*x*x
+	}
+}
+
+// updateCW updates weights and their covariances by CW. The estimate is
+// regarded as correct when the error is within the sensitivity band, and the
+// margin of the estimate is -loss. As with classifier.CW, weights are updated
+// so that the margin becomes at least regWeight times the variance of the
+// estimate.
+func (pa *PassiveAggressive) updateCW(v fVector, sign, loss float32) {
+	variance := pa.variance(v)
+	if variance < 1e-12 {
+		return
+	}
+
+	C := pa.regWeight
+	margin := -loss
+	b := 1 + 2*C*margin
+	gamma := -b + float32(math.Sqrt(float64(b*b-8*C*(margin-C*variance))))
+	if gamma <= 0 {
+		return
+	}
+	gamma /= 4 * C * variance
+
+	for _, e := range v {
+		cov := pa.covariance(e.dim)
+		x := e.value
+		pa.model[e.dim] += float32(float64(sign*gamma*cov*x) / pa.scale)
+		pa.cov[e.dim] = 1 / (1/cov + 2*gamma*C*x*x)
+	}
+}
+
+// decayWeights multiplies all weights by 1 - weightDecay.
+func (pa *PassiveAggressive) decayWeights() {
+	if pa.weightDecay == 0 {
+		return
+	}
+	pa.scale *= 1 - pa.weightDecay
+	if pa.scale < minScale {
+		pa.model = pa.scaledModel()
+		pa.scale = 1
+	}
+}
+
+// scaledModel returns weights multiplied by the scale. It returns model
+// itself when the scale is 1.
+func (pa *PassiveAggressive) scaledModel() model {
+	if pa.scale == 1 {
+		return pa.model
+	}
+	m := make(model, len(pa.model))
+	for d, w := range pa.model {
+		m[d] = float32(float64(w) * pa.scale)
+	}
+	return m
+}
+
+// weight returns the weight of a feature.
+func (pa *PassiveAggressive) weight(d dim) float32 {
+	return float32(float64(pa.model[d]) * pa.scale)
+}
+
+func (pa *PassiveAggressive) covariance(d dim) float32 {
+	if c, ok := pa.cov[d]; ok {
+		return c
+	}
+	return 1
+}
+
 // Estimate estimates a value from a model and a feature vector.
 func (pa *PassiveAggressive) Estimate(v FeatureVector) (float32, error) {
 	fv, err := v.toInternal()
@@ -85,7 +298,29 @@ func (pa *PassiveAggressive) Estimate(v FeatureVector) (float32, error) {
 	pa.m.RLock()
 	defer pa.m.RUnlock()
 
-	return pa.estimate(fv), nil
+	return pa.estimate(fv, nil), nil
+}
+
+// Explain estimates a value from a model and a feature vector, and returns
+// how much each feature contributes to the estimate.
+func (pa *PassiveAggressive) Explain(v FeatureVector) (float32, []Contribution, error) {
+	fv, err := v.toInternal()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	pa.m.RLock()
+	defer pa.m.RUnlock()
+
+	cs := make([]Contribution, len(fv))
+	est := pa.estimate(fv, func(i int, w float32) {
+		cs[i] = Contribution{
+			Feature: string(fv[i].dim),
+			Value:   fv[i].value,
+			Weight:  w,
+		}
+	})
+	return est, cs, nil
 }
 
 // Clear clears a model.
@@ -94,18 +329,49 @@ func (pa *PassiveAggressive) Clear() {
 	defer pa.m.Unlock()
 
 	pa.model = make(model)
-	pa.sum = 0
-	pa.sqSum = 0
-	pa.count = 0
+	pa.cov = make(model)
+	pa.scale = 1
+	pa.stats.clear()
 }
 
 const (
-	paForwatVersion = 1
+	paForwatVersion = 3
 )
 
 type paMsgpack struct {
 	_struct struct{} `codec:",toarray"`
 
+	// Model has weights multiplied by the scale.
+	Model model
+	Cov   model
+	Stats targetStats
+
+	Method      Method
+	RegWeight   float32
+	Sensitivity float32
+	WeightDecay float64
+}
+
+// paMsgpackV2 is the format version 2, which has statistics of target values
+// in float32 and doesn't have weight decay.
+type paMsgpackV2 struct {
+	_struct struct{} `codec:",toarray"`
+
+	Model model
+	Cov   model
+	Sum   float32
+	SqSum float32
+	Count uint64
+
+	Method      Method
+	RegWeight   float32
+	Sensitivity float32
+}
+
+// paMsgpackV1 is the format version 1, which only supports PA.
+type paMsgpackV1 struct {
+	_struct struct{} `codec:",toarray"`
+
 	Model model
 	Sum   float32
 	SqSum float32
@@ -126,12 +392,13 @@ func (pa *PassiveAggressive) Save(w io.Writer) error {
 
 	enc := codec.NewEncoder(w, regressionMsgpackHandle)
 	err := enc.Encode(&paMsgpack{
-		Model:       pa.model,
-		Sum:         pa.sum,
-		SqSum:       pa.sqSum,
-		Count:       pa.count,
+		Model:       pa.scaledModel(),
+		Cov:         pa.cov,
+		Stats:       pa.stats,
+		Method:      pa.method,
 		RegWeight:   pa.regWeight,
 		Sensitivity: pa.sensitivity,
+		WeightDecay: pa.weightDecay,
 	})
 	return err
 }
@@ -146,29 +413,205 @@ func LoadPassiveAggressive(r io.Reader) (*PassiveAggressive, error) {
 	switch formatVersion[0] {
 	case 1:
 		return loadPassiveAggressiveFormatV1(r)
+	case 2:
+		return loadPassiveAggressiveFormatV2(r)
+	case 3:
+		return loadPassiveAggressiveFormatV3(r)
 	default:
 		return nil, fmt.Errorf("unsupported format version of PassiveAggressive container: %v", formatVersion[0])
 	}
 }
 
 func loadPassiveAggressiveFormatV1(r io.Reader) (*PassiveAggressive, error) {
+	m := paMsgpackV1{}
+	dec := codec.NewDecoder(r, regressionMsgpackHandle)
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	return &PassiveAggressive{
+		model: m.Model,
+		cov:   make(model),
+		scale: 1,
+		stats: targetStats{
+			Sum:    float64(m.Sum),
+			SqSum:  float64(m.SqSum),
+			Weight: float64(m.Count),
+		},
+
+		method:      PA,
+		regWeight:   m.RegWeight,
+		sensitivity: m.Sensitivity,
+	}, nil
+}
+
+func loadPassiveAggressiveFormatV2(r io.Reader) (*PassiveAggressive, error) {
+	m := paMsgpackV2{}
+	dec := codec.NewDecoder(r, regressionMsgpackHandle)
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	if m.Method < PA || m.Method > CW {
+		return nil, fmt.Errorf("invalid method: %v", m.Method)
+	}
+	if m.Model == nil {
+		m.Model = make(model)
+	}
+	if m.Cov == nil {
+		m.Cov = make(model)
+	}
+
+	return &PassiveAggressive{
+		model: m.Model,
+		cov:   m.Cov,
+		scale: 1,
+		stats: targetStats{
+			Sum:    float64(m.Sum),
+			SqSum:  float64(m.SqSum),
+			Weight: float64(m.Count),
+		},
+
+		method:      m.Method,
+		regWeight:   m.RegWeight,
+		sensitivity: m.Sensitivity,
+	}, nil
+}
+
+func loadPassiveAggressiveFormatV3(r io.Reader) (*PassiveAggressive, error) {
 	m := paMsgpack{}
 	dec := codec.NewDecoder(r, regressionMsgpackHandle)
 	if err := dec.Decode(&m); err != nil {
 		return nil, err
 	}
+	if m.Method < PA || m.Method > CW {
+		return nil, fmt.Errorf("invalid method: %v", m.Method)
+	}
+	if st := &m.Stats; st.WindowSize > 0 && (len(st.Window) > st.WindowSize || st.Pos < 0 || st.Pos >= st.WindowSize) {
+		return nil, errors.New("window of statistics is broken")
+	}
+	if m.Model == nil {
+		m.Model = make(model)
+	}
+	if m.Cov == nil {
+		m.Cov = make(model)
+	}
 
 	return &PassiveAggressive{
 		model: m.Model,
-		sum:   m.Sum,
-		sqSum: m.SqSum,
-		count: m.Count,
+		cov:   m.Cov,
+		scale: 1,
+		stats: m.Stats,
 
+		method:      m.Method,
 		regWeight:   m.RegWeight,
 		sensitivity: m.Sensitivity,
+		weightDecay: m.WeightDecay,
 	}, nil
 }
 
+// Mix replaces the model with the mixture of models of sources. Weights are
+// averaged over sources, and a feature which a source doesn't have is
+// regarded as having zero weight in the source. Statistics of target values
+// are also averaged so that the mean and the standard deviation of them
+// become those of all values which sources were trained with. Statistics in
+// the sliding window mode aren't mixed because windows of sources cannot be
+// merged, and the model keeps its own statistics. Covariances of AROW and CW
+// are averaged in the same way as weights except that a missing covariance is
+// regarded as 1. All sources must use the same method and the same mode of
+// statistics as the model. The model itself can be in sources.
+func (pa *PassiveAggressive) Mix(sources []*PassiveAggressive) error {
+	if len(sources) == 0 {
+		return errors.New("at least one source is required")
+	}
+
+	ws := make(map[dim]float64)
+	cs := make(map[dim]float64)
+	var sum, sqSum, weight float64
+	for i, src := range sources {
+		if src.method != pa.method {
+			return fmt.Errorf("source %v uses %v but the model uses %v", i, src.method, pa.method)
+		}
+		if !src.stats.sameMode(&pa.stats) {
+			return fmt.Errorf("source %v has statistics in a different mode from the model", i)
+		}
+	}
+	for _, src := range sources {
+		src.m.RLock()
+		for d, w := range src.model {
+			ws[d] += float64(w) * src.scale
+		}
+		for d := range src.cov {
+			cs[d] = 0
+		}
+		sum += src.stats.Sum
+		sqSum += src.stats.SqSum
+		weight += src.stats.Weight
+		src.m.RUnlock()
+	}
+
+	// A covariance which a source doesn't have is 1.
+	for _, src := range sources {
+		src.m.RLock()
+		for d := range cs {
+			cs[d] += float64(src.covariance(d))
+		}
+		src.m.RUnlock()
+	}
+
+	n := float64(len(sources))
+	m := make(model, len(ws))
+	for d, w := range ws {
+		m[d] = float32(w / n)
+	}
+	cov := make(model, len(cs))
+	for d, c := range cs {
+		cov[d] = float32(c / n)
+	}
+
+	pa.m.Lock()
+	defer pa.m.Unlock()
+	pa.model = m
+	pa.cov = cov
+	pa.scale = 1
+	if pa.stats.WindowSize == 0 {
+		pa.stats.Sum = sum / n
+		pa.stats.SqSum = sqSum / n
+		pa.stats.Weight = weight / n
+	}
+	return nil
+}
+
+// Weights returns weights of all features.
+func (pa *PassiveAggressive) Weights() map[string]float32 {
+	pa.m.RLock()
+	defer pa.m.RUnlock()
+
+	ws := make(map[string]float32, len(pa.model))
+	for d := range pa.model {
+		ws[string(d)] = pa.weight(d)
+	}
+	return ws
+}
+
+// ActiveDims returns the number of dimensions having a non-zero weight.
+func (pa *PassiveAggressive) ActiveDims() int {
+	pa.m.RLock()
+	defer pa.m.RUnlock()
+
+	n := 0
+	for _, w := range pa.model {
+		if w != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Method returns the learning method of the model.
+func (pa *PassiveAggressive) Method() Method {
+	return pa.method
+}
+
 // RegWeight returns regularization weight.
 func (pa *PassiveAggressive) RegWeight() float32 {
 	return pa.regWeight
@@ -179,13 +622,17 @@ func (pa *PassiveAggressive) Sensitivity() float32 {
 	return pa.sensitivity
 }
 
-func (pa *PassiveAggressive) estimate(v fVector) float32 {
+// estimate estimates a value from a feature vector. When contribute isn't
+// nil, it's called with the index of each element of v and its weight. The
+// estimate is the sum of value * weight of all elements.
+func (pa *PassiveAggressive) estimate(v fVector, contribute func(i int, weight float32)) float32 {
 	var ret float32
-	for i := range v {
-		dim := v[i].dim
-		x := v[i].value
-
-		ret += x * pa.model[dim]
+	for i, e := range v {
+		w := pa.weight(e.dim)
+		if contribute != nil {
+			contribute(i, w)
+		}
+		ret += e.value * w
 	}
 	return ret
 }
@@ -195,10 +642,18 @@ func (pa *PassiveAggressive) update(v fVector, coeff float32) {
 		dim := v[i].dim
 		x := v[i].value
 
-		pa.model[dim] += coeff * x
+		pa.model[dim] += float32(float64(coeff*x) / pa.scale)
 	}
 }
 
+// Contribution is a contribution of a feature to an estimate. The
+// contribution is Value * Weight.
+type Contribution struct {
+	Feature string
+	Value   float32
+	Weight  float32
+}
+
 type dim string
 
 type model map[dim]float32
@@ -240,10 +695,6 @@ func min(x float32, y float32) float32 {
 	return float32(math.Min(float64(x), float64(y)))
 }
 
-func sqrt(x float32) float32 {
-	return float32(math.Sqrt(float64(x)))
-}
-
 func sign(x float32) float32 {
 	return float32(math.Copysign(1, float64(x)))
 }
@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/fvconv"
 	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"github.com/zeromberto/jubatus/mix"
+	"github.com/zeromberto/jubatus/normalizer"
 	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
 	"gopkg.in/sensorbee/sensorbee.v0/core"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
@@ -12,40 +15,44 @@ import (
 	"reflect"
 )
 
-// regressionMsgpack has information of the saved file.
-type regressionMsgpack struct {
-	_struct   struct{} `codec:",toarray"`
-	Algorithm string
-}
-
 type PassiveAggressiveState struct {
-	pa                 *PassiveAggressive
-	valueField         string
-	featureVectorField string
-}
+	stateBase
+	pa *PassiveAggressive
 
-var _ core.SavableSharedState = &PassiveAggressiveState{}
-
-type paStateMsgpack struct {
-	_struct            struct{} `codec:",toarray"`
-	ValueField         string
-	FeatureVectorField string
+	// targetModels has a model for each target when the state has targets.
+	// pa is nil in that case.
+	targetModels []*PassiveAggressive
 }
 
+var (
+	_ core.SavableSharedState = &PassiveAggressiveState{}
+	_ mix.RemoteMixer         = &PassiveAggressiveState{}
+)
+
 // PassiveAggressiveStateCreator is used by BQL to create PassiveAggressiveState as a UDS.
+// Method decides which learning method the state uses.
 type PassiveAggressiveStateCreator struct {
+	Method Method
 }
 
 var _ udf.UDSLoader = &PassiveAggressiveStateCreator{}
 
 func (c *PassiveAggressiveStateCreator) CreateState(ctx *core.Context, params data.Map) (core.SharedState, error) {
-	value, err := pluginutil.ExtractParamAsStringWithDefault(params, "value_field", "value")
+	base, err := newStateBase(params)
 	if err != nil {
 		return nil, err
 	}
-	fv, err := pluginutil.ExtractParamAsStringWithDefault(params, "feature_vector_field", "feature_vector")
-	if err != nil {
-		return nil, err
+
+	// The model is imported from a model file of the original Jubatus when
+	// jubatus_model parameter is given.
+	if _, ok := params["jubatus_model"]; ok {
+		if c.Method != PA {
+			return nil, fmt.Errorf("jubatus_model parameter isn't supported by %v", c.Method)
+		}
+		if base.targets != nil {
+			return nil, errors.New("jubatus_model parameter cannot be used with targets parameter")
+		}
+		return createPassiveAggressiveStateFromJubatus(params, base)
 	}
 
 	rw, err := pluginutil.ExtractParamAndConvertToFloat(params, "regularization_weight")
@@ -64,16 +71,40 @@ func (c *PassiveAggressiveStateCreator) CreateState(ctx *core.Context, params da
 		return nil, errors.New("sensitivity parameter must be not less than zero")
 	}
 
-	pa, err := NewPassiveAggressive(float32(rw), float32(sen))
+	opts := &Options{}
+	if _, ok := params["target_stats_decay"]; ok {
+		opts.StatsDecay, err = pluginutil.ExtractParamAndConvertToFloat(params, "target_stats_decay")
+		if err != nil {
+			return nil, err
+		}
+	}
+	window, err := pluginutil.ExtractParamAsIntWithDefault(params, "target_stats_window", 0)
 	if err != nil {
 		return nil, err
 	}
+	opts.StatsWindow = int(window)
+	if _, ok := params["weight_decay"]; ok {
+		opts.WeightDecay, err = pluginutil.ExtractParamAndConvertToFloat(params, "weight_decay")
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return &PassiveAggressiveState{
-		pa:                 pa,
-		valueField:         value,
-		featureVectorField: fv,
-	}, nil
+	s := &PassiveAggressiveState{
+		stateBase: base,
+	}
+	for i := 0; i < base.numModels(); i++ {
+		pa, err := NewWithOptions(c.Method, float32(rw), float32(sen), opts)
+		if err != nil {
+			return nil, err
+		}
+		if base.targets == nil {
+			s.pa = pa
+		} else {
+			s.targetModels = append(s.targetModels, pa)
+		}
+	}
+	return s, nil
 }
 
 var (
@@ -95,32 +126,49 @@ func (c *PassiveAggressiveStateCreator) LoadState(ctx *core.Context, r io.Reader
 
 	switch formatVersion[0] {
 	case 1:
-		return loadPassiveAggressiveStateFormatV1(ctx, r)
+		return loadPassiveAggressiveStateFormatV1(ctx, r, c.Method)
+	case 2:
+		return loadPassiveAggressiveStateFormatV2(ctx, r, c.Method)
+	case 3:
+		return loadPassiveAggressiveStateFormatV3(ctx, r, c.Method)
+	case 4:
+		return loadPassiveAggressiveStateFormatV4(ctx, r, c.Method)
+	case 5:
+		return loadPassiveAggressiveStateFormatV5(ctx, r, c.Method)
 	default:
 		return nil, fmt.Errorf("unsupported format version of PassiveAggressiveState container: %v", formatVersion[0])
 	}
 }
 
-func loadPassiveAggressiveStateFormatV1(ctx *core.Context, r io.Reader) (core.SharedState, error) {
-	var header regressionMsgpack
-	dec := codec.NewDecoder(r, regressionMsgpackHandle)
-	if err := dec.Decode(&header); err != nil {
+func loadPassiveAggressiveStateFormatV1(ctx *core.Context, r io.Reader, method Method) (core.SharedState, error) {
+	// The format version 1 doesn't have a converter.
+	s, err := loadPassiveAggressiveStateHeader(r, method)
+	if err != nil {
 		return nil, err
 	}
-	if header.Algorithm != "passive_aggressive" {
-		return nil, fmt.Errorf("unsupported regression algorithm: %v", header.Algorithm)
+
+	pa, err := loadPassiveAggressiveModel(r, method)
+	if err != nil {
+		return nil, err
 	}
+	s.pa = pa
+	return s, nil
+}
 
-	s := &PassiveAggressiveState{}
+func loadPassiveAggressiveStateFormatV2(ctx *core.Context, r io.Reader, method Method) (core.SharedState, error) {
+	// The format version 2 doesn't have a normalizer.
+	s, err := loadPassiveAggressiveStateHeader(r, method)
+	if err != nil {
+		return nil, err
+	}
 
-	var d paStateMsgpack
-	if err := dec.Decode(&d); err != nil {
+	conv, err := fvconv.Load(r)
+	if err != nil {
 		return nil, err
 	}
-	s.valueField = d.ValueField
-	s.featureVectorField = d.FeatureVectorField
+	s.converter = conv
 
-	pa, err := LoadPassiveAggressive(r)
+	pa, err := loadPassiveAggressiveModel(r, method)
 	if err != nil {
 		return nil, err
 	}
@@ -128,36 +176,156 @@ func loadPassiveAggressiveStateFormatV1(ctx *core.Context, r io.Reader) (core.Sh
 	return s, nil
 }
 
-func (*PassiveAggressiveState) Terminate(ctx *core.Context) error {
-	return nil
+func loadPassiveAggressiveStateFormatV3(ctx *core.Context, r io.Reader, method Method) (core.SharedState, error) {
+	// The format version 3 doesn't have an interval estimator.
+	s, err := loadPassiveAggressiveStateHeader(r, method)
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := fvconv.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.converter = conv
+
+	norm, err := normalizer.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.normalizer = norm
+
+	pa, err := loadPassiveAggressiveModel(r, method)
+	if err != nil {
+		return nil, err
+	}
+	s.pa = pa
+	return s, nil
 }
 
-func (pa *PassiveAggressiveState) Write(ctx *core.Context, t *core.Tuple) error {
-	vval, ok := t.Data[pa.valueField]
-	if !ok {
-		return fmt.Errorf("%s field is missing", pa.valueField)
+func loadPassiveAggressiveStateFormatV4(ctx *core.Context, r io.Reader, method Method) (core.SharedState, error) {
+	// The format version 4 doesn't have targets.
+	s, err := loadPassiveAggressiveStateHeader(r, method)
+	if err != nil {
+		return nil, err
 	}
-	val64, err := data.ToFloat(vval)
+
+	conv, err := fvconv.Load(r)
 	if err != nil {
-		return fmt.Errorf("%s cannot be converted to float: %v", pa.valueField, err)
+		return nil, err
 	}
-	val := float32(val64)
+	s.converter = conv
 
-	vfv, ok := t.Data[pa.featureVectorField]
-	if !ok {
-		return fmt.Errorf("%s field is missing", pa.featureVectorField)
+	norm, err := normalizer.Load(r)
+	if err != nil {
+		return nil, err
 	}
-	fv, err := data.AsMap(vfv)
+	s.normalizer = norm
+
+	interval, err := loadIntervalEstimator(r)
 	if err != nil {
-		return fmt.Errorf("%s value is not a map: %v", pa.featureVectorField, err)
+		return nil, err
 	}
+	s.interval = interval
 
-	err = pa.pa.Train(FeatureVector(fv), val)
-	return err
+	pa, err := loadPassiveAggressiveModel(r, method)
+	if err != nil {
+		return nil, err
+	}
+	s.pa = pa
+	return s, nil
+}
+
+func loadPassiveAggressiveStateFormatV5(ctx *core.Context, r io.Reader, method Method) (core.SharedState, error) {
+	s, err := loadPassiveAggressiveStateHeader(r, method)
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := fvconv.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.converter = conv
+
+	norm, err := normalizer.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	s.normalizer = norm
+
+	interval, err := loadIntervalEstimator(r)
+	if err != nil {
+		return nil, err
+	}
+	s.interval = interval
+
+	var t targetsMsgpack
+	if err := codec.NewDecoder(r, regressionMsgpackHandle).Decode(&t); err != nil {
+		return nil, err
+	}
+	if len(t.Targets) == 0 {
+		pa, err := loadPassiveAggressiveModel(r, method)
+		if err != nil {
+			return nil, err
+		}
+		s.pa = pa
+		return s, nil
+	}
+
+	s.targets = t.Targets
+	for range t.Targets {
+		pa, err := loadPassiveAggressiveModel(r, method)
+		if err != nil {
+			return nil, err
+		}
+		s.targetModels = append(s.targetModels, pa)
+	}
+	return s, nil
+}
+
+// loadPassiveAggressiveModel loads the model of a state and checks that it
+// uses method.
+func loadPassiveAggressiveModel(r io.Reader, method Method) (*PassiveAggressive, error) {
+	pa, err := LoadPassiveAggressive(r)
+	if err != nil {
+		return nil, err
+	}
+	if pa.Method() != method {
+		return nil, fmt.Errorf("the model uses %v but %v is expected", pa.Method(), method)
+	}
+	return pa, nil
+}
+
+func loadPassiveAggressiveStateHeader(r io.Reader, method Method) (*PassiveAggressiveState, error) {
+	var header regressionMsgpack
+	dec := codec.NewDecoder(r, regressionMsgpackHandle)
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Algorithm != method.String() {
+		return nil, fmt.Errorf("unsupported regression algorithm: %v (%v is expected)", header.Algorithm, method)
+	}
+
+	var d stateMsgpack
+	if err := dec.Decode(&d); err != nil {
+		return nil, err
+	}
+	return &PassiveAggressiveState{
+		stateBase: newStateBaseFromMsgpack(&d),
+	}, nil
+}
+
+func (*PassiveAggressiveState) Terminate(ctx *core.Context) error {
+	return nil
+}
+
+func (pa *PassiveAggressiveState) Write(ctx *core.Context, t *core.Tuple) error {
+	return pa.train(pa.regressors(), t)
 }
 
 const (
-	regressionFormatVersion = 1
+	regressionFormatVersion = 5
 )
 
 // Save is provided as a part of core.SavableSharedState.
@@ -168,27 +336,56 @@ func (pa *PassiveAggressiveState) Save(ctx *core.Context, w io.Writer, params da
 
 	enc := codec.NewEncoder(w, regressionMsgpackHandle)
 	if err := enc.Encode(&regressionMsgpack{
-		Algorithm: "passive_aggressive",
+		Algorithm: pa.method().String(),
 	}); err != nil {
 		return err
 	}
 
-	if err := enc.Encode(&paStateMsgpack{
-		ValueField:         pa.valueField,
-		FeatureVectorField: pa.featureVectorField,
+	if err := enc.Encode(pa.toMsgpack()); err != nil {
+		return err
+	}
+	if err := fvconv.Save(w, pa.converter); err != nil {
+		return err
+	}
+	if err := normalizer.Save(w, pa.normalizer); err != nil {
+		return err
+	}
+	if err := saveIntervalEstimator(w, pa.interval); err != nil {
+		return err
+	}
+	if err := codec.NewEncoder(w, regressionMsgpackHandle).Encode(&targetsMsgpack{
+		Targets: pa.targets,
 	}); err != nil {
 		return err
 	}
-	return pa.pa.Save(w)
+	for _, m := range pa.models() {
+		if err := m.Save(w); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func PassiveAggressiveEstimate(ctx *core.Context, stateName string, featureVector data.Map) (float32, error) {
-	s, err := lookupPassiveAggressiveState(ctx, stateName)
-	if err != nil {
-		return 0, err
+// models returns all models the state has.
+func (pa *PassiveAggressiveState) models() []*PassiveAggressive {
+	if pa.pa != nil {
+		return []*PassiveAggressive{pa.pa}
 	}
+	return pa.targetModels
+}
 
-	return s.pa.Estimate(FeatureVector(featureVector))
+// method returns the learning method of models of the state.
+func (pa *PassiveAggressiveState) method() Method {
+	return pa.models()[0].Method()
+}
+
+func (pa *PassiveAggressiveState) regressors() []regressor {
+	ms := pa.models()
+	rs := make([]regressor, len(ms))
+	for i, m := range ms {
+		rs[i] = m
+	}
+	return rs
 }
 
 func lookupPassiveAggressiveState(ctx *core.Context, stateName string) (*PassiveAggressiveState, error) {
@@ -197,8 +394,61 @@ func lookupPassiveAggressiveState(ctx *core.Context, stateName string) (*Passive
 		return nil, err
 	}
 
-	if s, ok := st.(*PassiveAggressiveState); ok {
-		return s, nil
+	s, ok := st.(*PassiveAggressiveState)
+	if !ok {
+		return nil, fmt.Errorf("state '%v' cannot be converted to paState", stateName)
+	}
+	return s, nil
+}
+
+// targetModel returns the model of the target. target must be empty when the
+// state doesn't have targets, and must be given otherwise.
+func (pa *PassiveAggressiveState) targetModel(target string) (*PassiveAggressive, error) {
+	if pa.targets == nil {
+		if target != "" {
+			return nil, fmt.Errorf("the state doesn't have target %v", target)
+		}
+		return pa.pa, nil
 	}
-	return nil, fmt.Errorf("state '%v' cannot be converted to paState", stateName)
+	if target == "" {
+		return nil, errors.New("a target must be given because the state has multiple targets")
+	}
+	for i, t := range pa.targets {
+		if t == target {
+			return pa.targetModels[i], nil
+		}
+	}
+	return nil, fmt.Errorf("the state doesn't have target %v", target)
+}
+
+// Mix replaces the model of the state with the mixture of models of sources.
+// See PassiveAggressive.Mix for details. When the state has targets, models
+// are mixed for each target, and all sources must have the same targets.
+func (pa *PassiveAggressiveState) Mix(sources []core.SharedState) error {
+	ms := pa.models()
+	ps := make([][]*PassiveAggressive, len(ms))
+	for i, src := range sources {
+		s, ok := src.(*PassiveAggressiveState)
+		if !ok {
+			return fmt.Errorf("source %v isn't a PassiveAggressive state", i)
+		}
+		if !reflect.DeepEqual(s.targets, pa.targets) {
+			return fmt.Errorf("source %v has different targets", i)
+		}
+		for j, m := range s.models() {
+			ps[j] = append(ps[j], m)
+		}
+	}
+	for j, m := range ms {
+		if err := m.Mix(ps[j]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadMixSource loads a state saved by Save so that it can be mixed with the
+// state.
+func (pa *PassiveAggressiveState) LoadMixSource(ctx *core.Context, r io.Reader) (core.SharedState, error) {
+	return (&PassiveAggressiveStateCreator{Method: pa.method()}).LoadState(ctx, r, data.Map{})
 }
@@ -60,3 +60,146 @@ func TestPassiveAggressiveStateLoad(t *testing.T) {
 		})
 	})
 }
+
+func TestPassiveAggressiveStateWithNormalizer(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := PassiveAggressiveStateCreator{}
+	pas, err := c.CreateState(ctx, data.Map{
+		"regularization_weight": data.Float(3.402823e+38),
+		"sensitivity":           data.Float(0.1),
+		"normalizer":            data.String("min_max"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SharedStates.Add("pa", "jubaregression_pa", pas); err != nil {
+		t.Fatal(err)
+	}
+	pa := pas.(*PassiveAggressiveState)
+
+	Convey("Given a PassiveAggressiveState having a normalizer", t, func() {
+		Convey("when writing tuples", func() {
+			for i := 0; i <= 100; i++ {
+				So(pa.Write(ctx, &core.Tuple{
+					Data: data.Map{
+						"value": data.Float(i),
+						"feature_vector": data.Map{
+							"n": data.Int(i * 1000),
+						},
+					},
+				}), ShouldBeNil)
+			}
+
+			Convey("estimating should normalize the feature vector", func() {
+				v, err := PassiveAggressiveEstimate(ctx, "pa", data.Map{"n": data.Int(50000)})
+				So(err, ShouldBeNil)
+				w, err := pa.pa.Estimate(FeatureVector{"n": data.Float(0.5)})
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, w)
+			})
+
+			Convey("saving and loading it should keep the normalizer", func() {
+				buf := bytes.NewBuffer(nil)
+				So(pa.Save(ctx, buf, data.Map{}), ShouldBeNil)
+				pa2, err := c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				So(pa2, ShouldResemble, pa)
+			})
+		})
+	})
+
+	Convey("Given an unknown normalizer", t, func() {
+		_, err := c.CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(1),
+			"sensitivity":           data.Float(0.1),
+			"normalizer":            data.String("unknown"),
+		})
+
+		Convey("creating a state should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestPassiveAggressiveStateMethods(t *testing.T) {
+	ctx := core.NewContext(nil)
+	params := data.Map{
+		"regularization_weight": data.Float(1),
+		"sensitivity":           data.Float(0.1),
+	}
+
+	Convey("Given a state using AROW", t, func() {
+		c := PassiveAggressiveStateCreator{Method: AROW}
+		s, err := c.CreateState(ctx, params)
+		So(err, ShouldBeNil)
+		st := s.(*PassiveAggressiveState)
+		So(st.pa.Method(), ShouldEqual, AROW)
+
+		for i := 0; i < 10; i++ {
+			So(st.Write(ctx, &core.Tuple{Data: data.Map{
+				"value":          data.Float(i),
+				"feature_vector": data.Map{"n": data.Int(i)},
+			}}), ShouldBeNil)
+		}
+
+		Convey("when saving it", func() {
+			buf := bytes.NewBuffer(nil)
+			So(st.Save(ctx, buf, data.Map{}), ShouldBeNil)
+
+			Convey("it should be loaded by the creator of AROW", func() {
+				s2, err := c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				So(s2, ShouldResemble, st)
+			})
+
+			Convey("it shouldn't be loaded by the creator of another method", func() {
+				_, err := (&PassiveAggressiveStateCreator{Method: PA1}).LoadState(ctx, buf, data.Map{})
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when mixing it with a state using another method", func() {
+			other, err := (&PassiveAggressiveStateCreator{Method: CW}).CreateState(ctx, params)
+			So(err, ShouldBeNil)
+			err = st.Mix([]core.SharedState{st, other})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestPassiveAggressiveStateWithDecay(t *testing.T) {
+	ctx := core.NewContext(nil)
+	c := PassiveAggressiveStateCreator{}
+
+	Convey("Given parameters having decay", t, func() {
+		params := data.Map{
+			"regularization_weight": data.Float(1),
+			"sensitivity":           data.Float(0.1),
+			"target_stats_window":   data.Int(10),
+			"weight_decay":          data.Float(0.01),
+		}
+
+		Convey("when creating a state", func() {
+			s, err := c.CreateState(ctx, params)
+			So(err, ShouldBeNil)
+			pa := s.(*PassiveAggressiveState)
+
+			Convey("the model should have the options", func() {
+				So(pa.pa.stats.WindowSize, ShouldEqual, 10)
+				So(pa.pa.weightDecay, ShouldEqual, 0.01)
+			})
+		})
+
+		Convey("when creating a state with both decay and window of statistics", func() {
+			params["target_stats_decay"] = data.Float(0.1)
+			_, err := c.CreateState(ctx, params)
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,262 @@
+package regression
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPassiveAggressiveMethods(t *testing.T) {
+	for _, m := range []Method{PA, PA1, PA2, AROW, CW} {
+		m := m
+		Convey(fmt.Sprintf("Given a model using %v", m), t, func() {
+			pa, err := New(m, 1, 0.01)
+			So(err, ShouldBeNil)
+
+			Convey("when training it with a linear function", func() {
+				r := rand.New(rand.NewSource(0))
+				for i := 0; i < 1000; i++ {
+					x := r.Float64()*2 - 1
+					So(pa.Train(FeatureVector{"x": data.Float(x), "bias": data.Float(1)}, float32(2*x+1)), ShouldBeNil)
+				}
+
+				Convey("it should estimate values", func() {
+					v, err := pa.Estimate(FeatureVector{"x": data.Float(0.5), "bias": data.Float(1)})
+					So(err, ShouldBeNil)
+					So(math.Abs(float64(v)-2), ShouldBeLessThan, 0.1)
+				})
+
+				Convey("saving and loading it should keep the model", func() {
+					buf := bytes.NewBuffer(nil)
+					So(pa.Save(buf), ShouldBeNil)
+					pa2, err := LoadPassiveAggressive(buf)
+					So(err, ShouldBeNil)
+					So(pa2, ShouldResemble, pa)
+				})
+			})
+		})
+	}
+
+	Convey("Given an AROW model", t, func() {
+		pa, err := New(AROW, 1, 0)
+		So(err, ShouldBeNil)
+
+		Convey("when training it", func() {
+			So(pa.Train(FeatureVector{"x": data.Float(1)}, 1), ShouldBeNil)
+
+			Convey("covariances of trained features should decrease", func() {
+				So(pa.cov["x"], ShouldAlmostEqual, 0.5)
+				So(pa.model["x"], ShouldAlmostEqual, 0.5)
+			})
+		})
+
+		Convey("when training it once with regularization weight 0.5", func() {
+			pa, err := New(AROW, 0.5, 0)
+			So(err, ShouldBeNil)
+			So(pa.Train(FeatureVector{"x": data.Float(2)}, 3), ShouldBeNil)
+
+			Convey("it should be updated by AROW", func() {
+				// variance = 4, beta = 1/(4+1/0.5) = 1/6, alpha = 3*beta
+				So(pa.model["x"], ShouldAlmostEqual, 1, 1e-6)
+				So(pa.cov["x"], ShouldAlmostEqual, 1.0/3, 1e-6)
+			})
+		})
+	})
+
+	Convey("Given a CW model having confidence parameter 0.5", t, func() {
+		pa, err := New(CW, 0.5, 0)
+		So(err, ShouldBeNil)
+
+		Convey("when training it once", func() {
+			So(pa.Train(FeatureVector{"x": data.Float(2)}, 3), ShouldBeNil)
+
+			Convey("it should be updated by CW", func() {
+				// margin = -3, variance = 4, b = 1+2*0.5*(-3) = -2,
+				// gamma = (2+sqrt(4-8*0.5*(-3-0.5*4)))/(4*0.5*4)
+				//       = (2+sqrt(24))/8
+				gamma := (2 + math.Sqrt(24)) / 8
+				So(pa.model["x"], ShouldAlmostEqual, gamma*2, 1e-6)
+				So(pa.cov["x"], ShouldAlmostEqual, 1/(1+2*gamma*0.5*4), 1e-6)
+			})
+
+			Convey("the margin should be the confidence parameter times the variance", func() {
+				v, err := pa.Estimate(FeatureVector{"x": data.Float(2)})
+				So(err, ShouldBeNil)
+				So(v-3, ShouldAlmostEqual, 0.5*pa.cov["x"]*4, 1e-5)
+			})
+		})
+	})
+
+	Convey("Given a PA1 model having small regularization weight", t, func() {
+		pa, err := New(PA1, 0.1, 0)
+		So(err, ShouldBeNil)
+
+		Convey("when training it with a large error", func() {
+			So(pa.Train(FeatureVector{"x": data.Float(1)}, 100), ShouldBeNil)
+
+			Convey("the step size should be bounded", func() {
+				So(pa.model["x"], ShouldAlmostEqual, 0.1, 1e-6)
+			})
+		})
+	})
+
+	Convey("Given an invalid method", t, func() {
+		_, err := New(Method(-1), 1, 0)
+
+		Convey("creating a model should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestLoadPassiveAggressiveFormatV1(t *testing.T) {
+	Convey("Given a model saved in the format version 1", t, func() {
+		buf := bytes.NewBuffer([]byte{1})
+		So(codec.NewEncoder(buf, regressionMsgpackHandle).Encode(&paMsgpackV1{
+			Model:       model{"x": 1},
+			RegWeight:   2,
+			Sensitivity: 0.5,
+		}), ShouldBeNil)
+
+		Convey("when loading it", func() {
+			pa, err := LoadPassiveAggressive(buf)
+			So(err, ShouldBeNil)
+
+			Convey("it should use PA", func() {
+				So(pa.Method(), ShouldEqual, PA)
+				So(pa.model, ShouldResemble, model{"x": 1})
+				So(pa.RegWeight(), ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestPassiveAggressiveOptions(t *testing.T) {
+	Convey("Given a model with windowed statistics", t, func() {
+		pa, err := NewWithOptions(PA, 1, 1, &Options{StatsWindow: 3})
+		So(err, ShouldBeNil)
+
+		Convey("when training it with more values than the window size", func() {
+			for _, v := range []float32{100, -100, 1, 2, 3} {
+				So(pa.Train(FeatureVector{"x": data.Float(1)}, v), ShouldBeNil)
+			}
+
+			Convey("statistics should only have the last values", func() {
+				So(pa.stats.Weight, ShouldEqual, 3)
+				So(pa.stats.Sum, ShouldAlmostEqual, 6)
+				So(pa.stats.stdDev(), ShouldAlmostEqual, math.Sqrt(2.0/3))
+			})
+		})
+	})
+
+	Convey("Given a model with decayed statistics", t, func() {
+		pa, err := NewWithOptions(PA, 1, 1, &Options{StatsDecay: 0.5})
+		So(err, ShouldBeNil)
+
+		Convey("when training it", func() {
+			for _, v := range []float32{4, 2} {
+				So(pa.Train(FeatureVector{"x": data.Float(1)}, v), ShouldBeNil)
+			}
+
+			Convey("past values should have smaller weights", func() {
+				So(pa.stats.Weight, ShouldAlmostEqual, 1.5)
+				So(pa.stats.Sum, ShouldAlmostEqual, 4)
+			})
+		})
+	})
+
+	Convey("Given a model with weight decay", t, func() {
+		pa, err := NewWithOptions(PA, 1, 0, &Options{WeightDecay: 0.5})
+		So(err, ShouldBeNil)
+		So(pa.Train(FeatureVector{"x": data.Float(1)}, 1), ShouldBeNil)
+
+		Convey("when training it with another feature", func() {
+			So(pa.Train(FeatureVector{"y": data.Float(1)}, 1), ShouldBeNil)
+
+			Convey("weights of other features should decay", func() {
+				ws := pa.Weights()
+				So(ws["x"], ShouldAlmostEqual, 0.5)
+				So(ws["y"], ShouldAlmostEqual, 1)
+			})
+
+			Convey("saving and loading it should keep weights", func() {
+				buf := bytes.NewBuffer(nil)
+				So(pa.Save(buf), ShouldBeNil)
+				pa2, err := LoadPassiveAggressive(buf)
+				So(err, ShouldBeNil)
+				So(pa2.Weights(), ShouldResemble, pa.Weights())
+				So(pa2.weightDecay, ShouldEqual, 0.5)
+			})
+		})
+
+		Convey("when training it many times", func() {
+			for i := 0; i < 100; i++ {
+				So(pa.Train(FeatureVector{"y": data.Float(1)}, 0), ShouldBeNil)
+			}
+
+			Convey("the scale shouldn't underflow", func() {
+				So(pa.scale, ShouldBeGreaterThanOrEqualTo, minScale)
+			})
+		})
+	})
+
+	Convey("Given invalid options", t, func() {
+		cases := map[string]*Options{
+			"negative decay":     {StatsDecay: -0.1},
+			"too large decay":    {StatsDecay: 1},
+			"negative window":    {StatsWindow: -1},
+			"decay and window":   {StatsDecay: 0.1, StatsWindow: 10},
+			"too large weight":   {WeightDecay: 1},
+			"negative weight dc": {WeightDecay: -0.1},
+		}
+		for name, opts := range cases {
+			opts := opts
+			Convey("creating a model with "+name+" should fail", func() {
+				_, err := NewWithOptions(PA, 1, 0, opts)
+				So(err, ShouldNotBeNil)
+			})
+		}
+	})
+
+	Convey("Given models with statistics in different modes", t, func() {
+		a, err := NewWithOptions(PA, 1, 0, &Options{StatsWindow: 3})
+		So(err, ShouldBeNil)
+		b, err := New(PA, 1, 0)
+		So(err, ShouldBeNil)
+
+		Convey("mixing them should fail", func() {
+			So(a.Mix([]*PassiveAggressive{a, b}), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestLoadPassiveAggressiveFormatV2(t *testing.T) {
+	Convey("Given a model saved in the format version 2", t, func() {
+		buf := bytes.NewBuffer([]byte{2})
+		So(codec.NewEncoder(buf, regressionMsgpackHandle).Encode(&paMsgpackV2{
+			Model:       model{"x": 1},
+			Sum:         6,
+			SqSum:       14,
+			Count:       3,
+			Method:      PA1,
+			RegWeight:   2,
+			Sensitivity: 0.5,
+		}), ShouldBeNil)
+
+		Convey("when loading it", func() {
+			pa, err := LoadPassiveAggressive(buf)
+			So(err, ShouldBeNil)
+
+			Convey("it should have cumulative statistics in float64", func() {
+				So(pa.Method(), ShouldEqual, PA1)
+				So(pa.stats, ShouldResemble, targetStats{Sum: 6, SqSum: 14, Weight: 3})
+				So(pa.Weights(), ShouldResemble, map[string]float32{"x": 1})
+			})
+		})
+	})
+}
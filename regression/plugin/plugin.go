@@ -6,7 +6,16 @@ import (
 )
 
 func init() {
-	udf.MustRegisterGlobalUDSCreator("jubaregression_pa", &regression.PassiveAggressiveStateCreator{})
+	udf.MustRegisterGlobalUDSCreator("jubaregression_pa", &regression.PassiveAggressiveStateCreator{Method: regression.PA})
+	udf.MustRegisterGlobalUDSCreator("jubaregression_pa1", &regression.PassiveAggressiveStateCreator{Method: regression.PA1})
+	udf.MustRegisterGlobalUDSCreator("jubaregression_pa2", &regression.PassiveAggressiveStateCreator{Method: regression.PA2})
+	udf.MustRegisterGlobalUDSCreator("jubaregression_arow", &regression.PassiveAggressiveStateCreator{Method: regression.AROW})
+	udf.MustRegisterGlobalUDSCreator("jubaregression_cw", &regression.PassiveAggressiveStateCreator{Method: regression.CW})
+	udf.MustRegisterGlobalUDSCreator("jubaregression_nn", &regression.NearestNeighborStateCreator{})
 
-	udf.MustRegisterGlobalUDF("jubaregression_estimate", udf.MustConvertGeneric(regression.PassiveAggressiveEstimate))
+	// jubaregression_estimate works with states of all methods and algorithms.
+	udf.MustRegisterGlobalUDF("jubaregression_estimate", udf.MustConvertGeneric(regression.Estimate))
+	udf.MustRegisterGlobalUDF("jubaregression_estimate_interval", udf.MustConvertGeneric(regression.EstimateWithInterval))
+	udf.MustRegisterGlobalUDF("jubaregression_explain", udf.MustConvertGeneric(regression.PassiveAggressiveExplain))
+	udf.MustRegisterGlobalUDF("jubaregression_weights", udf.MustConvertGeneric(regression.PassiveAggressiveWeights))
 }
@@ -0,0 +1,346 @@
+package regression
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zeromberto/jubatus/fvconv"
+	"github.com/zeromberto/jubatus/internal/pipeline"
+	"github.com/zeromberto/jubatus/internal/pluginutil"
+	"github.com/zeromberto/jubatus/normalizer"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// regressionMsgpack has information of the saved file.
+type regressionMsgpack struct {
+	_struct   struct{} `codec:",toarray"`
+	Algorithm string
+}
+
+// stateMsgpack has parameters common to states of all algorithms.
+type stateMsgpack struct {
+	_struct            struct{} `codec:",toarray"`
+	ValueField         string
+	FeatureVectorField string
+}
+
+// stateBase has fields common to states of all regression algorithms.
+type stateBase struct {
+	valueField         string
+	featureVectorField string
+
+	// converter converts raw values into feature vectors. Values are used
+	// as feature vectors when it's nil.
+	converter *fvconv.Converter
+
+	// normalizer normalizes feature vectors. They aren't normalized when
+	// it's nil.
+	normalizer *normalizer.Normalizer
+
+	// interval computes prediction intervals of estimates. They aren't
+	// computed when it's nil.
+	interval *intervalEstimator
+
+	// targets has names of targets when the state estimates multiple values
+	// at once. The state has a model for each target in the same order. It's
+	// nil when the state estimates a single value.
+	targets []string
+}
+
+// targetsMsgpack has names of targets of a state.
+type targetsMsgpack struct {
+	_struct struct{} `codec:",toarray"`
+	Targets []string
+}
+
+func newStateBase(params data.Map) (stateBase, error) {
+	value, err := pluginutil.ExtractParamAsStringWithDefault(params, "value_field", "value")
+	if err != nil {
+		return stateBase{}, err
+	}
+	fv, err := pluginutil.ExtractParamAsStringWithDefault(params, "feature_vector_field", "feature_vector")
+	if err != nil {
+		return stateBase{}, err
+	}
+	conv, err := fvconv.NewFromParams(params)
+	if err != nil {
+		return stateBase{}, err
+	}
+	norm, err := normalizer.NewFromParams(params)
+	if err != nil {
+		return stateBase{}, err
+	}
+	interval, err := newIntervalEstimatorFromParams(params)
+	if err != nil {
+		return stateBase{}, err
+	}
+	targets, err := targetsFromParams(params)
+	if err != nil {
+		return stateBase{}, err
+	}
+	if targets != nil && interval != nil {
+		return stateBase{}, errors.New("prediction_interval parameter cannot be used with targets parameter")
+	}
+	return stateBase{
+		valueField:         value,
+		featureVectorField: fv,
+		converter:          conv,
+		normalizer:         norm,
+		interval:           interval,
+		targets:            targets,
+	}, nil
+}
+
+// targetsFromParams extracts targets parameter, which is an array of names of
+// targets. It returns nil when the parameter isn't given.
+func targetsFromParams(params data.Map) ([]string, error) {
+	v, ok := params["targets"]
+	if !ok {
+		return nil, nil
+	}
+	a, err := data.AsArray(v)
+	if err != nil {
+		return nil, fmt.Errorf("targets parameter must be an array: %v", err)
+	}
+	if len(a) == 0 {
+		return nil, errors.New("targets parameter must not be empty")
+	}
+
+	targets := make([]string, len(a))
+	seen := make(map[string]struct{}, len(a))
+	for i, t := range a {
+		name, err := data.AsString(t)
+		if err != nil {
+			return nil, fmt.Errorf("targets[%v] must be a string: %v", i, err)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("targets[%v] must not be empty", i)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("targets has a duplicated name: %v", name)
+		}
+		seen[name] = struct{}{}
+		targets[i] = name
+	}
+	return targets, nil
+}
+
+// numModels returns the number of models the state has.
+func (s *stateBase) numModels() int {
+	if s.targets == nil {
+		return 1
+	}
+	return len(s.targets)
+}
+
+func newStateBaseFromMsgpack(d *stateMsgpack) stateBase {
+	return stateBase{
+		valueField:         d.ValueField,
+		featureVectorField: d.FeatureVectorField,
+	}
+}
+
+func (s *stateBase) toMsgpack() *stateMsgpack {
+	return &stateMsgpack{
+		ValueField:         s.valueField,
+		FeatureVectorField: s.featureVectorField,
+	}
+}
+
+// train trains models with a tuple written to the state. rs has a model for
+// each target, or a single model when the state doesn't have targets.
+// Statistics of residuals are updated with the estimate made before training
+// when the state computes prediction intervals.
+func (s *stateBase) train(rs []regressor, t *core.Tuple) error {
+	vval, ok := t.Data[s.valueField]
+	if !ok {
+		return fmt.Errorf("%s field is missing", s.valueField)
+	}
+	vals, err := s.values(vval)
+	if err != nil {
+		return err
+	}
+
+	vfv, ok := t.Data[s.featureVectorField]
+	if !ok {
+		return fmt.Errorf("%s field is missing", s.featureVectorField)
+	}
+	m, err := data.AsMap(vfv)
+	if err != nil {
+		return fmt.Errorf("%s value is not a map: %v", s.featureVectorField, err)
+	}
+	fv, err := s.featureVector(m, true)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range rs {
+		val, ok := vals[i]
+		if !ok {
+			continue
+		}
+		if s.interval != nil {
+			est, err := r.Estimate(fv)
+			if err != nil {
+				return err
+			}
+			s.interval.add(est, val)
+		}
+		if err := r.Train(fv, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// values extracts values of targets from the value of valueField. It returns
+// a map from indices of targets to values. The value must be a map from names
+// of targets to values when the state has targets, and targets missing in the
+// map aren't trained.
+func (s *stateBase) values(v data.Value) (map[int]float32, error) {
+	if s.targets == nil {
+		val, err := data.ToFloat(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s cannot be converted to float: %v", s.valueField, err)
+		}
+		return map[int]float32{0: float32(val)}, nil
+	}
+
+	m, err := data.AsMap(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s value is not a map: %v", s.valueField, err)
+	}
+	vals := make(map[int]float32, len(m))
+	for i, name := range s.targets {
+		x, ok := m[name]
+		if !ok {
+			continue
+		}
+		val, err := data.ToFloat(x)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s cannot be converted to float: %v", s.valueField, name, err)
+		}
+		vals[i] = float32(val)
+	}
+	if len(vals) != len(m) {
+		for name := range m {
+			if !s.hasTarget(name) {
+				return nil, fmt.Errorf("%s has an unknown target: %v", s.valueField, name)
+			}
+		}
+	}
+	return vals, nil
+}
+
+func (s *stateBase) hasTarget(name string) bool {
+	for _, t := range s.targets {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// estimate estimates a value from a raw feature vector, which isn't converted
+// or normalized yet.
+func (s *stateBase) estimate(r regressor, v data.Map) (float32, error) {
+	fv, err := s.featureVector(v, false)
+	if err != nil {
+		return 0, err
+	}
+	return r.Estimate(fv)
+}
+
+// estimateTargets estimates values of all targets from a raw feature vector.
+// It returns a map from names of targets to estimates. The feature vector is
+// converted only once and shared by models of targets.
+func (s *stateBase) estimateTargets(rs []regressor, v data.Map) (data.Map, error) {
+	fv, err := s.featureVector(v, false)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(data.Map, len(rs))
+	for i, r := range rs {
+		est, err := r.Estimate(fv)
+		if err != nil {
+			return nil, err
+		}
+		ret[s.targets[i]] = data.Float(est)
+	}
+	return ret, nil
+}
+
+// featureVector converts a value into a feature vector with the converter
+// and the normalizer of the state.
+func (s *stateBase) featureVector(v data.Map, training bool) (FeatureVector, error) {
+	fv, err := pipeline.FeatureVector(s.converter, s.normalizer, v, training)
+	if err != nil {
+		return nil, err
+	}
+	return FeatureVector(fv), nil
+}
+
+// regressor is a regression model.
+type regressor interface {
+	Train(v FeatureVector, value float32) error
+	Estimate(v FeatureVector) (float32, error)
+}
+
+// estimator is a state of a regression algorithm.
+type estimator interface {
+	base() *stateBase
+
+	// regressors returns a model for each target, or a single model when the
+	// state doesn't have targets.
+	regressors() []regressor
+}
+
+func (s *stateBase) base() *stateBase {
+	return s
+}
+
+func lookupEstimator(ctx *core.Context, stateName string) (estimator, error) {
+	st, err := ctx.SharedStates.Get(stateName)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := st.(estimator)
+	if !ok {
+		return nil, fmt.Errorf("state '%v' isn't a regression state", stateName)
+	}
+	return s, nil
+}
+
+// PassiveAggressiveEstimate estimates a value with the model of the state
+// having stateName. It works with states of all regression algorithms, but
+// doesn't support states having multiple targets. Use Estimate for them.
+func PassiveAggressiveEstimate(ctx *core.Context, stateName string, featureVector data.Map) (float32, error) {
+	s, err := lookupEstimator(ctx, stateName)
+	if err != nil {
+		return 0, err
+	}
+	if s.base().targets != nil {
+		return 0, fmt.Errorf("state '%v' has multiple targets", stateName)
+	}
+	return s.base().estimate(s.regressors()[0], featureVector)
+}
+
+// Estimate estimates a value with the model of the state having stateName.
+// When the state has targets, it returns a map from names of targets to
+// estimates. Otherwise, it returns the estimate as a float.
+func Estimate(ctx *core.Context, stateName string, featureVector data.Map) (data.Value, error) {
+	s, err := lookupEstimator(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+	b := s.base()
+	if b.targets != nil {
+		return b.estimateTargets(s.regressors(), featureVector)
+	}
+	est, err := b.estimate(s.regressors()[0], featureVector)
+	if err != nil {
+		return nil, err
+	}
+	return data.Float(est), nil
+}
@@ -0,0 +1,199 @@
+package regression
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"testing"
+)
+
+func TestMultiTargetState(t *testing.T) {
+	ctx := core.NewContext(nil)
+	targets := data.Array{data.String("price"), data.String("cost")}
+	creators := map[string]struct {
+		c      udsCreator
+		params data.Map
+	}{
+		"PassiveAggressiveState": {&PassiveAggressiveStateCreator{Method: PA1}, data.Map{
+			"regularization_weight": data.Float(1),
+			"sensitivity":           data.Float(0),
+			"targets":               targets,
+		}},
+		"NearestNeighborState": {&NearestNeighborStateCreator{}, data.Map{
+			"nearest_neighbor_algorithm": data.String("euclid_lsh"),
+			"hash_num":                   data.Int(64),
+			"nearest_neighbor_num":       data.Int(1),
+			"targets":                    targets,
+		}},
+	}
+
+	for name, cr := range creators {
+		cr := cr
+		Convey("Given a "+name+" having multiple targets", t, func() {
+			s, err := cr.c.CreateState(ctx, cr.params)
+			So(err, ShouldBeNil)
+			So(ctx.SharedStates.Add("multi", "jubaregression", s), ShouldBeNil)
+			Reset(func() {
+				ctx.SharedStates.Remove("multi")
+			})
+			w := s.(savableWriter)
+
+			for i := 0; i < 100; i++ {
+				f, x := "a", 1.0
+				if i%2 == 1 {
+					f, x = "b", 2.0
+				}
+				So(w.Write(ctx, &core.Tuple{
+					Data: data.Map{
+						"value": data.Map{
+							"price": data.Float(2 * x),
+							"cost":  data.Float(-x),
+						},
+						"feature_vector": data.Map{f: data.Float(1)},
+					},
+				}), ShouldBeNil)
+			}
+
+			Convey("when estimating values", func() {
+				v, err := Estimate(ctx, "multi", data.Map{"b": data.Float(1)})
+				So(err, ShouldBeNil)
+
+				Convey("it should return estimates of all targets", func() {
+					m, err := data.AsMap(v)
+					So(err, ShouldBeNil)
+					So(len(m), ShouldEqual, 2)
+					price, _ := data.AsFloat(m["price"])
+					cost, _ := data.AsFloat(m["cost"])
+					So(price, ShouldAlmostEqual, 4, 0.1)
+					So(cost, ShouldAlmostEqual, -2, 0.1)
+				})
+			})
+
+			Convey("when writing a tuple having a part of targets", func() {
+				err := w.Write(ctx, &core.Tuple{
+					Data: data.Map{
+						"value":          data.Map{"price": data.Float(1)},
+						"feature_vector": data.Map{"x": data.Float(1)},
+					},
+				})
+
+				Convey("it should succeed", func() {
+					So(err, ShouldBeNil)
+				})
+			})
+
+			Convey("when writing a tuple having an unknown target", func() {
+				err := w.Write(ctx, &core.Tuple{
+					Data: data.Map{
+						"value":          data.Map{"unknown": data.Float(1)},
+						"feature_vector": data.Map{"x": data.Float(1)},
+					},
+				})
+
+				Convey("it should fail", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("when writing a tuple having a single value", func() {
+				err := w.Write(ctx, &core.Tuple{
+					Data: data.Map{
+						"value":          data.Float(1),
+						"feature_vector": data.Map{"x": data.Float(1)},
+					},
+				})
+
+				Convey("it should fail", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("estimating a single value should fail", func() {
+				_, err := PassiveAggressiveEstimate(ctx, "multi", data.Map{"x": data.Float(1)})
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("when saving and loading it", func() {
+				buf := bytes.NewBuffer(nil)
+				So(w.Save(ctx, buf, data.Map{}), ShouldBeNil)
+				s2, err := cr.c.LoadState(ctx, buf, data.Map{})
+				So(err, ShouldBeNil)
+				So(ctx.SharedStates.Add("multi2", "jubaregression", s2), ShouldBeNil)
+				Reset(func() {
+					ctx.SharedStates.Remove("multi2")
+				})
+
+				Convey("it should estimate the same values", func() {
+					fv := data.Map{"a": data.Float(1)}
+					v, err := Estimate(ctx, "multi", fv)
+					So(err, ShouldBeNil)
+					v2, err := Estimate(ctx, "multi2", fv)
+					So(err, ShouldBeNil)
+					So(v2, ShouldResemble, v)
+				})
+			})
+		})
+	}
+
+	Convey("Given a single target state", t, func() {
+		s, err := (&PassiveAggressiveStateCreator{}).CreateState(ctx, data.Map{
+			"regularization_weight": data.Float(1),
+			"sensitivity":           data.Float(0),
+		})
+		So(err, ShouldBeNil)
+		So(ctx.SharedStates.Add("single", "jubaregression_pa", s), ShouldBeNil)
+		Reset(func() {
+			ctx.SharedStates.Remove("single")
+		})
+
+		Convey("estimating a value should return a float", func() {
+			v, err := Estimate(ctx, "single", data.Map{"x": data.Float(1)})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, data.Float(0))
+		})
+	})
+
+	Convey("Given invalid targets parameters", t, func() {
+		cases := map[string]data.Value{
+			"not an array":    data.String("price"),
+			"empty array":     data.Array{},
+			"non-string name": data.Array{data.Int(1)},
+			"empty name":      data.Array{data.String("")},
+			"duplicated name": data.Array{data.String("a"), data.String("a")},
+		}
+
+		for name, v := range cases {
+			v := v
+			Convey("creating a state with "+name+" should fail", func() {
+				_, err := (&PassiveAggressiveStateCreator{}).CreateState(ctx, data.Map{
+					"regularization_weight": data.Float(1),
+					"sensitivity":           data.Float(0),
+					"targets":               v,
+				})
+				So(err, ShouldNotBeNil)
+			})
+		}
+
+		Convey("creating a state with prediction_interval should fail", func() {
+			_, err := (&PassiveAggressiveStateCreator{}).CreateState(ctx, data.Map{
+				"regularization_weight": data.Float(1),
+				"sensitivity":           data.Float(0),
+				"targets":               data.Array{data.String("a")},
+				"prediction_interval":   data.Map{},
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+type udsCreator interface {
+	CreateState(ctx *core.Context, params data.Map) (core.SharedState, error)
+	LoadState(ctx *core.Context, r io.Reader, params data.Map) (core.SharedState, error)
+}
+
+type savableWriter interface {
+	core.SavableSharedState
+	core.Writer
+}
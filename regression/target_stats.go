@@ -0,0 +1,101 @@
+package regression
+
+import (
+	"math"
+)
+
+// targetStats has statistics of target values, which are used to compute the
+// standard deviation for the sensitivity band of PassiveAggressive.
+//
+// Statistics are computed in one of the following modes:
+//
+//	cumulative: all values have the same weight (Decay == 0, WindowSize == 0)
+//	decay: the weight of past values is multiplied by 1 - Decay every time a
+//	       new value is added (Decay > 0)
+//	window: only the last WindowSize values are used (WindowSize > 0)
+type targetStats struct {
+	_struct struct{} `codec:",toarray"`
+
+	Decay      float64
+	WindowSize int
+
+	Sum   float64
+	SqSum float64
+
+	// Weight is the sum of weights of values. It's the number of values
+	// unless Decay is positive.
+	Weight float64
+
+	// Window is a ring buffer of the last values in the window mode. Pos is
+	// the index to which the next value is written.
+	Window []float64
+	Pos    int
+}
+
+func (s *targetStats) add(x float64) {
+	switch {
+	case s.WindowSize > 0:
+		if len(s.Window) < s.WindowSize {
+			s.Window = append(s.Window, x)
+			s.Sum += x
+			s.SqSum += x * x
+			s.Weight++
+		} else {
+			old := s.Window[s.Pos]
+			s.Window[s.Pos] = x
+			s.Sum += x - old
+			s.SqSum += x*x - old*old
+		}
+		s.Pos = (s.Pos + 1) % s.WindowSize
+		if s.Pos == 0 {
+			// Recompute sums once per round so that rounding errors of
+			// subtractions don't accumulate.
+			s.recompute()
+		}
+
+	case s.Decay > 0:
+		r := 1 - s.Decay
+		s.Sum = s.Sum*r + x
+		s.SqSum = s.SqSum*r + x*x
+		s.Weight = s.Weight*r + 1
+
+	default:
+		s.Sum += x
+		s.SqSum += x * x
+		s.Weight++
+	}
+}
+
+func (s *targetStats) recompute() {
+	s.Sum = 0
+	s.SqSum = 0
+	for _, x := range s.Window {
+		s.Sum += x
+		s.SqSum += x * x
+	}
+	s.Weight = float64(len(s.Window))
+}
+
+func (s *targetStats) stdDev() float64 {
+	if s.Weight == 0 {
+		return 0
+	}
+	avg := s.Sum / s.Weight
+	v := s.SqSum/s.Weight - avg*avg
+	if v < 0 {
+		// It can be negative by rounding errors.
+		return 0
+	}
+	return math.Sqrt(v)
+}
+
+func (s *targetStats) clear() {
+	*s = targetStats{
+		Decay:      s.Decay,
+		WindowSize: s.WindowSize,
+	}
+}
+
+func (s *targetStats) sameMode(t *targetStats) bool {
+	return s.Decay == t.Decay && s.WindowSize == t.WindowSize
+}
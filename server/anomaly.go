@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"github.com/zeromberto/jubatus/anomaly"
+	"io"
+	"sync"
+)
+
+// NewAnomaly creates a server serving a LightLOF model with the API of
+// jubaanomaly. It supports add, calc_score, save, load, clear, get_config and
+// get_status. Models are saved in dir.
+func NewAnomaly(l *anomaly.LightLOF, dir string) *Server {
+	return newServer("anomaly", &anomalyModel{lof: l}, dir)
+}
+
+type anomalyModel struct {
+	m   sync.RWMutex
+	lof *anomaly.LightLOF
+}
+
+func (a *anomalyModel) model() *anomaly.LightLOF {
+	a.m.RLock()
+	defer a.m.RUnlock()
+	return a.lof
+}
+
+func (a *anomalyModel) methods() map[string]method {
+	return map[string]method{
+		"add":        a.add,
+		"calc_score": a.calcScore,
+	}
+}
+
+// add adds a datum to the model. It returns id_with_score, which is a pair of
+// the ID of the added row and its score.
+func (a *anomalyModel) add(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 1); err != nil {
+		return nil, err
+	}
+	fv, err := toDatumFeatureVector(params[0])
+	if err != nil {
+		return nil, err
+	}
+	id, score, err := a.model().AddWithID(anomaly.FeatureVector(fv))
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{fmt.Sprint(id), float64(score)}, nil
+}
+
+// calcScore calculates the score of a datum without adding it.
+func (a *anomalyModel) calcScore(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 1); err != nil {
+		return nil, err
+	}
+	fv, err := toDatumFeatureVector(params[0])
+	if err != nil {
+		return nil, err
+	}
+	score, err := a.model().CalcScore(anomaly.FeatureVector(fv))
+	if err != nil {
+		return nil, err
+	}
+	return float64(score), nil
+}
+
+func (a *anomalyModel) save(w io.Writer) error {
+	return a.model().Save(w)
+}
+
+func (a *anomalyModel) load(r io.Reader) error {
+	l, err := anomaly.LoadLightLOF(r)
+	if err != nil {
+		return err
+	}
+	a.m.Lock()
+	defer a.m.Unlock()
+	a.lof = l
+	return nil
+}
+
+func (a *anomalyModel) clear() {
+	a.model().Clear()
+}
+
+func (a *anomalyModel) config() map[string]interface{} {
+	return map[string]interface{}{
+		"method": "light_lof",
+	}
+}
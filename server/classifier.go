@@ -0,0 +1,173 @@
+package server
+
+import (
+	"github.com/zeromberto/jubatus/classifier"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io"
+	"sort"
+	"sync"
+)
+
+// NewClassifier creates a server serving an AROW model with the API of
+// jubaclassifier. It supports train, classify, set_label, delete_label, save,
+// load, clear, get_config and get_status. Models are saved in dir.
+func NewClassifier(a *classifier.AROW, dir string) *Server {
+	return newServer("classifier", &classifierModel{arow: a}, dir)
+}
+
+type classifierModel struct {
+	m    sync.RWMutex
+	arow *classifier.AROW
+}
+
+func (c *classifierModel) model() *classifier.AROW {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	return c.arow
+}
+
+func (c *classifierModel) methods() map[string]method {
+	return map[string]method{
+		"train":        c.train,
+		"classify":     c.classify,
+		"set_label":    c.setLabel,
+		"delete_label": c.deleteLabel,
+	}
+}
+
+// train trains the model with an array of labeled_datum, which is a pair of
+// a label and a datum. It returns the number of trained data.
+func (c *classifierModel) train(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 1); err != nil {
+		return nil, err
+	}
+	ds, err := toArray(params[0])
+	if err != nil {
+		return nil, newArgumentError("data must be an array: %v", err)
+	}
+
+	type labeledDatum struct {
+		label classifier.Label
+		fv    classifier.FeatureVector
+	}
+	lds := make([]labeledDatum, len(ds))
+	for i, d := range ds {
+		p, err := toPair(d)
+		if err != nil {
+			return nil, newArgumentError("labeled_datum is invalid: %v", err)
+		}
+		l, err := toString(p[0])
+		if err != nil {
+			return nil, newArgumentError("label is invalid: %v", err)
+		}
+		fv, err := toDatumFeatureVector(p[1])
+		if err != nil {
+			return nil, err
+		}
+		lds[i] = labeledDatum{classifier.Label(l), classifier.FeatureVector(fv)}
+	}
+
+	a := c.model()
+	for i, ld := range lds {
+		if err := a.Train(ld.fv, ld.label); err != nil {
+			return i, err
+		}
+	}
+	return len(lds), nil
+}
+
+// classify classifies an array of datum. It returns an array of arrays of
+// estimate_result, which is a pair of a label and its score. Results are
+// sorted by labels.
+func (c *classifierModel) classify(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 1); err != nil {
+		return nil, err
+	}
+	ds, err := toArray(params[0])
+	if err != nil {
+		return nil, newArgumentError("data must be an array: %v", err)
+	}
+	fvs := make([]classifier.FeatureVector, len(ds))
+	for i, d := range ds {
+		fv, err := toDatumFeatureVector(d)
+		if err != nil {
+			return nil, err
+		}
+		fvs[i] = classifier.FeatureVector(fv)
+	}
+
+	a := c.model()
+	res := make([]interface{}, len(fvs))
+	for i, fv := range fvs {
+		scores, err := a.Classify(fv)
+		if err != nil {
+			return nil, err
+		}
+		labels := make([]string, 0, len(scores))
+		for l := range scores {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+
+		rs := make([]interface{}, len(labels))
+		for j, l := range labels {
+			s, err := data.AsFloat(scores[l])
+			if err != nil {
+				return nil, err
+			}
+			rs[j] = []interface{}{l, s}
+		}
+		res[i] = rs
+	}
+	return res, nil
+}
+
+func (c *classifierModel) setLabel(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 1); err != nil {
+		return nil, err
+	}
+	l, err := toString(params[0])
+	if err != nil {
+		return nil, newArgumentError("label must be a string: %v", err)
+	}
+	return c.model().SetLabel(classifier.Label(l))
+}
+
+func (c *classifierModel) deleteLabel(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 1); err != nil {
+		return nil, err
+	}
+	l, err := toString(params[0])
+	if err != nil {
+		return nil, newArgumentError("label must be a string: %v", err)
+	}
+	return c.model().DeleteLabel(classifier.Label(l)), nil
+}
+
+func (c *classifierModel) save(w io.Writer) error {
+	return c.model().Save(w)
+}
+
+func (c *classifierModel) load(r io.Reader) error {
+	a, err := classifier.LoadAROW(r)
+	if err != nil {
+		return err
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.arow = a
+	return nil
+}
+
+func (c *classifierModel) clear() {
+	c.model().Clear()
+}
+
+func (c *classifierModel) config() map[string]interface{} {
+	return map[string]interface{}{
+		"method": "AROW",
+		"parameter": map[string]interface{}{
+			"regularization_weight": c.model().RegWeight(),
+		},
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// datum is the data structure of Jubatus. It's encoded as an array of
+// string_values, num_values and binary_values, each of which is an array of
+// [key, value] pairs.
+type datum struct {
+	stringValues [][2]string
+	numValues    []numValue
+}
+
+type numValue struct {
+	key   string
+	value float64
+}
+
+// featureVector converts a datum to a feature vector in the same way as the
+// default fv_converter of Jubatus does: a string value becomes a binary
+// feature named "<key>$<value>@str#bin/bin" and a numeric value becomes a
+// feature named "<key>@num". binary_values are ignored.
+func (d *datum) featureVector() data.Map {
+	fv := make(data.Map, len(d.stringValues)+len(d.numValues))
+	for _, kv := range d.stringValues {
+		fv[fmt.Sprintf("%s$%s@str#bin/bin", kv[0], kv[1])] = data.Float(1)
+	}
+	for _, kv := range d.numValues {
+		key := kv.key + "@num"
+		if v, ok := fv[key]; ok {
+			f, _ := data.AsFloat(v)
+			fv[key] = data.Float(f + kv.value)
+		} else {
+			fv[key] = data.Float(kv.value)
+		}
+	}
+	return fv
+}
+
+func toDatum(v interface{}) (*datum, error) {
+	a, err := toArray(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(a) < 2 || len(a) > 3 {
+		return nil, fmt.Errorf("datum must have 2 or 3 fields but has %v", len(a))
+	}
+
+	d := &datum{}
+	svs, err := toArray(a[0])
+	if err != nil {
+		return nil, fmt.Errorf("string_values of datum is invalid: %v", err)
+	}
+	for _, sv := range svs {
+		kv, err := toPair(sv)
+		if err != nil {
+			return nil, fmt.Errorf("string_values of datum is invalid: %v", err)
+		}
+		k, err := toString(kv[0])
+		if err != nil {
+			return nil, fmt.Errorf("string_values of datum is invalid: %v", err)
+		}
+		v, err := toString(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("string_values of datum is invalid: %v", err)
+		}
+		d.stringValues = append(d.stringValues, [2]string{k, v})
+	}
+
+	nvs, err := toArray(a[1])
+	if err != nil {
+		return nil, fmt.Errorf("num_values of datum is invalid: %v", err)
+	}
+	for _, nv := range nvs {
+		kv, err := toPair(nv)
+		if err != nil {
+			return nil, fmt.Errorf("num_values of datum is invalid: %v", err)
+		}
+		k, err := toString(kv[0])
+		if err != nil {
+			return nil, fmt.Errorf("num_values of datum is invalid: %v", err)
+		}
+		v, err := toFloat(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("num_values of datum is invalid: %v", err)
+		}
+		d.numValues = append(d.numValues, numValue{key: k, value: v})
+	}
+	return d, nil
+}
+
+func toDatumFeatureVector(v interface{}) (data.Map, error) {
+	d, err := toDatum(v)
+	if err != nil {
+		return nil, newArgumentError("%v", err)
+	}
+	return d.featureVector(), nil
+}
+
+func toPair(v interface{}) ([]interface{}, error) {
+	a, err := toArray(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(a) != 2 {
+		return nil, fmt.Errorf("a pair must have 2 elements but has %v", len(a))
+	}
+	return a, nil
+}
+
+func toArray(v interface{}) ([]interface{}, error) {
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%T isn't an array", v)
+	}
+	return a, nil
+}
+
+func toString(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case []byte:
+		return string(s), nil
+	default:
+		return "", fmt.Errorf("%T isn't a string", v)
+	}
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case uint64:
+		return float64(x), nil
+	default:
+		return 0, fmt.Errorf("%T isn't a number", v)
+	}
+}
+
+func toInt(v interface{}) (int64, error) {
+	switch x := v.(type) {
+	case int64:
+		return x, nil
+	case uint64:
+		return int64(x), nil
+	default:
+		return 0, fmt.Errorf("%T isn't an integer", v)
+	}
+}
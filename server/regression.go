@@ -0,0 +1,133 @@
+package server
+
+import (
+	"github.com/zeromberto/jubatus/regression"
+	"io"
+	"sync"
+)
+
+// NewRegression creates a server serving a PassiveAggressive model with the
+// API of jubaregression. It supports train, estimate, save, load, clear,
+// get_config and get_status. Models are saved in dir.
+func NewRegression(pa *regression.PassiveAggressive, dir string) *Server {
+	return newServer("regression", &regressionModel{pa: pa}, dir)
+}
+
+type regressionModel struct {
+	m  sync.RWMutex
+	pa *regression.PassiveAggressive
+}
+
+func (r *regressionModel) model() *regression.PassiveAggressive {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.pa
+}
+
+func (r *regressionModel) methods() map[string]method {
+	return map[string]method{
+		"train":    r.train,
+		"estimate": r.estimate,
+	}
+}
+
+// train trains the model with an array of scored_datum, which is a pair of a
+// value and a datum. It returns the number of trained data.
+func (r *regressionModel) train(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 1); err != nil {
+		return nil, err
+	}
+	ds, err := toArray(params[0])
+	if err != nil {
+		return nil, newArgumentError("data must be an array: %v", err)
+	}
+
+	type scoredDatum struct {
+		value float32
+		fv    regression.FeatureVector
+	}
+	sds := make([]scoredDatum, len(ds))
+	for i, d := range ds {
+		p, err := toPair(d)
+		if err != nil {
+			return nil, newArgumentError("scored_datum is invalid: %v", err)
+		}
+		v, err := toFloat(p[0])
+		if err != nil {
+			return nil, newArgumentError("score is invalid: %v", err)
+		}
+		fv, err := toDatumFeatureVector(p[1])
+		if err != nil {
+			return nil, err
+		}
+		sds[i] = scoredDatum{float32(v), regression.FeatureVector(fv)}
+	}
+
+	pa := r.model()
+	for i, sd := range sds {
+		if err := pa.Train(sd.fv, sd.value); err != nil {
+			return i, err
+		}
+	}
+	return len(sds), nil
+}
+
+// estimate estimates values of an array of datum.
+func (r *regressionModel) estimate(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 1); err != nil {
+		return nil, err
+	}
+	ds, err := toArray(params[0])
+	if err != nil {
+		return nil, newArgumentError("data must be an array: %v", err)
+	}
+	fvs := make([]regression.FeatureVector, len(ds))
+	for i, d := range ds {
+		fv, err := toDatumFeatureVector(d)
+		if err != nil {
+			return nil, err
+		}
+		fvs[i] = regression.FeatureVector(fv)
+	}
+
+	pa := r.model()
+	res := make([]interface{}, len(fvs))
+	for i, fv := range fvs {
+		v, err := pa.Estimate(fv)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = float64(v)
+	}
+	return res, nil
+}
+
+func (r *regressionModel) save(w io.Writer) error {
+	return r.model().Save(w)
+}
+
+func (r *regressionModel) load(rd io.Reader) error {
+	pa, err := regression.LoadPassiveAggressive(rd)
+	if err != nil {
+		return err
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.pa = pa
+	return nil
+}
+
+func (r *regressionModel) clear() {
+	r.model().Clear()
+}
+
+func (r *regressionModel) config() map[string]interface{} {
+	pa := r.model()
+	return map[string]interface{}{
+		"method": "PA",
+		"parameter": map[string]interface{}{
+			"regularization_weight": pa.RegWeight(),
+			"sensitivity":           pa.Sensitivity(),
+		},
+	}
+}
@@ -0,0 +1,352 @@
+// Package server provides msgpack-RPC servers compatible with the API of the
+// original Jubatus servers, so that clients written for Jubatus can use models
+// of this project without changes.
+//
+// Each server serves one model. Like a standalone Jubatus server, it ignores
+// the name parameter which every method of Jubatus has as its first argument.
+// Models are saved in the format of this project, not in the format of
+// Jubatus.
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	rpcRequest      = 0
+	rpcResponse     = 1
+	rpcNotification = 2
+
+	// Error codes defined by msgpack-RPC implementation of Jubatus.
+	rpcNoMethodError = 1
+	rpcArgumentError = 2
+)
+
+var msgpackHandle = &codec.MsgpackHandle{}
+
+func init() {
+	msgpackHandle.RawToString = true
+}
+
+// model is a model served by a Server.
+type model interface {
+	// methods returns methods specific to the type of the model.
+	methods() map[string]method
+
+	save(w io.Writer) error
+	load(r io.Reader) error
+	clear()
+
+	// config returns parameters of the model. It's returned as a JSON by
+	// get_config.
+	config() map[string]interface{}
+}
+
+// method is a method called by clients. params doesn't have the name
+// parameter.
+type method func(params []interface{}) (interface{}, error)
+
+// Server is a msgpack-RPC server which serves a model.
+type Server struct {
+	typ     string
+	model   model
+	dir     string
+	methods map[string]method
+
+	m        sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func newServer(typ string, m model, dir string) *Server {
+	s := &Server{
+		typ:   typ,
+		model: m,
+		dir:   dir,
+		conns: map[net.Conn]struct{}{},
+	}
+	s.methods = map[string]method{
+		"save":       s.save,
+		"load":       s.load,
+		"clear":      s.clear,
+		"get_config": s.getConfig,
+		"get_status": s.getStatus,
+	}
+	for name, f := range m.methods() {
+		s.methods[name] = f
+	}
+	return s
+}
+
+// Serve accepts connections on l and serves the model to them. It blocks
+// until Close is called or l fails, and always returns a non-nil error.
+func (s *Server) Serve(l net.Listener) error {
+	s.m.Lock()
+	if s.closed {
+		s.m.Unlock()
+		return errors.New("the server is already closed")
+	}
+	if s.listener != nil {
+		s.m.Unlock()
+		return errors.New("the server is already serving")
+	}
+	s.listener = l
+	s.m.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		s.m.Lock()
+		if s.closed {
+			s.m.Unlock()
+			conn.Close()
+			return errors.New("the server is closed")
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.m.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops the server and closes all connections. It waits for the
+// methods being called to return.
+func (s *Server) Close() error {
+	s.m.Lock()
+	if s.closed {
+		s.m.Unlock()
+		return nil
+	}
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.m.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.m.Lock()
+		delete(s.conns, conn)
+		s.m.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+
+	dec := codec.NewDecoder(bufio.NewReader(conn), msgpackHandle)
+	w := bufio.NewWriter(conn)
+	enc := codec.NewEncoder(w, msgpackHandle)
+	for {
+		var msg []interface{}
+		if err := dec.Decode(&msg); err != nil {
+			return
+		}
+		if len(msg) == 0 {
+			return
+		}
+		typ, err := toInt(msg[0])
+		if err != nil {
+			return
+		}
+
+		switch {
+		case typ == rpcRequest && len(msg) == 4:
+			res, rpcErr := s.call(msg[2], msg[3])
+			if err := enc.Encode([]interface{}{rpcResponse, msg[1], rpcErr, res}); err != nil {
+				return
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+		case typ == rpcNotification && len(msg) == 3:
+			s.call(msg[1], msg[2])
+
+		default:
+			// The connection is broken.
+			return
+		}
+	}
+}
+
+// call calls a method and returns its result or an error object of
+// msgpack-RPC.
+func (s *Server) call(name, params interface{}) (interface{}, interface{}) {
+	n, err := toString(name)
+	if err != nil {
+		return nil, rpcNoMethodError
+	}
+	f, ok := s.methods[n]
+	if !ok {
+		return nil, rpcNoMethodError
+	}
+
+	// The first parameter is the name of the model, which isn't used.
+	ps, err := toArray(params)
+	if err != nil || len(ps) == 0 {
+		return nil, rpcArgumentError
+	}
+	if _, err := toString(ps[0]); err != nil {
+		return nil, rpcArgumentError
+	}
+
+	res, err := f(ps[1:])
+	if err != nil {
+		if _, ok := err.(*argumentError); ok {
+			return nil, rpcArgumentError
+		}
+		return nil, err.Error()
+	}
+	return res, nil
+}
+
+// argumentError is returned by methods when parameters are invalid.
+type argumentError struct {
+	msg string
+}
+
+func (e *argumentError) Error() string {
+	return e.msg
+}
+
+func newArgumentError(format string, args ...interface{}) error {
+	return &argumentError{msg: fmt.Sprintf(format, args...)}
+}
+
+// checkParams checks the number of parameters.
+func checkParams(params []interface{}, n int) error {
+	if len(params) != n {
+		return newArgumentError("%v parameters are required but %v were given", n+1, len(params)+1)
+	}
+	return nil
+}
+
+// id returns the identifier of the server used in results of save and
+// get_status, which is "<host>_<port>" like Jubatus.
+func (s *Server) id() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	host, port, err := net.SplitHostPort(s.listener.Addr().String())
+	if err != nil {
+		return s.listener.Addr().String()
+	}
+	return host + "_" + port
+}
+
+// modelPath returns the path of the file of a model saved with the given id.
+func (s *Server) modelPath(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", newArgumentError("invalid model id: %v", id)
+	}
+	return filepath.Join(s.dir, fmt.Sprintf("%v_%v_%v.jubatus", s.id(), s.typ, id)), nil
+}
+
+func (s *Server) save(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 1); err != nil {
+		return nil, err
+	}
+	id, err := toString(params[0])
+	if err != nil {
+		return nil, newArgumentError("id must be a string: %v", err)
+	}
+	path, err := s.modelPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.model.save(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return map[string]interface{}{s.id(): path}, nil
+}
+
+func (s *Server) load(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 1); err != nil {
+		return nil, err
+	}
+	id, err := toString(params[0])
+	if err != nil {
+		return nil, newArgumentError("id must be a string: %v", err)
+	}
+	path, err := s.modelPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := s.model.load(bufio.NewReader(f)); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+func (s *Server) clear(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 0); err != nil {
+		return nil, err
+	}
+	s.model.clear()
+	return true, nil
+}
+
+func (s *Server) getConfig(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 0); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(s.model.config())
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (s *Server) getStatus(params []interface{}) (interface{}, error) {
+	if err := checkParams(params, 0); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		s.id(): map[string]interface{}{
+			"type":    s.typ,
+			"datadir": s.dir,
+		},
+	}, nil
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"bufio"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/ugorji/go/codec"
+	"github.com/zeromberto/jubatus/anomaly"
+	"github.com/zeromberto/jubatus/classifier"
+	"github.com/zeromberto/jubatus/regression"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+)
+
+// client is a minimal msgpack-RPC client.
+type client struct {
+	conn  net.Conn
+	dec   *codec.Decoder
+	enc   *codec.Encoder
+	w     *bufio.Writer
+	msgID uint32
+}
+
+func newClient(addr string) (*client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(conn)
+	return &client{
+		conn: conn,
+		dec:  codec.NewDecoder(bufio.NewReader(conn), msgpackHandle),
+		enc:  codec.NewEncoder(w, msgpackHandle),
+		w:    w,
+	}, nil
+}
+
+// call calls a method with the name parameter and returns its result and
+// error object.
+func (c *client) call(method string, params ...interface{}) (interface{}, interface{}) {
+	c.msgID++
+	ps := append([]interface{}{"test"}, params...)
+	So(c.enc.Encode([]interface{}{rpcRequest, c.msgID, method, ps}), ShouldBeNil)
+	So(c.w.Flush(), ShouldBeNil)
+
+	var res []interface{}
+	So(c.dec.Decode(&res), ShouldBeNil)
+	So(len(res), ShouldEqual, 4)
+	id, err := toInt(res[1])
+	So(err, ShouldBeNil)
+	So(id, ShouldEqual, c.msgID)
+	return res[3], res[2]
+}
+
+func startServer(s *Server) (*client, func()) {
+	l, err := net.Listen("tcp", "localhost:0")
+	So(err, ShouldBeNil)
+	go s.Serve(l)
+
+	c, err := newClient(l.Addr().String())
+	So(err, ShouldBeNil)
+	return c, func() {
+		c.conn.Close()
+		So(s.Close(), ShouldBeNil)
+	}
+}
+
+func newDatum(str map[string]string, num map[string]float64) []interface{} {
+	svs := []interface{}{}
+	for k, v := range str {
+		svs = append(svs, []interface{}{k, v})
+	}
+	nvs := []interface{}{}
+	for k, v := range num {
+		nvs = append(nvs, []interface{}{k, v})
+	}
+	return []interface{}{svs, nvs, []interface{}{}}
+}
+
+func TestDatum(t *testing.T) {
+	Convey("Given a datum", t, func() {
+		d := newDatum(map[string]string{"name": "abc"}, map[string]float64{"age": 20})
+
+		Convey("when converting it to a feature vector", func() {
+			fv, err := toDatumFeatureVector(d)
+
+			Convey("it should have features named like Jubatus", func() {
+				So(err, ShouldBeNil)
+				So(fv, ShouldResemble, data.Map{
+					"name$abc@str#bin/bin": data.Float(1),
+					"age@num":              data.Float(20),
+				})
+			})
+		})
+	})
+
+	Convey("Given an invalid datum", t, func() {
+		d := []interface{}{[]interface{}{[]interface{}{"name", 1.0}}, []interface{}{}}
+
+		Convey("when converting it to a feature vector", func() {
+			_, err := toDatumFeatureVector(d)
+
+			Convey("it should fail with an argument error", func() {
+				So(err, ShouldHaveSameTypeAs, &argumentError{})
+			})
+		})
+	})
+}
+
+func TestClassifierServer(t *testing.T) {
+	Convey("Given a classifier server", t, func() {
+		dir, err := ioutil.TempDir("", "jubatus_server_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		a, err := classifier.NewAROW(1)
+		So(err, ShouldBeNil)
+		c, stop := startServer(NewClassifier(a, dir))
+		defer stop()
+
+		red := newDatum(map[string]string{"color": "red"}, nil)
+		blue := newDatum(map[string]string{"color": "blue"}, nil)
+
+		Convey("when training it", func() {
+			res, rpcErr := c.call("train", []interface{}{
+				[]interface{}{"r", red},
+				[]interface{}{"b", blue},
+			})
+			So(rpcErr, ShouldBeNil)
+
+			Convey("it should return the number of trained data", func() {
+				n, err := toInt(res)
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 2)
+			})
+
+			Convey("it should classify data", func() {
+				res, rpcErr := c.call("classify", []interface{}{red, blue})
+				So(rpcErr, ShouldBeNil)
+				rs, err := toArray(res)
+				So(err, ShouldBeNil)
+				So(len(rs), ShouldEqual, 2)
+
+				for i, l := range []string{"r", "b"} {
+					ers, err := toArray(rs[i])
+					So(err, ShouldBeNil)
+					So(len(ers), ShouldEqual, 2)
+
+					best, bestScore := "", -1.0
+					for _, er := range ers {
+						p, err := toPair(er)
+						So(err, ShouldBeNil)
+						label, err := toString(p[0])
+						So(err, ShouldBeNil)
+						score, err := toFloat(p[1])
+						So(err, ShouldBeNil)
+						if score > bestScore {
+							best, bestScore = label, score
+						}
+					}
+					So(best, ShouldEqual, l)
+				}
+			})
+
+			Convey("it should save and load the model", func() {
+				res, rpcErr := c.call("save", "m1")
+				So(rpcErr, ShouldBeNil)
+				paths, ok := res.(map[interface{}]interface{})
+				So(ok, ShouldBeTrue)
+				So(len(paths), ShouldEqual, 1)
+
+				_, rpcErr = c.call("clear")
+				So(rpcErr, ShouldBeNil)
+				res, rpcErr = c.call("classify", []interface{}{red})
+				So(rpcErr, ShouldBeNil)
+				rs, _ := toArray(res)
+				ers, _ := toArray(rs[0])
+				So(ers, ShouldBeEmpty)
+
+				res, rpcErr = c.call("load", "m1")
+				So(rpcErr, ShouldBeNil)
+				So(res, ShouldEqual, true)
+				res, rpcErr = c.call("classify", []interface{}{red})
+				So(rpcErr, ShouldBeNil)
+				rs, _ = toArray(res)
+				ers, _ = toArray(rs[0])
+				So(len(ers), ShouldEqual, 2)
+			})
+		})
+
+		Convey("when saving a model with an invalid id", func() {
+			_, rpcErr := c.call("save", "../m1")
+
+			Convey("it should fail with an argument error", func() {
+				So(rpcErr, ShouldEqual, rpcArgumentError)
+			})
+		})
+
+		Convey("when loading a nonexistent model", func() {
+			_, rpcErr := c.call("load", "nonexistent")
+
+			Convey("it should fail", func() {
+				So(rpcErr, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when calling an unknown method", func() {
+			_, rpcErr := c.call("estimate", []interface{}{red})
+
+			Convey("it should fail with a no method error", func() {
+				So(rpcErr, ShouldEqual, rpcNoMethodError)
+			})
+		})
+
+		Convey("when calling a method with invalid parameters", func() {
+			_, rpcErr := c.call("train", "r", red)
+
+			Convey("it should fail with an argument error", func() {
+				So(rpcErr, ShouldEqual, rpcArgumentError)
+			})
+		})
+
+		Convey("when getting its config", func() {
+			res, rpcErr := c.call("get_config")
+
+			Convey("it should return a JSON", func() {
+				So(rpcErr, ShouldBeNil)
+				So(res, ShouldEqual, `{"method":"AROW","parameter":{"regularization_weight":1}}`)
+			})
+		})
+	})
+}
+
+func TestRegressionServer(t *testing.T) {
+	Convey("Given a regression server", t, func() {
+		pa, err := regression.NewPassiveAggressive(100, 0)
+		So(err, ShouldBeNil)
+		c, stop := startServer(NewRegression(pa, os.TempDir()))
+		defer stop()
+
+		Convey("when training it", func() {
+			data := []interface{}{}
+			for i := 0; i < 20; i++ {
+				data = append(data, []interface{}{2.0, newDatum(nil, map[string]float64{"x": 1})})
+			}
+			res, rpcErr := c.call("train", data)
+			So(rpcErr, ShouldBeNil)
+			n, err := toInt(res)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 20)
+
+			Convey("it should estimate values", func() {
+				res, rpcErr := c.call("estimate", []interface{}{newDatum(nil, map[string]float64{"x": 1})})
+				So(rpcErr, ShouldBeNil)
+				vs, err := toArray(res)
+				So(err, ShouldBeNil)
+				So(len(vs), ShouldEqual, 1)
+				v, err := toFloat(vs[0])
+				So(err, ShouldBeNil)
+				So(v, ShouldAlmostEqual, 2, 1e-3)
+			})
+		})
+	})
+}
+
+func TestAnomalyServer(t *testing.T) {
+	Convey("Given an anomaly server", t, func() {
+		l, err := anomaly.NewLightLOF(anomaly.EuclidLSH, 64, 2, 2, 0, 0)
+		So(err, ShouldBeNil)
+		c, stop := startServer(NewAnomaly(l, os.TempDir()))
+		defer stop()
+
+		Convey("when adding data", func() {
+			for i := 0; i < 5; i++ {
+				res, rpcErr := c.call("add", newDatum(nil, map[string]float64{"x": float64(i)}))
+				So(rpcErr, ShouldBeNil)
+				p, err := toPair(res)
+				So(err, ShouldBeNil)
+				id, err := toString(p[0])
+				So(err, ShouldBeNil)
+				So(id, ShouldNotBeEmpty)
+			}
+
+			Convey("it should calculate scores", func() {
+				res, rpcErr := c.call("calc_score", newDatum(nil, map[string]float64{"x": 2}))
+				So(rpcErr, ShouldBeNil)
+				_, err := toFloat(res)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("it should be cleared", func() {
+				_, rpcErr := c.call("clear")
+				So(rpcErr, ShouldBeNil)
+				res, rpcErr := c.call("add", newDatum(nil, map[string]float64{"x": 1}))
+				So(rpcErr, ShouldBeNil)
+				p, _ := toPair(res)
+				So(p[0], ShouldEqual, "1")
+			})
+		})
+	})
+}
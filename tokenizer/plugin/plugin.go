@@ -0,0 +1,10 @@
+package plugin
+
+import (
+	"github.com/zeromberto/jubatus/tokenizer"
+	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
+)
+
+func init() {
+	udf.MustRegisterGlobalUDF("juba_tokenize", udf.MustConvertGeneric(tokenizer.Tokenize))
+}
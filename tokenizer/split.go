@@ -0,0 +1,124 @@
+package tokenizer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type charNGram int
+
+// NewCharNGram creates a tokenizer returning character n-grams. A string
+// shorter than n doesn't have any token.
+func NewCharNGram(n int) (Tokenizer, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("n of char_ngram must be greater than zero: %v", n)
+	}
+	return charNGram(n), nil
+}
+
+func (n charNGram) Tokenize(s string) []string {
+	rs := []rune(s)
+	if len(rs) < int(n) {
+		return nil
+	}
+	ts := make([]string, 0, len(rs)-int(n)+1)
+	for i := 0; i+int(n) <= len(rs); i++ {
+		ts = append(ts, string(rs[i:i+int(n)]))
+	}
+	return ts
+}
+
+type wordNGram struct {
+	n     int
+	words *delimiter
+}
+
+// NewWordNGram creates a tokenizer returning n-grams of words split by sep.
+// Words of an n-gram are joined with a white space. Words are split by white
+// spaces when sep is empty.
+func NewWordNGram(n int, sep string) (Tokenizer, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("n of word_ngram must be greater than zero: %v", n)
+	}
+	return &wordNGram{
+		n:     n,
+		words: &delimiter{sep: sep},
+	}, nil
+}
+
+func (w *wordNGram) Tokenize(s string) []string {
+	ws := w.words.Tokenize(s)
+	if w.n == 1 {
+		return ws
+	}
+	if len(ws) < w.n {
+		return nil
+	}
+	ts := make([]string, 0, len(ws)-w.n+1)
+	for i := 0; i+w.n <= len(ws); i++ {
+		ts = append(ts, strings.Join(ws[i:i+w.n], " "))
+	}
+	return ts
+}
+
+type regexpTokenizer struct {
+	re    *regexp.Regexp
+	group int
+}
+
+// NewRegexp creates a tokenizer returning matches of pattern. group is the
+// index of the capture group used as a token, and 0 means the whole match.
+func NewRegexp(pattern string, group int) (Tokenizer, error) {
+	if pattern == "" {
+		return nil, errors.New("pattern of regexp must not be empty")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("pattern of regexp is invalid: %v", err)
+	}
+	if group < 0 || group > re.NumSubexp() {
+		return nil, fmt.Errorf("group of regexp must be in [0, %v]: %v", re.NumSubexp(), group)
+	}
+	return &regexpTokenizer{
+		re:    re,
+		group: group,
+	}, nil
+}
+
+func (r *regexpTokenizer) Tokenize(s string) []string {
+	var ts []string
+	for _, m := range r.re.FindAllStringSubmatch(s, -1) {
+		if m[r.group] != "" {
+			ts = append(ts, m[r.group])
+		}
+	}
+	return ts
+}
+
+type delimiter struct {
+	sep string
+}
+
+// NewDelimiter creates a tokenizer splitting a string by sep. It splits a
+// string by white spaces when sep is empty.
+func NewDelimiter(sep string) Tokenizer {
+	return &delimiter{
+		sep: sep,
+	}
+}
+
+func (d *delimiter) Tokenize(s string) []string {
+	if d.sep == "" {
+		return strings.Fields(s)
+	}
+	ws := strings.Split(s, d.sep)
+	ts := ws[:0]
+	for _, w := range ws {
+		if w != "" {
+			ts = append(ts, w)
+		}
+	}
+	return ts
+}
@@ -0,0 +1,164 @@
+// Package tokenizer splits strings into tokens so that text can be used as
+// bag-of-words features of classifier, regression and anomaly detection.
+//
+// A tokenizer is configured by a map having method and its parameters:
+//
+//	char_ngram: character n-grams, n defaults to 2
+//	word_ngram: n-grams of words split by delimiter, n defaults to 1 and
+//	            words of an n-gram are joined with a white space
+//	regexp: matches of pattern, group is the index of the capture group used
+//	        as a token, which defaults to 0, i.e. the whole match
+//	delimiter: words split by delimiter
+//
+// delimiter of word_ngram and delimiter is a string separating words. Words
+// are split by white spaces when it's empty or not given. Empty tokens are
+// never returned.
+package tokenizer
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// Tokenizer splits a string into tokens.
+type Tokenizer interface {
+	// Tokenize returns tokens of s in the order of their occurrences. The
+	// same token can appear more than once.
+	Tokenize(s string) []string
+}
+
+// New creates a tokenizer from a config.
+func New(cfg data.Map) (Tokenizer, error) {
+	v, ok := cfg["method"]
+	if !ok {
+		return nil, errors.New("method of tokenizer must be given")
+	}
+	method, err := data.AsString(v)
+	if err != nil {
+		return nil, fmt.Errorf("method of tokenizer must be a string: %v", err)
+	}
+
+	switch method {
+	case "char_ngram":
+		if err := checkParams(cfg, "n"); err != nil {
+			return nil, err
+		}
+		n, err := intParam(cfg, "n", 2)
+		if err != nil {
+			return nil, err
+		}
+		return NewCharNGram(n)
+
+	case "word_ngram":
+		if err := checkParams(cfg, "n", "delimiter"); err != nil {
+			return nil, err
+		}
+		n, err := intParam(cfg, "n", 1)
+		if err != nil {
+			return nil, err
+		}
+		d, err := stringParam(cfg, "delimiter", "")
+		if err != nil {
+			return nil, err
+		}
+		return NewWordNGram(n, d)
+
+	case "regexp":
+		if err := checkParams(cfg, "pattern", "group"); err != nil {
+			return nil, err
+		}
+		p, err := stringParam(cfg, "pattern", "")
+		if err != nil {
+			return nil, err
+		}
+		g, err := intParam(cfg, "group", 0)
+		if err != nil {
+			return nil, err
+		}
+		return NewRegexp(p, g)
+
+	case "delimiter":
+		if err := checkParams(cfg, "delimiter"); err != nil {
+			return nil, err
+		}
+		d, err := stringParam(cfg, "delimiter", "")
+		if err != nil {
+			return nil, err
+		}
+		return NewDelimiter(d), nil
+
+	default:
+		return nil, fmt.Errorf("unknown method of tokenizer: %v", method)
+	}
+}
+
+// checkParams returns an error when cfg has an unknown parameter.
+func checkParams(cfg data.Map, names ...string) error {
+	for k := range cfg {
+		if k == "method" {
+			continue
+		}
+		known := false
+		for _, n := range names {
+			if k == n {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown parameter of tokenizer: %v", k)
+		}
+	}
+	return nil
+}
+
+func intParam(cfg data.Map, name string, def int) (int, error) {
+	v, ok := cfg[name]
+	if !ok {
+		return def, nil
+	}
+	n, err := data.ToInt(v)
+	if err != nil {
+		return 0, fmt.Errorf("%v of tokenizer must be an integer: %v", name, err)
+	}
+	return int(n), nil
+}
+
+func stringParam(cfg data.Map, name string, def string) (string, error) {
+	v, ok := cfg[name]
+	if !ok {
+		return def, nil
+	}
+	s, err := data.AsString(v)
+	if err != nil {
+		return "", fmt.Errorf("%v of tokenizer must be a string: %v", name, err)
+	}
+	return s, nil
+}
+
+// BagOfWords returns a map from tokens of s to the numbers of their
+// occurrences. The map can be used as a feature vector.
+func BagOfWords(t Tokenizer, s string) data.Map {
+	m := data.Map{}
+	for _, tk := range t.Tokenize(s) {
+		if v, ok := m[tk]; ok {
+			x, _ := data.AsFloat(v)
+			m[tk] = data.Float(x + 1)
+		} else {
+			m[tk] = data.Float(1)
+		}
+	}
+	return m
+}
+
+// Tokenize splits text with a tokenizer created from cfg and returns the bag
+// of words. It's provided as juba_tokenize UDF to preview how text is
+// tokenized.
+func Tokenize(text string, cfg data.Map) (data.Map, error) {
+	t, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return BagOfWords(t, text), nil
+}
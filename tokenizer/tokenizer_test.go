@@ -0,0 +1,94 @@
+package tokenizer
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestTokenizers(t *testing.T) {
+	Convey("Given tokenizers", t, func() {
+		cases := []struct {
+			title    string
+			cfg      data.Map
+			input    string
+			expected []string
+		}{
+			{"char bigram", data.Map{"method": data.String("char_ngram")}, "日本語", []string{"日本", "本語"}},
+			{"char trigram of a short string", data.Map{"method": data.String("char_ngram"), "n": data.Int(3)}, "ab", nil},
+			{"word unigram", data.Map{"method": data.String("word_ngram")}, " a  b\tc ", []string{"a", "b", "c"}},
+			{"word bigram", data.Map{"method": data.String("word_ngram"), "n": data.Int(2)}, "a b c", []string{"a b", "b c"}},
+			{"word bigram with a delimiter", data.Map{"method": data.String("word_ngram"), "n": data.Int(2), "delimiter": data.String(",")}, "a,,b,c", []string{"a b", "b c"}},
+			{"regexp", data.Map{"method": data.String("regexp"), "pattern": data.String("[0-9]+")}, "a1b22c", []string{"1", "22"}},
+			{"regexp with a group", data.Map{"method": data.String("regexp"), "pattern": data.String("k=([a-z]*)"), "group": data.Int(1)}, "k=x k= k=y", []string{"x", "y"}},
+			{"delimiter", data.Map{"method": data.String("delimiter"), "delimiter": data.String("|")}, "a||b|a", []string{"a", "b", "a"}},
+			{"white spaces", data.Map{"method": data.String("delimiter")}, "a b\n", []string{"a", "b"}},
+		}
+
+		for _, c := range cases {
+			c := c
+			Convey("when tokenizing with "+c.title, func() {
+				tk, err := New(c.cfg)
+				So(err, ShouldBeNil)
+
+				Convey("it should return expected tokens", func() {
+					So(tk.Tokenize(c.input), ShouldResemble, c.expected)
+				})
+			})
+		}
+	})
+}
+
+func TestNewWithInvalidConfig(t *testing.T) {
+	Convey("Given invalid configs", t, func() {
+		cases := map[string]data.Map{
+			"no method":        {},
+			"unknown method":   {"method": data.String("unknown")},
+			"zero n":           {"method": data.String("char_ngram"), "n": data.Int(0)},
+			"negative n":       {"method": data.String("word_ngram"), "n": data.Int(-1)},
+			"no pattern":       {"method": data.String("regexp")},
+			"invalid pattern":  {"method": data.String("regexp"), "pattern": data.String("(")},
+			"too large group":  {"method": data.String("regexp"), "pattern": data.String("(a)"), "group": data.Int(2)},
+			"unknown param":    {"method": data.String("delimiter"), "n": data.Int(1)},
+			"non-string delim": {"method": data.String("delimiter"), "delimiter": data.Int(1)},
+		}
+
+		for name, cfg := range cases {
+			name, cfg := name, cfg
+			Convey("when creating a tokenizer with "+name, func() {
+				_, err := New(cfg)
+
+				Convey("it should fail", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+		}
+	})
+}
+
+func TestTokenize(t *testing.T) {
+	Convey("Given a text", t, func() {
+		text := "a b a c a"
+
+		Convey("when tokenizing it", func() {
+			m, err := Tokenize(text, data.Map{"method": data.String("word_ngram")})
+			So(err, ShouldBeNil)
+
+			Convey("it should return the bag of words", func() {
+				So(m, ShouldResemble, data.Map{
+					"a": data.Float(3),
+					"b": data.Float(1),
+					"c": data.Float(1),
+				})
+			})
+		})
+
+		Convey("when tokenizing it with an invalid config", func() {
+			_, err := Tokenize(text, data.Map{})
+
+			Convey("it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}